@@ -0,0 +1,17 @@
+package client
+
+import (
+	lnlisters "github.com/cni-genie/CNI-Genie/controllers/logicalnetwork-pkg/client/listers/network/v1"
+	netattachlisters "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/listers/k8s.cni.cncf.io/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// Cache serves the objects looked up by genie from the informers of a long
+// running process, eg: genie daemon, instead of the api server. Listers which
+// are not set are not used, and objects missing in the cache are still fetched
+// from the api server, as the informers may lag behind it.
+type Cache struct {
+	Pods            corelisters.PodLister
+	NetAttachDefs   netattachlisters.NetworkAttachmentDefinitionLister
+	LogicalNetworks lnlisters.LogicalNetworkLister
+}
@@ -1,71 +1,175 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
+	netattachclient "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned"
 	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"github.com/cni-genie/CNI-Genie/utils/metrics"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"os"
+	"k8s.io/client-go/util/flowcontrol"
+	"net/http"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ClientInterface interface {
-	GetPod(name, namespace string) (*v1.Pod, error)
-	PatchPod(name, namespace string, pt types.PatchType, data []byte) (*v1.Pod, error)
-	GetRaw(path string) ([]byte, error)
+	GetPod(ctx context.Context, name, namespace string) (*v1.Pod, error)
+	PatchPod(ctx context.Context, name, namespace string, pt types.PatchType, data []byte) (*v1.Pod, error)
+	GetRaw(ctx context.Context, path string) ([]byte, error)
+	GetNetworkAttachmentDefinition(ctx context.Context, name, namespace string) (*netattachv1.NetworkAttachmentDefinition, error)
+	GetLogicalNetwork(ctx context.Context, name, namespace string) (*utils.LogicalNetwork, error)
 }
 
+// KubeClient accesses the api server. Calls are retried on transient errors
+// as per Options, and fail with an *APIError.
 type KubeClient struct {
 	kubernetes.Interface
+	// NetClient accesses the network attachment definitions of k8s.cni.cncf.io
+	NetClient netattachclient.Interface
+	Options   Options
+	// Cache, if set, is looked up before the api server
+	Cache *Cache
+	// EventLimiter, if set, bounds the rate of the events recorded on pods
+	EventLimiter flowcontrol.RateLimiter
 }
 
-func (kc *KubeClient) GetPod(name, namespace string) (*v1.Pod, error) {
-	pod, err := kc.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+func (kc *KubeClient) GetPod(ctx context.Context, name, namespace string) (*v1.Pod, error) {
+	if kc.Cache != nil && kc.Cache.Pods != nil {
+		if pod, err := kc.Cache.Pods.Pods(namespace).Get(name); err == nil {
+			return pod.DeepCopy(), nil
+		}
+	}
+	obj, err := kc.call(ctx, fmt.Sprintf("getting pod %s/%s", namespace, name), func(ctx context.Context) (interface{}, error) {
+		return kc.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.(*v1.Pod), nil
+}
+
+func (kc *KubeClient) PatchPod(ctx context.Context, name, namespace string, pt types.PatchType, data []byte) (*v1.Pod, error) {
+	obj, err := kc.call(ctx, fmt.Sprintf("patching pod %s/%s", namespace, name), func(ctx context.Context) (interface{}, error) {
+		return kc.CoreV1().Pods(namespace).Patch(name, pt, data)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return pod, nil
+	return obj.(*v1.Pod), nil
 }
 
-func (kc *KubeClient) PatchPod(name, namespace string, pt types.PatchType, data []byte) (*v1.Pod, error) {
-	pod, err := kc.CoreV1().Pods(namespace).Patch(name, pt, data)
+func (kc *KubeClient) GetRaw(ctx context.Context, path string) ([]byte, error) {
+	obj, err := kc.call(ctx, "getting "+path, func(ctx context.Context) (interface{}, error) {
+		return kc.ExtensionsV1beta1().RESTClient().Get().AbsPath(path).Context(ctx).DoRaw()
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return pod, nil
+	return obj.([]byte), nil
 }
 
-func (kc *KubeClient) GetRaw(path string) ([]byte, error) {
-	obj, err := kc.ExtensionsV1beta1().RESTClient().Get().AbsPath(path).DoRaw()
+func (kc *KubeClient) GetNetworkAttachmentDefinition(ctx context.Context, name, namespace string) (*netattachv1.NetworkAttachmentDefinition, error) {
+	if kc.Cache != nil && kc.Cache.NetAttachDefs != nil {
+		if nad, err := kc.Cache.NetAttachDefs.NetworkAttachmentDefinitions(namespace).Get(name); err == nil {
+			return nad.DeepCopy(), nil
+		}
+	}
+	obj, err := kc.call(ctx, fmt.Sprintf("getting network attachment definition %s/%s", namespace, name), func(ctx context.Context) (interface{}, error) {
+		return kc.NetClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(namespace).Get(name, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return obj, nil
+	return obj.(*netattachv1.NetworkAttachmentDefinition), nil
+}
+
+func (kc *KubeClient) GetLogicalNetwork(ctx context.Context, name, namespace string) (*utils.LogicalNetwork, error) {
+	if kc.Cache != nil && kc.Cache.LogicalNetworks != nil {
+		if ln, err := kc.Cache.LogicalNetworks.LogicalNetworks(namespace).Get(name); err == nil {
+			return ln.DeepCopy(), nil
+		}
+	}
+	path := fmt.Sprintf("/apis/alpha.network.k8s.io/v1/namespaces/%s/logicalnetworks/%s", namespace, name)
+	obj, err := kc.GetRaw(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	ln := &utils.LogicalNetwork{}
+	if err = json.Unmarshal(obj, ln); err != nil {
+		return nil, fmt.Errorf("Error decoding logical network %s/%s: %v", namespace, name, err)
+	}
+	return ln, nil
 }
 
 // GetKubeClient creates a kubeclient from genie-kubeconfig file,
 // default location is /etc/cni/net.d.
 func BuildKubeClientFromConfig(conf *utils.GenieConf) (*KubeClient, error) {
-	config, err := buildKubeConfig(conf)
+	config, err := BuildKubeConfig(conf)
 	if err != nil {
 		return nil, err
 	}
+	opts, err := OptionsFromConfig(&conf.Kubernetes)
+	if err != nil {
+		return nil, err
+	}
+	// Every attempt is bounded by the request timeout, and the requests of all
+	// the calls together by the rate limits
+	config.Timeout = opts.RequestTimeout
+	config.QPS = DefaultQPS
+	if conf.Kubernetes.QPS > 0 {
+		config.QPS = conf.Kubernetes.QPS
+	}
+	config.Burst = DefaultBurst
+	if conf.Kubernetes.Burst > 0 {
+		config.Burst = conf.Kubernetes.Burst
+	}
+	config.Wrap(instrumentTransport)
 	// Create the clientset
 	kc, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
-	return &KubeClient{kc}, nil
+	nc, err := netattachclient.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	eventQPS, eventBurst := float32(DefaultEventQPS), DefaultEventBurst
+	if conf.Kubernetes.EventQPS > 0 {
+		eventQPS = conf.Kubernetes.EventQPS
+	}
+	if conf.Kubernetes.EventBurst > 0 {
+		eventBurst = conf.Kubernetes.EventBurst
+	}
+	// The token bucket is kept in the state directory, so that the rate is
+	// limited across the invocations of the plugin on the node as well
+	stateDir := conf.StateDir
+	if stateDir == "" {
+		stateDir = utils.DefaultStateDir
+	}
+	limiter := NewFileRateLimiter(filepath.Join(stateDir, EventBucketFile), eventQPS, eventBurst)
+	return &KubeClient{Interface: kc, NetClient: nc, Options: opts, EventLimiter: limiter}, nil
 }
 
-func buildKubeConfig(conf *utils.GenieConf) (*restclient.Config, error) {
+// BuildKubeConfig builds the rest config of the api server as per genie
+// configuration. The bounds of the calls are not applied to it, so that it
+// suits watches as well.
+func BuildKubeConfig(conf *utils.GenieConf) (*restclient.Config, error) {
 	// Some config can be passed in a kubeconfig file
 	kubeconfig := conf.Kubernetes.Kubeconfig
 
@@ -108,7 +212,30 @@ func buildKubeConfig(conf *utils.GenieConf) (*restclient.Config, error) {
 		return nil, err
 	}
 
-	fmt.Fprintf(os.Stderr, "CNI Genie Kubernetes config %v\n", config)
+	// Only the fields not carrying credentials are logged
+	logging.Debugf("Kubernetes config: host=%s, ca file=%s, client cert file=%s, bearer token set=%v",
+		config.Host, config.TLSClientConfig.CAFile, config.TLSClientConfig.CertFile, config.BearerToken != "")
 
 	return config, nil
 }
+
+// metricsRoundTripper records the latency and the outcome of every request
+// made to the api server
+type metricsRoundTripper struct {
+	rt http.RoundTripper
+}
+
+func instrumentTransport(rt http.RoundTripper) http.RoundTripper {
+	return &metricsRoundTripper{rt: rt}
+}
+
+func (m *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := m.rt.RoundTrip(req)
+	result := metrics.ResultError
+	if err == nil {
+		result = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.ObserveKubeAPI(req.Method, result, time.Since(start))
+	return resp, err
+}
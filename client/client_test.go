@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"io/ioutil"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetPodRetries(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	tests := []struct {
+		errs             []error
+		expectedCalls    int
+		expectedReason   ErrorReason
+		expectedNotFound bool
+	}{
+		{
+			errs:          []error{apierrors.NewServiceUnavailable("overloaded"), errors.New("connection refused")},
+			expectedCalls: 3,
+		},
+		{
+			errs:             []error{apierrors.NewNotFound(pods, "web")},
+			expectedCalls:    1,
+			expectedReason:   ReasonNotFound,
+			expectedNotFound: true,
+		},
+		{
+			errs:           []error{apierrors.NewForbidden(pods, "web", errors.New("denied"))},
+			expectedCalls:  1,
+			expectedReason: ReasonRejected,
+		},
+		{
+			errs: []error{apierrors.NewTooManyRequests("slow down", 1), apierrors.NewTooManyRequests("slow down", 1),
+				apierrors.NewTooManyRequests("slow down", 1), apierrors.NewTooManyRequests("slow down", 1)},
+			expectedCalls:  3,
+			expectedReason: ReasonUnavailable,
+		},
+	}
+
+	for i := range tests {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+		clientset := fake.NewSimpleClientset(pod)
+		calls := 0
+		errs := tests[i].errs
+		clientset.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			calls++
+			if calls <= len(errs) {
+				return true, nil, errs[calls-1]
+			}
+			return false, nil, nil
+		})
+		kc := &KubeClient{Interface: clientset, Options: Options{MaxAttempts: 3, RetryBackoff: time.Millisecond}}
+
+		got, err := kc.GetPod(context.TODO(), "web", "default")
+		if calls != tests[i].expectedCalls {
+			t.Errorf("Expected %d calls; got %d", tests[i].expectedCalls, calls)
+		}
+		if tests[i].expectedReason == "" {
+			if err != nil || got.Name != "web" {
+				t.Errorf("Expected pod web; got %v, error: %v", got, err)
+			}
+			continue
+		}
+		if reasonOf(err) != tests[i].expectedReason {
+			t.Errorf("Expected error with reason %s; got %v", tests[i].expectedReason, err)
+		}
+		if IsNotFound(err) != tests[i].expectedNotFound {
+			t.Errorf("Expected not found %v for error %v", tests[i].expectedNotFound, err)
+		}
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		time.Sleep(time.Second)
+		return true, nil, errors.New("too late")
+	})
+	kc := &KubeClient{Interface: clientset, Options: Options{CallTimeout: 50 * time.Millisecond}}
+
+	start := time.Now()
+	_, err := kc.GetPod(context.TODO(), "web", "default")
+	if !IsUnavailable(err) {
+		t.Errorf("Expected api server to be reported unavailable; got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected call to return at its timeout; took %v", elapsed)
+	}
+
+	if _, err = OptionsFromConfig(&utils.KubernetesConfig{RequestTimeout: "5"}); err == nil {
+		t.Errorf("Expected error for request timeout without unit")
+	}
+}
+
+func TestGetPodFromCache(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	cached := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: map[string]string{"cni": "weave"}}}
+	indexer.Add(cached)
+
+	tests := []struct {
+		name          string
+		expectedAnnot string
+		expectedCalls int
+	}{
+		{name: "web", expectedAnnot: "weave", expectedCalls: 0},
+		{name: "db", expectedAnnot: "flannel", expectedCalls: 1},
+	}
+
+	for i := range tests {
+		clientset := fake.NewSimpleClientset(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default", Annotations: map[string]string{"cni": "flannel"}}})
+		kc := &KubeClient{Interface: clientset, Cache: &Cache{Pods: corelisters.NewPodLister(indexer)}}
+
+		pod, err := kc.GetPod(context.TODO(), tests[i].name, "default")
+		if err != nil || pod.Annotations["cni"] != tests[i].expectedAnnot {
+			t.Errorf("Expected pod with cni annotation %s; got %v, error: %v", tests[i].expectedAnnot, pod, err)
+			continue
+		}
+		if calls := len(clientset.Actions()); calls != tests[i].expectedCalls {
+			t.Errorf("Expected %d api server calls; got %d", tests[i].expectedCalls, calls)
+		}
+		// The cached object must not be modified through the returned pod
+		pod.Annotations["cni"] = "changed"
+		if cached.Annotations["cni"] != "weave" {
+			t.Errorf("Expected cached pod to be left unmodified")
+		}
+	}
+}
+
+func TestRecordPodEvent(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	kc := &KubeClient{Interface: clientset, EventLimiter: flowcontrol.NewFakeNeverRateLimiter()}
+	pod := PodRef{Namespace: "default", Name: "web", UID: "uid1"}
+
+	if err := kc.RecordPodEvent(pod, v1.EventTypeNormal, "NetworkAttached", "attached"); err != ErrEventThrottled {
+		t.Errorf("Expected event to be throttled; got error: %v", err)
+	}
+
+	kc.EventLimiter = flowcontrol.NewFakeAlwaysRateLimiter()
+	message := strings.Repeat("x", 2*maxEventMessage)
+	if err := kc.RecordPodEvent(pod, v1.EventTypeWarning, "AttachFailed", message); err != nil {
+		t.Fatalf("Error recording event: %v", err)
+	}
+	events, err := clientset.CoreV1().Events("default").List(metav1.ListOptions{})
+	if err != nil || len(events.Items) != 1 {
+		t.Fatalf("Expected 1 event; got %v, error: %v", events, err)
+	}
+	event := events.Items[0]
+	if event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != "web" || event.InvolvedObject.UID != "uid1" {
+		t.Errorf("Expected event on pod default/web; got: %+v", event.InvolvedObject)
+	}
+	if event.Type != v1.EventTypeWarning || event.Reason != "AttachFailed" || event.Source.Component != EventComponent {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+	if len(event.Message) != maxEventMessage {
+		t.Errorf("Expected message truncated to %d characters; got %d", maxEventMessage, len(event.Message))
+	}
+}
+
+func TestEventRateLimitAcrossClients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-events")
+	if err != nil {
+		t.Fatalf("Error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	bucket := filepath.Join(dir, EventBucketFile)
+	pod := PodRef{Namespace: "default", Name: "web", UID: "uid1"}
+
+	// Every invocation of the plugin builds its own client, sharing only the
+	// bucket file with the others
+	recorded := 0
+	for i := 0; i < 5; i++ {
+		kc := &KubeClient{Interface: fake.NewSimpleClientset(), EventLimiter: NewFileRateLimiter(bucket, 0.001, 3)}
+		for j := 0; j < 2; j++ {
+			err := kc.RecordPodEvent(pod, v1.EventTypeWarning, "AttachFailed", "no free ip")
+			if err == nil {
+				recorded++
+			} else if err != ErrEventThrottled {
+				t.Fatalf("Client %d: error recording event: %v", i, err)
+			}
+		}
+	}
+	if recorded != 3 {
+		t.Errorf("Expected 3 events recorded by all the clients together; got: %d", recorded)
+	}
+
+	// The bucket is refilled as per the rate
+	limiter := NewFileRateLimiter(bucket, 1000, 3)
+	time.Sleep(10 * time.Millisecond)
+	if !limiter.TryAccept() {
+		t.Errorf("Expected the bucket to be refilled")
+	}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils/filelock"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"io/ioutil"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/flowcontrol"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Defaults of the rate of the events recorded on pods
+const (
+	DefaultEventQPS   = 1
+	DefaultEventBurst = 25
+)
+
+// EventBucketFile is the file, in the state directory of genie, keeping the
+// token bucket of the events recorded on pods
+const EventBucketFile = "events/bucket"
+
+// EventComponent is the source of the events recorded by genie
+const EventComponent = "cni-genie"
+
+// maxEventMessage bounds the message of an event, as the errors of the
+// delegates may be long
+const maxEventMessage = 1024
+
+// ErrEventThrottled is returned when an event is dropped as per the event
+// rate limit of the client
+var ErrEventThrottled = errors.New("event dropped as per event rate limit")
+
+// PodRef identifies the pod an event is recorded on
+type PodRef struct {
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// RecordPodEvent records an event on a pod. Events are not retried, and the
+// ones beyond the event rate limit of the client, eg: when many pods fail at
+// once, are dropped with ErrEventThrottled.
+func (kc *KubeClient) RecordPodEvent(pod PodRef, eventType, reason, message string) error {
+	if kc.EventLimiter != nil && !kc.EventLimiter.TryAccept() {
+		return ErrEventThrottled
+	}
+	if len(message) > maxEventMessage {
+		message = message[:maxEventMessage-3] + "..."
+	}
+	host, _ := os.Hostname()
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", pod.Name, now.UnixNano()),
+			Namespace: pod.Namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Namespace:  pod.Namespace,
+			Name:       pod.Name,
+			UID:        types.UID(pod.UID),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: EventComponent, Host: host},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	// A single attempt bounded by the request timeout, so that the pod is not
+	// held up by its events
+	if _, err := kc.CoreV1().Events(pod.Namespace).Create(event); err != nil {
+		op := fmt.Sprintf("recording event %s on pod %s/%s", reason, pod.Namespace, pod.Name)
+		return &APIError{Op: op, Reason: classify(err), Err: err}
+	}
+	return nil
+}
+
+// fileRateLimiter is a token bucket kept in a file, so that the rate is
+// limited across all the processes sharing the file, eg: the invocations of
+// the plugin on a node, each of them building its own client. Should the file
+// not be usable, the rate is limited within the process only.
+type fileRateLimiter struct {
+	path     string
+	qps      float32
+	burst    int
+	fallback flowcontrol.RateLimiter
+}
+
+type tokenBucket struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+// NewFileRateLimiter creates a rate limiter keeping its token bucket in the
+// given file. The bucket of a new file is full.
+func NewFileRateLimiter(path string, qps float32, burst int) flowcontrol.RateLimiter {
+	return &fileRateLimiter{
+		path:     path,
+		qps:      qps,
+		burst:    burst,
+		fallback: flowcontrol.NewTokenBucketRateLimiter(qps, burst),
+	}
+}
+
+func (l *fileRateLimiter) TryAccept() bool {
+	ok, err := l.take()
+	if err != nil {
+		logging.Warningf("Error taking token from %s, limiting the rate within the process: %v", l.path, err)
+		return l.fallback.TryAccept()
+	}
+	return ok
+}
+
+func (l *fileRateLimiter) Accept() {
+	for !l.TryAccept() {
+		time.Sleep(time.Duration(float64(time.Second) / float64(l.qps)))
+	}
+}
+
+func (l *fileRateLimiter) Stop() {
+	l.fallback.Stop()
+}
+
+func (l *fileRateLimiter) QPS() float32 {
+	return l.qps
+}
+
+// take refills the bucket for the time elapsed since it was last used and
+// takes a token from it, if there is one
+func (l *fileRateLimiter) take() (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return false, err
+	}
+	unlock, err := filelock.Lock(l.path + ".lock")
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	now := time.Now()
+	bucket := tokenBucket{Tokens: float64(l.burst), Last: now}
+	data, err := ioutil.ReadFile(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	// A corrupted bucket is started afresh
+	if err == nil && json.Unmarshal(data, &bucket) != nil {
+		bucket = tokenBucket{Tokens: float64(l.burst), Last: now}
+	}
+	if elapsed := now.Sub(bucket.Last); elapsed > 0 {
+		bucket.Tokens = math.Min(float64(l.burst), bucket.Tokens+elapsed.Seconds()*float64(l.qps))
+	}
+	bucket.Last = now
+
+	ok := bucket.Tokens >= 1
+	if ok {
+		bucket.Tokens--
+	}
+	if data, err = json.Marshal(&bucket); err != nil {
+		return false, err
+	}
+	if err = ioutil.WriteFile(l.path, data, 0600); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
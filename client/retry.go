@@ -0,0 +1,181 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"time"
+)
+
+// Defaults of the bounds of api server calls, used for the ones not configured
+const (
+	DefaultRequestTimeout = 5 * time.Second
+	DefaultCallTimeout    = 20 * time.Second
+	DefaultMaxAttempts    = 4
+	DefaultRetryBackoff   = 200 * time.Millisecond
+	DefaultQPS            = 5
+	DefaultBurst          = 10
+)
+
+// ErrorReason classifies the failures of calls to the api server
+type ErrorReason string
+
+const (
+	// ReasonNotFound is returned when the requested object does not exist
+	ReasonNotFound ErrorReason = "NotFound"
+	// ReasonUnavailable is returned when the api server could not be reached,
+	// timed out or was overloaded, even after retrying
+	ReasonUnavailable ErrorReason = "Unavailable"
+	// ReasonRejected is returned when the api server refused the request, eg:
+	// as forbidden or invalid. Such requests are not retried.
+	ReasonRejected ErrorReason = "Rejected"
+)
+
+// APIError is the error returned by the calls of KubeClient
+type APIError struct {
+	// Operation which failed, eg: getting pod default/web
+	Op     string
+	Reason ErrorReason
+	Err    error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Error %s (%s): %v", e.Op, e.Reason, e.Err)
+}
+
+// IsNotFound reports whether err is due to the requested object not existing
+func IsNotFound(err error) bool {
+	return reasonOf(err) == ReasonNotFound
+}
+
+// IsUnavailable reports whether err is due to the api server not being available
+func IsUnavailable(err error) bool {
+	return reasonOf(err) == ReasonUnavailable
+}
+
+func reasonOf(err error) ErrorReason {
+	if e, ok := err.(*APIError); ok {
+		return e.Reason
+	}
+	return ""
+}
+
+// classify tells the reason of a failed request. Errors without an api status
+// did not get a response from the api server, and are transient as are the
+// statuses of an overloaded or timed out server.
+func classify(err error) ErrorReason {
+	switch {
+	case apierrors.IsNotFound(err):
+		return ReasonNotFound
+	case err == context.DeadlineExceeded || err == context.Canceled:
+		return ReasonUnavailable
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsTooManyRequests(err),
+		apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err):
+		return ReasonUnavailable
+	}
+	if _, ok := err.(apierrors.APIStatus); !ok {
+		return ReasonUnavailable
+	}
+	return ReasonRejected
+}
+
+// Options bound the calls made to the api server
+type Options struct {
+	RequestTimeout time.Duration
+	CallTimeout    time.Duration
+	MaxAttempts    int
+	RetryBackoff   time.Duration
+}
+
+// OptionsFromConfig reads the options from the kubernetes configuration of
+// genie, leaving the defaults for the ones not set
+func OptionsFromConfig(conf *utils.KubernetesConfig) (Options, error) {
+	opts := Options{MaxAttempts: conf.MaxAttempts}
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"request_timeout", conf.RequestTimeout, &opts.RequestTimeout},
+		{"call_timeout", conf.CallTimeout, &opts.CallTimeout},
+		{"retry_backoff", conf.RetryBackoff, &opts.RetryBackoff},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return opts, fmt.Errorf("Invalid kubernetes %s %q: expected a duration, eg: 5s", d.name, d.value)
+		}
+		*d.dst = v
+	}
+	if opts.MaxAttempts < 0 {
+		return opts, fmt.Errorf("Invalid kubernetes max_attempts %d", opts.MaxAttempts)
+	}
+	return opts.withDefaults(), nil
+}
+
+func (o Options) withDefaults() Options {
+	if o.RequestTimeout == 0 {
+		o.RequestTimeout = DefaultRequestTimeout
+	}
+	if o.CallTimeout == 0 {
+		o.CallTimeout = DefaultCallTimeout
+	}
+	if o.MaxAttempts == 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	if o.RetryBackoff == 0 {
+		o.RetryBackoff = DefaultRetryBackoff
+	}
+	return o
+}
+
+type result struct {
+	obj interface{}
+	err error
+}
+
+// call runs fn until it succeeds, fails with an error which is not transient,
+// runs out of attempts or the call timeout expires. Delay between the attempts
+// grows exponentially. fn is given a context bounded by the call timeout, and
+// the call returns once the context is done even if fn has not.
+func (kc *KubeClient) call(ctx context.Context, op string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	opts := kc.Options.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, opts.CallTimeout)
+	defer cancel()
+
+	backoff := opts.RetryBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		ch := make(chan result, 1)
+		go func() {
+			obj, err := fn(ctx)
+			ch <- result{obj, err}
+		}()
+		select {
+		case r := <-ch:
+			if r.err == nil {
+				return r.obj, nil
+			}
+			err = r.err
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		reason := classify(err)
+		if reason != ReasonUnavailable || attempt >= opts.MaxAttempts || ctx.Err() != nil {
+			return nil, &APIError{Op: op, Reason: reason, Err: err}
+		}
+		logging.Warningf("Attempt %d of %s failed, retrying in %v: %v", attempt, op, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, &APIError{Op: op, Reason: ReasonUnavailable, Err: fmt.Errorf("%v, last error: %v", ctx.Err(), err)}
+		}
+		backoff *= 2
+	}
+}
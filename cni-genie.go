@@ -14,14 +14,23 @@ package main
 
 import (
 	"fmt"
+	"github.com/cni-genie/CNI-Genie/daemon"
 	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/version"
+	"io/ioutil"
 	"os"
 	"runtime"
 )
 
+const (
+	// GCMinCNIVersion specifies the minimum cni version supporting GC and STATUS commands
+	GCMinCNIVersion = "1.1.0"
+)
+
 func init() {
 	// This ensures that main runs only on main thread (thread group leader).
 	// since namespace ops (unshare, setns) are done for a single thread, we
@@ -30,40 +39,50 @@ func init() {
 }
 
 func cmdAdd(args *skel.CmdArgs) error {
-	fmt.Fprintf(os.Stderr, "CNI Genie cmdAdd = %v\n", string(args.StdinData))
-
 	conf, err := genie.ParseCNIConf(args.StdinData)
 	if err != nil {
 		return fmt.Errorf("failed to load netconf: %v", err)
 	}
+	if conf.DaemonSocket != "" {
+		return forward(conf, "ADD", args)
+	}
+	cniArgs := genie.PopulateCNIArgs(args)
+	genie.SetupLogging(conf, cniArgs)
+	defer genie.FlushMetrics(conf)
+	logging.Debugf("cmdAdd = %v", logging.Redact(string(args.StdinData)))
+
 	gc, err := genie.NewGenieController(conf)
 	if err != nil {
 		return err
 	}
-	cniArgs := genie.PopulateCNIArgs(args)
-	fmt.Fprintf(os.Stderr, "CNI Genie Add IP address\n")
+	logging.Infof("Adding pod networks")
 	result, ipamErr := gc.AddPodNetwork(cniArgs, conf)
 	if ipamErr != nil || nil == result {
 		return fmt.Errorf("CNI Genie Add IP internal error: %v, result: %s", ipamErr, result)
 	}
 
-	fmt.Fprintf(os.Stderr, "CNI Genie End result= %s\n", result)
-	return types.PrintResult(result, conf.CNIVersion)
+	logging.Infof("End result= %s", result)
+	return types.PrintResult(result, genie.ResultVersion(conf))
 }
 
 func cmdDel(args *skel.CmdArgs) error {
-	fmt.Fprintf(os.Stderr, "CNI Genie cmdDel = %v\n", string(args.StdinData))
-
 	conf, err := genie.ParseCNIConf(args.StdinData)
 	if err != nil {
 		return fmt.Errorf("failed to load netconf: %v", err)
 	}
+	if conf.DaemonSocket != "" {
+		return forward(conf, "DEL", args)
+	}
+	cniArgs := genie.PopulateCNIArgs(args)
+	genie.SetupLogging(conf, cniArgs)
+	defer genie.FlushMetrics(conf)
+	logging.Debugf("cmdDel = %v", logging.Redact(string(args.StdinData)))
+
 	gc, err := genie.NewGenieController(conf)
 	if err != nil {
 		return err
 	}
-	cniArgs := genie.PopulateCNIArgs(args)
-	fmt.Fprintf(os.Stderr, "CNI Genie releasing IP address\n")
+	logging.Infof("Deleting pod networks")
 	ipamErr := gc.DeletePodNetwork(cniArgs, conf)
 	if ipamErr != nil {
 		return fmt.Errorf("CNI Genie release IP internal error: %v", ipamErr)
@@ -73,11 +92,136 @@ func cmdDel(args *skel.CmdArgs) error {
 }
 
 func cmdCheck(args *skel.CmdArgs) error {
-	fmt.Fprintf(os.Stderr, "CNI Genie cmdCheck = %v\n", string(args.StdinData))
+	conf, err := genie.ParseCNIConf(args.StdinData)
+	if err != nil {
+		return fmt.Errorf("failed to load netconf: %v", err)
+	}
+	if conf.DaemonSocket != "" {
+		return forward(conf, "CHECK", args)
+	}
+	cniArgs := genie.PopulateCNIArgs(args)
+	genie.SetupLogging(conf, cniArgs)
+	defer genie.FlushMetrics(conf)
+	logging.Debugf("cmdCheck = %v", logging.Redact(string(args.StdinData)))
+
+	gc, err := genie.NewGenieController(conf)
+	if err != nil {
+		return err
+	}
+	logging.Infof("Checking pod networks")
+	err = gc.CheckPodNetwork(cniArgs, conf)
+	if err != nil {
+		return fmt.Errorf("CNI Genie check internal error: %v", err)
+	}
 
 	return nil
 }
 
+// forward hands the invocation over to genie daemon, printing the result of
+// ADD as returned by the daemon
+func forward(conf *utils.GenieConf, command string, args *skel.CmdArgs) error {
+	out, err := daemon.Forward(conf.DaemonSocket, command, args)
+	if err != nil {
+		return err
+	}
+	if len(out) > 0 {
+		_, err = os.Stdout.Write(out)
+	}
+	return err
+}
+
+func cmdGC(stdinData []byte) error {
+	conf, err := parseConfForCommand(stdinData)
+	if err != nil {
+		return err
+	}
+	if conf.DaemonSocket != "" {
+		return forward(conf, "GC", &skel.CmdArgs{StdinData: stdinData})
+	}
+	genie.SetupLogging(conf, nil)
+	defer genie.FlushMetrics(conf)
+	logging.Debugf("cmdGC = %v", logging.Redact(string(stdinData)))
+
+	gc, err := genie.NewGenieController(conf)
+	if err != nil {
+		return err
+	}
+
+	return gc.GarbageCollect(conf)
+}
+
+func cmdStatus(stdinData []byte) error {
+	conf, err := parseConfForCommand(stdinData)
+	if err != nil {
+		return err
+	}
+	if conf.DaemonSocket != "" {
+		return forward(conf, "STATUS", &skel.CmdArgs{StdinData: stdinData})
+	}
+	genie.SetupLogging(conf, nil)
+	defer genie.FlushMetrics(conf)
+	logging.Debugf("cmdStatus = %v", logging.Redact(string(stdinData)))
+
+	gc, err := genie.NewGenieController(conf)
+	if err != nil {
+		return err
+	}
+
+	return gc.Status(conf)
+}
+
+// parseConfForCommand parses the netconf for the commands introduced in
+// cni version 1.1.0 and ensures that the requested version supports them
+func parseConfForCommand(stdinData []byte) (*utils.GenieConf, error) {
+	conf, err := genie.ParseCNIConf(stdinData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load netconf: %v", err)
+	}
+	gtet, err := version.GreaterThanOrEqualTo(conf.CNIVersion, GCMinCNIVersion)
+	if err != nil || !gtet {
+		return nil, &types.Error{
+			Code: types.ErrIncompatibleCNIVersion,
+			Msg:  fmt.Sprintf("config version %q does not support the %s command", conf.CNIVersion, os.Getenv("CNI_COMMAND")),
+		}
+	}
+	return conf, nil
+}
+
+// runCommand executes the commands which are not known to the skel package,
+// reporting the error, if any, in the same way as skel does
+func runCommand(cmd func([]byte) error) {
+	stdinData, err := ioutil.ReadAll(os.Stdin)
+	if err == nil {
+		err = cmd(stdinData)
+	}
+	if err != nil {
+		e, ok := err.(*types.Error)
+		if !ok {
+			e = &types.Error{Code: types.ErrUnknown, Msg: err.Error()}
+		}
+		if err = e.Print(); err != nil {
+			logging.Errorf("Error writing error JSON to stdout: %v", err)
+		}
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
 func main() {
-	skel.PluginMain(cmdAdd, cmdCheck, cmdDel, version.All, "CNI Genie plugin")
+	// Subcommands meant for the administrator, when not invoked by the runtime
+	if os.Getenv("CNI_COMMAND") == "" && len(os.Args) > 1 && os.Args[1] == genie.ExplainCommand {
+		if err := genie.Explain(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	switch os.Getenv("CNI_COMMAND") {
+	case "GC":
+		runCommand(cmdGC)
+	case "STATUS":
+		runCommand(cmdStatus)
+	}
+	skel.PluginMain(cmdAdd, cmdCheck, cmdDel, genie.PluginVersions, "CNI Genie plugin")
 }
@@ -0,0 +1,88 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cni-genie/CNI-Genie/client"
+	"github.com/cni-genie/CNI-Genie/controllers/network-policy-controller/signals"
+	"github.com/cni-genie/CNI-Genie/daemon"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+)
+
+var (
+	genieConf  string
+	kubeconfig string
+	socket     string
+	nodeName   string
+	resync     time.Duration
+)
+
+func fatalf(format string, args ...interface{}) {
+	logging.Errorf(format, args...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+
+	// set up signals so we handle the first shutdown signal gracefully
+	stopCh := signals.SetupSignalHandler()
+
+	confData, err := ioutil.ReadFile(genieConf)
+	if err != nil {
+		fatalf("Error reading genie configuration: %v", err)
+	}
+	conf, err := genie.ParseCNIConf(confData)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if kubeconfig != "" {
+		conf.Kubernetes.Kubeconfig = kubeconfig
+	}
+	// Logging is configured once for the daemon, every request then only sets
+	// the container and the pod it is for
+	genie.SetupLogging(conf, nil)
+
+	kc, err := client.BuildKubeClientFromConfig(conf)
+	if err != nil {
+		fatalf("Error building kubernetes client: %v", err)
+	}
+	config, err := client.BuildKubeConfig(conf)
+	if err != nil {
+		fatalf("Error building kubernetes config: %v", err)
+	}
+	kc.Cache, err = daemon.StartCache(config, nodeName, resync, stopCh)
+	if err != nil {
+		fatalf("%v", err)
+	}
+
+	server := &daemon.Server{Kc: kc}
+	if err = server.Serve(socket, stopCh); err != nil {
+		fatalf("Error serving genie requests: %v", err)
+	}
+}
+
+func init() {
+	flag.StringVar(&genieConf, "genie-conf", filepath.Join(genie.DefaultNetDir, genie.GenieConfFile), "Genie configuration file, from which the kubernetes and logging settings are taken.")
+	flag.StringVar(&kubeconfig, "kubeconfig", "", "Path to a kubeconfig, overrides the one in genie configuration. In cluster config is used if neither is set.")
+	flag.StringVar(&socket, "socket", daemon.DefaultSocket, "Unix socket to serve the requests of the genie plugin on.")
+	flag.StringVar(&nodeName, "node-name", os.Getenv("NODE_NAME"), "Name of the node, only the pods of which are cached.")
+	flag.DurationVar(&resync, "resync", 5*time.Minute, "Resync period of the informers.")
+}
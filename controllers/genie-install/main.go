@@ -0,0 +1,76 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/cni-genie/CNI-Genie/controllers/network-policy-controller/signals"
+	"github.com/cni-genie/CNI-Genie/install"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+)
+
+var (
+	opts     install.Options
+	logLevel string
+	resync   time.Duration
+)
+
+func fatalf(format string, args ...interface{}) {
+	logging.Errorf(format, args...)
+	os.Exit(1)
+}
+
+func main() {
+	flag.Parse()
+
+	if err := logging.Configure(logLevel, "", ""); err != nil {
+		fatalf("%v", err)
+	}
+	// set up signals so we handle the first shutdown signal gracefully
+	stopCh := signals.SetupSignalHandler()
+
+	// The genie configuration of the configmap is taken as template, as with launch.sh
+	opts.Template = []byte(os.Getenv("CNI_NETWORK_CONFIG"))
+	opts.ServiceHost = os.Getenv("KUBERNETES_SERVICE_HOST")
+	opts.ServicePort = os.Getenv("KUBERNETES_SERVICE_PORT")
+	opts.RomanaServiceHost = os.Getenv("ROMANA_ROOT_SERVICE_HOST")
+	opts.RomanaServicePort = os.Getenv("ROMANA_ROOT_SERVICE_PORT")
+	if opts.NodeName == "" {
+		opts.NodeName, _ = os.Hostname()
+	}
+
+	installer := install.NewInstaller(opts)
+	if err := installer.InstallBinary(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := installer.Run(resync, stopCh); err != nil {
+		fatalf("Error installing genie: %v", err)
+	}
+}
+
+func init() {
+	flag.StringVar(&opts.HostNetDir, "host-net-dir", "/host/etc/cni/net.d", "Cni conf dir of the node, as mounted in the installer.")
+	flag.StringVar(&opts.NetDir, "net-dir", "/etc/cni/net.d", "Cni conf dir as seen on the node.")
+	flag.StringVar(&opts.ConfName, "conf-name", install.DefaultConfName, "Name of genie configuration file.")
+	flag.StringVar(&opts.KubeconfigName, "kubeconfig-name", install.DefaultKubeconfigName, "Name of the kubeconfig of the plugin, written into the cni conf dir.")
+	flag.StringVar(&opts.TokenFile, "token-file", install.DefaultTokenFile, "Service account token written into the kubeconfig.")
+	flag.StringVar(&opts.CAFile, "ca-file", install.DefaultCAFile, "Ca certificate of the api server written into the kubeconfig.")
+	flag.StringVar(&opts.NodeName, "node-name", os.Getenv("KUBERNETES_NODE_NAME"), "Name of the node, substituted for __KUBERNETES_NODE_NAME__. Hostname is used if not set.")
+	flag.StringVar(&opts.Binary, "binary", "/opt/cni/bin/genie", "Genie plugin binary to install. It is not installed if empty.")
+	flag.StringVar(&opts.HostBinDir, "host-bin-dir", "/host/opt/cni/bin", "Cni bin dir of the node, as mounted in the installer.")
+	flag.StringVar(&logLevel, "log-level", "info", "Log level: debug, info, warning or error.")
+	flag.DurationVar(&resync, "resync", time.Minute, "Period of syncing the installation, in addition to syncing on changes.")
+}
@@ -0,0 +1,57 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NetworkAttachmentDefinition describes the network attachment objects
+type NetworkAttachmentDefinition struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object metadata
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec specifies an option to choose how the configuration for the
+	// CNI plugin, specified by this NetworkAttachmentDefinition object,
+	// is to be fetched, i.e. whether from the value defined for the sub-field
+	// or to be loaded from the corresponding configuration file present
+	// in the network directory, in case the sub-field or this field itself
+	// is empty.
+	// +optional
+	Spec NetworkAttachmentDefinitionSpec `json:"spec"`
+}
+
+// NetworkAttachmentDefinitionSpec describes the configuration of network object
+type NetworkAttachmentDefinitionSpec struct {
+	// Config specifies the configuration in conf or conflist
+	// format for the CNI plugin, which will be invoked by this
+	// NetworkAttachmentDefinition object
+	// +optional
+	Config string `json:"config,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NetworkAttachmentDefinitionList is a list of NetworkAttachmentDefinition objects
+type NetworkAttachmentDefinitionList struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard list metadata
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []NetworkAttachmentDefinition `json:"items"`
+}
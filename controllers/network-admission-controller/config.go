@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"time"
 
+	netattachclient "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned"
 	"k8s.io/api/admissionregistration/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -43,6 +44,19 @@ func getClient() *kubernetes.Clientset {
 	return clientset
 }
 
+// get a clientset of network attachment definitions with in-cluster config.
+func getNetClient() *netattachclient.Clientset {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		glog.Fatal(err)
+	}
+	clientset, err := netattachclient.NewForConfig(config)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	return clientset
+}
+
 // retrieve the CA cert that will signed the cert used by the
 // "GenericAdmissionWebhook" plugin admission controller.
 func getAPIServerCert(clientset *kubernetes.Clientset) []byte {
@@ -86,6 +100,31 @@ func selfRegistration(clientset *kubernetes.Clientset, caCert []byte) {
 			glog.Fatal(err2)
 		}
 	}
+	rules := []v1beta1.RuleWithOperations{{
+		Operations: []v1beta1.OperationType{v1beta1.Create, v1beta1.Update},
+		Rule: v1beta1.Rule{
+			APIGroups:   []string{"alpha.network.k8s.io"},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"logicalnetworks"},
+		},
+	}, {
+		Operations: []v1beta1.OperationType{v1beta1.Create, v1beta1.Update},
+		Rule: v1beta1.Rule{
+			APIGroups:   []string{"k8s.cni.cncf.io"},
+			APIVersions: []string{"v1"},
+			Resources:   []string{"network-attachment-definitions"},
+		},
+	}}
+	if podValidationEnabled() {
+		rules = append(rules, v1beta1.RuleWithOperations{
+			Operations: []v1beta1.OperationType{v1beta1.Create},
+			Rule: v1beta1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+			},
+		})
+	}
 	webhookConfig := &v1beta1.ValidatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "genie-network-admission-controller-config",
@@ -93,19 +132,63 @@ func selfRegistration(clientset *kubernetes.Clientset, caCert []byte) {
 		},
 		Webhooks: []v1beta1.ValidatingWebhook{
 			{
-				Name: "genie-network-admission-controller.k8s.io",
+				Name:  "genie-network-admission-controller.k8s.io",
+				Rules: rules,
+				ClientConfig: v1beta1.WebhookClientConfig{
+					Service: &v1beta1.ServiceReference{
+						Namespace: "kube-system",
+						Name:      "genie-network-admission-controller",
+					},
+					CABundle: caCert,
+				},
+			},
+		},
+	}
+	if _, err := client.Create(webhookConfig); err != nil {
+		glog.Fatal(err)
+	}
+	glog.Info("selfRegistration completed")
+}
+
+// path at which pods are mutated
+const mutatePath = "/mutate"
+
+// register the mutation of pods injecting device resources with the
+// kube-apiserver by creating mutatingWebhookConfigurations.
+func selfRegistrationMutating(clientset *kubernetes.Clientset, caCert []byte) {
+	time.Sleep(10 * time.Second)
+	client := clientset.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+	_, err := client.Get("genie-network-resource-injector-config", metav1.GetOptions{})
+	if err == nil {
+		if err2 := client.Delete("genie-network-resource-injector-config", nil); err2 != nil {
+			glog.Fatal(err2)
+		}
+	}
+	path := mutatePath
+	// Pods are not kept from being created if the injection fails
+	failurePolicy := v1beta1.Ignore
+	webhookConfig := &v1beta1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "genie-network-resource-injector-config",
+			Namespace: "kube-system",
+		},
+		Webhooks: []v1beta1.MutatingWebhook{
+			{
+				Name: "genie-network-resource-injector.k8s.io",
 				Rules: []v1beta1.RuleWithOperations{{
-					Operations: []v1beta1.OperationType{v1beta1.Create, v1beta1.Update},
+					Operations: []v1beta1.OperationType{v1beta1.Create},
 					Rule: v1beta1.Rule{
-						APIGroups:   []string{"alpha.network.k8s.io"},
+						APIGroups:   []string{""},
 						APIVersions: []string{"v1"},
-						Resources:   []string{"logicalnetworks"},
+						Resources:   []string{"pods"},
 					},
 				}},
+				FailurePolicy: &failurePolicy,
 				ClientConfig: v1beta1.WebhookClientConfig{
 					Service: &v1beta1.ServiceReference{
 						Namespace: "kube-system",
 						Name:      "genie-network-admission-controller",
+						Path:      &path,
 					},
 					CABundle: caCert,
 				},
@@ -115,5 +198,5 @@ func selfRegistration(clientset *kubernetes.Clientset, caCert []byte) {
 	if _, err := client.Create(webhookConfig); err != nil {
 		glog.Fatal(err)
 	}
-	glog.Info("selfRegistration completed")
+	glog.Info("selfRegistrationMutating completed")
 }
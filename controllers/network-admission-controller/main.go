@@ -22,9 +22,11 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"github.com/cni-genie/CNI-Genie/networkcrd"
 	genieUtils "github.com/cni-genie/CNI-Genie/utils"
 	"github.com/golang/glog"
 	"k8s.io/api/admission/v1beta1"
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -37,29 +39,55 @@ func admit(data []byte) *v1beta1.AdmissionResponse {
 		return nil
 	}
 	// The externalAdmissionHookConfiguration registered via selfRegistration
-	// asks the kube-apiserver only sends admission request regarding logical networks.
+	// asks the kube-apiserver only sends admission request regarding logical
+	// networks, network attachment definitions, and pods if their validation
+	// is enabled.
 	logicalNwResource := metav1.GroupVersionResource{Group: "alpha.network.k8s.io", Version: "v1", Resource: "logicalnetworks"}
-	if ar.Request.Resource != logicalNwResource {
-		glog.Errorf("expect resource to be %s", logicalNwResource)
-		return nil
-	}
+	netAttachDefResource := metav1.GroupVersionResource{Group: "k8s.cni.cncf.io", Version: "v1", Resource: "network-attachment-definitions"}
+	podResource := metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
 
 	raw := ar.Request.Object.Raw
 
-	logicalNw := genieUtils.LogicalNetwork{}
-	if err := json.Unmarshal(raw, &logicalNw); err != nil {
-		glog.Error(err)
+	var admissionResponse *v1beta1.AdmissionResponse
+	switch ar.Request.Resource {
+	case logicalNwResource:
+		logicalNw := genieUtils.LogicalNetwork{}
+		if err := json.Unmarshal(raw, &logicalNw); err != nil {
+			glog.Error(err)
+			return nil
+		}
+		admissionResponse = validateNetworkParas(&logicalNw)
+	case netAttachDefResource:
+		netAttachDef := networkcrd.NetworkAttachmentDefinition{}
+		if err := json.Unmarshal(raw, &netAttachDef); err != nil {
+			glog.Error(err)
+			return nil
+		}
+		admissionResponse = validateNetAttachDef(&netAttachDef)
+	case podResource:
+		pod := v1.Pod{}
+		if err := json.Unmarshal(raw, &pod); err != nil {
+			glog.Error(err)
+			return nil
+		}
+		admissionResponse = validatePod(&pod, ar.Request.Namespace)
+	default:
+		glog.Errorf("expect resource to be %s, %s or %s", logicalNwResource, netAttachDefResource, podResource)
 		return nil
 	}
 
-	admissionResponse := validateNetworkParas(&logicalNw)
+	admissionResponse.UID = ar.Request.UID
 	glog.Infof("Admission controller returned response: %v", admissionResponse)
 	return admissionResponse
 }
 
-// Will be called whenever user create logical network object
+// Will be called whenever user create logical network object, network attachment definition or pod
 func serve(w http.ResponseWriter, r *http.Request) {
-	glog.Info("Admission controller has been called for logical network event")
+	glog.Info("Admission controller has been called for network event")
+	serveReview(w, r, admit)
+}
+
+func serveReview(w http.ResponseWriter, r *http.Request, review func([]byte) *v1beta1.AdmissionResponse) {
 	var body []byte
 	if r.Body != nil {
 		if data, err := ioutil.ReadAll(r.Body); err == nil {
@@ -74,7 +102,7 @@ func serve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	reviewStatus := admit(body)
+	reviewStatus := review(body)
 	ar := v1beta1.AdmissionReview{
 		Response: reviewStatus,
 	}
@@ -89,14 +117,29 @@ func serve(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	initURLs()
+	initPodValidation()
+	initResourceInjection()
 	flag.Parse()
 	http.HandleFunc("/", serve)
-	initURLs()
 	clientset := getClient()
+	if validatePodAnnotations {
+		netLookup = newNetworkLookup(clientset, getNetClient())
+	}
+	if injectDeviceResources {
+		injector := &resourceInjector{netClient: getNetClient()}
+		http.HandleFunc(mutatePath, func(w http.ResponseWriter, r *http.Request) {
+			glog.Info("Admission controller has been called for pod mutation")
+			serveReview(w, r, injector.mutate)
+		})
+	}
 	server := &http.Server{
 		Addr:      ":8000",
 		TLSConfig: configTLS(clientset),
 	}
 	go selfRegistration(clientset, caCert)
+	if injectDeviceResources {
+		go selfRegistrationMutating(clientset, caCert)
+	}
 	server.ListenAndServeTLS("", "")
 }
@@ -0,0 +1,42 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	it "github.com/cni-genie/CNI-Genie/interfaces"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	"github.com/golang/glog"
+	"k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Validate network attachment definition being created or updated, so that
+// a broken plugin configuration is rejected instead of failing pods on the nodes
+func validateNetAttachDef(netAttachDef *networkcrd.NetworkAttachmentDefinition) *v1beta1.AdmissionResponse {
+	admissionResponse := v1beta1.AdmissionResponse{Allowed: true}
+
+	if err := networkcrd.ValidateNetworkAttachmentDefinition(netAttachDef, &it.Cni{}); err != nil {
+		glog.Infof("Rejecting network attachment definition %s/%s: %v", netAttachDef.Namespace, netAttachDef.Name, err)
+		admissionResponse.Allowed = false
+		admissionResponse.Result = &metav1.Status{
+			Message: fmt.Sprintf("Invalid network attachment definition %s: %v", netAttachDef.Name, err),
+		}
+	}
+	return &admissionResponse
+}
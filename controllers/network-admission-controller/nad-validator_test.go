@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateNetAttachDef(t *testing.T) {
+	tests := []struct {
+		config          string
+		expectedAllowed bool
+		expectedMessage string
+	}{
+		{
+			// The configuration is taken from the conf dir of the nodes
+			config:          "",
+			expectedAllowed: true,
+		},
+		{
+			config:          `{"cniVersion": "0.3.1", "type": "macvlan", "master": "eth1", "capabilities": {"mac": true, "ips": true}}`,
+			expectedAllowed: true,
+		},
+		{
+			config:          `{"cniVersion": "0.3.1", "name": "macvlan-net", "plugins": [{"type": "macvlan"}, {"type": "portmap", "capabilities": {"portMappings": true}}]}`,
+			expectedAllowed: true,
+		},
+		{
+			config:          `{"cniVersion": "0.3.1", "type": "macvlan"`,
+			expectedAllowed: false,
+			expectedMessage: "Error parsing plugin configuration data",
+		},
+		{
+			config:          `{"cniVersion": "0.3.1", "name": "other-net", "type": "macvlan"}`,
+			expectedAllowed: false,
+			expectedMessage: `Name "other-net" of plugin configuration does not match`,
+		},
+		{
+			config:          `{"cniVersion": "0.3.1", "master": "eth1"}`,
+			expectedAllowed: false,
+			expectedMessage: "type",
+		},
+		{
+			config:          `{"cniVersion": "0.3.1", "plugins": []}`,
+			expectedAllowed: false,
+			expectedMessage: "no plugins",
+		},
+		{
+			config:          `{"cniVersion": "0.3.1", "plugins": [{"type": "macvlan"}, {"type": "portmap", "capabilities": {"portMapping": true}}]}`,
+			expectedAllowed: false,
+			expectedMessage: `Unknown capability "portMapping" in configuration of plugin portmap`,
+		},
+	}
+
+	resource := metav1.GroupVersionResource{Group: "k8s.cni.cncf.io", Version: "v1", Resource: "network-attachment-definitions"}
+	for i := range tests {
+		netAttachDef := &networkcrd.NetworkAttachmentDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "macvlan-net", Namespace: "tenant1"},
+			Spec:       networkcrd.NetworkAttachmentDefinitionSpec{Config: tests[i].config},
+		}
+		resp := admit(admissionReview(t, resource, netAttachDef))
+		if resp == nil {
+			t.Fatalf("Test %d: no admission response", i)
+		}
+		if resp.Allowed != tests[i].expectedAllowed || resp.UID != "uid1" {
+			t.Errorf("Test %d: expected allowed: %v; got: %+v", i, tests[i].expectedAllowed, resp)
+		}
+		if tests[i].expectedMessage != "" && (resp.Result == nil || !strings.Contains(resp.Result.Message, tests[i].expectedMessage)) {
+			t.Errorf("Test %d: expected message containing %q; got: %+v", i, tests[i].expectedMessage, resp.Result)
+		}
+	}
+}
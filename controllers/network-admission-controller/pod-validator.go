@@ -0,0 +1,286 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	netattachclient "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	genieUtils "github.com/cni-genie/CNI-Genie/utils"
+	"github.com/golang/glog"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	namespaceIsolation     bool
+	globalNamespaces       string
+	validatePodAnnotations bool
+	knownPlugins           string
+)
+
+// netLookup looks up the networks referred to by pods, it is set only when
+// annotations of pods are validated
+var netLookup *networkLookup
+
+func initPodValidation() {
+	flag.BoolVar(&namespaceIsolation, "namespace-isolation", false, "Reject pods referring to network attachment definitions of namespaces other than their own and the global namespaces. Should match namespace_isolation of genie configuration.")
+	flag.StringVar(&globalNamespaces, "global-namespaces", "", "Comma separated namespaces whose network attachment definitions may be referred to by the pods of any namespace with namespace isolation. Should match global_namespaces of genie configuration.")
+	flag.BoolVar(&validatePodAnnotations, "validate-pod-annotations", false, "Reject pods with malformed network selection annotations, or referring to networks which do not exist or to plugins which are not known.")
+	flag.StringVar(&knownPlugins, "known-plugins", genie.SupportedPlugins, "Comma separated plugins which pods may select through the cni annotation, including the networks configured on the nodes. Plugins are not checked if empty.")
+}
+
+// podValidationEnabled tells whether pods are to be sent to the admission controller
+func podValidationEnabled() bool {
+	return namespaceIsolation || validatePodAnnotations
+}
+
+// networkLookup gets network attachment definitions and logical networks
+type networkLookup struct {
+	netClient         netattachclient.Interface
+	getLogicalNetwork func(name, namespace string) (*genieUtils.LogicalNetwork, error)
+}
+
+func newNetworkLookup(clientset kubernetes.Interface, netClient netattachclient.Interface) *networkLookup {
+	getLogicalNetwork := func(name, namespace string) (*genieUtils.LogicalNetwork, error) {
+		logicalNwPath := fmt.Sprintf("/apis/alpha.network.k8s.io/v1/namespaces/%s/logicalnetworks/%s", namespace, name)
+		data, err := clientset.ExtensionsV1beta1().RESTClient().Get().AbsPath(logicalNwPath).DoRaw()
+		if err != nil {
+			return nil, err
+		}
+		logicalNw := &genieUtils.LogicalNetwork{}
+		if err = json.Unmarshal(data, logicalNw); err != nil {
+			return nil, err
+		}
+		return logicalNw, nil
+	}
+	return &networkLookup{netClient: netClient, getLogicalNetwork: getLogicalNetwork}
+}
+
+// Validate network selection annotations of a pod being created in namespace
+func validatePod(pod *v1.Pod, namespace string) *v1beta1.AdmissionResponse {
+	admissionResponse := v1beta1.AdmissionResponse{Allowed: true}
+	if pod.Namespace == "" {
+		pod.Namespace = namespace
+	}
+
+	err := checkNamespaceIsolation(pod)
+	if err == nil {
+		err = checkAnnotations(pod)
+	}
+	if err != nil {
+		admissionResponse.Allowed = false
+		admissionResponse.Result = &metav1.Status{
+			Message: err.Error(),
+		}
+	}
+	return &admissionResponse
+}
+
+// checkNamespaceIsolation checks that the network attachment definitions
+// referred to by the pod are allowed with namespace isolation, the same way
+// genie does on the node
+func checkNamespaceIsolation(pod *v1.Pod) error {
+	if !namespaceIsolation {
+		return nil
+	}
+	var global []string
+	for _, ns := range strings.Split(globalNamespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			global = append(global, ns)
+		}
+	}
+
+	if annot := strings.TrimSpace(pod.Annotations[genie.NetworkAttachmentDefinitionAnnot]); annot != "" {
+		networks, err := networkcrd.GetNetworkInfo(annot, pod.Namespace)
+		if err != nil {
+			return fmt.Errorf("Error parsing network selection annotation: %v", err)
+		}
+		if err = networkcrd.CheckNamespaceIsolation(networks, pod.Namespace, global); err != nil {
+			return err
+		}
+	}
+
+	ref := strings.TrimSpace(pod.Annotations[genie.DefaultNetworkAnnot])
+	if i := strings.Index(ref, "/"); i >= 0 && !networkcrd.NamespaceAllowed(ref[:i], pod.Namespace, global) {
+		return fmt.Errorf("Network attachment definition %s in %s annotation is not allowed for pods of namespace %s with namespace isolation", ref, genie.DefaultNetworkAnnot, pod.Namespace)
+	}
+	return nil
+}
+
+// checkAnnotations checks the network selection annotations of the pod which
+// genie would otherwise reject only on the node: the networks selected must
+// exist, the plugins selected must be known and no interface name may be
+// requested twice. The annotations are looked at in the order genie does: the
+// networks annotation is only looked at with an empty cni annotation.
+func checkAnnotations(pod *v1.Pod) error {
+	if !validatePodAnnotations {
+		return nil
+	}
+	annot := pod.Annotations
+	_, nadAnnot := annot[genie.NetworkAttachmentDefinitionAnnot]
+	_, defaultNetwork := annot[genie.DefaultNetworkAnnot]
+	_, cni := annot["cni"]
+
+	var ifNames []string
+	var err error
+	switch {
+	case !nadAnnot && defaultNetwork && cni:
+		return fmt.Errorf("Annotation %s cannot be used along with the cni annotation", genie.DefaultNetworkAnnot)
+	case nadAnnot || defaultNetwork:
+		ifNames, err = checkNetAttachDefs(pod)
+	case !cni:
+		// Genie attaches its default plugins, ignoring the networks annotation
+	case strings.TrimSpace(annot["cni"]) != "":
+		ifNames, err = checkPlugins(annot["cni"])
+	case strings.TrimSpace(annot["networks"]) != "":
+		ifNames, err = checkLogicalNetworks(annot["networks"], pod.Namespace)
+	}
+	if err != nil {
+		return err
+	}
+
+	requested := make(map[string]bool)
+	for _, ifName := range ifNames {
+		if ifName == "" {
+			continue
+		}
+		if requested[ifName] {
+			return fmt.Errorf("Repeated request for same interface name: %s", ifName)
+		}
+		requested[ifName] = true
+	}
+	return nil
+}
+
+// checkNetAttachDefs checks the network attachment definitions selected by
+// the pod, including its default network, and returns the interface names
+// requested for them
+func checkNetAttachDefs(pod *v1.Pod) ([]string, error) {
+	var selected []networkcrd.NetworkSelectionElement
+	if annot := strings.TrimSpace(pod.Annotations[genie.NetworkAttachmentDefinitionAnnot]); annot != "" {
+		var err error
+		selected, err = networkcrd.GetNetworkInfo(annot, pod.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing network selection annotation: %v", err)
+		}
+	}
+
+	// The default network of the pod is always attached as eth0
+	ifNames := []string{genie.DefaultIfNamePrefix + "0"}
+	if ref, ok := pod.Annotations[genie.DefaultNetworkAnnot]; ok {
+		network := networkcrd.NetworkSelectionElement{Namespace: pod.Namespace, Name: strings.TrimSpace(ref)}
+		if i := strings.Index(network.Name, "/"); i >= 0 {
+			network.Namespace, network.Name = network.Name[:i], network.Name[i+1:]
+		}
+		if network.Namespace == "" || network.Name == "" {
+			return nil, fmt.Errorf("Invalid network attachment definition %q in %s annotation", ref, genie.DefaultNetworkAnnot)
+		}
+		if err := netLookup.checkNetAttachDef(network); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, network := range selected {
+		if err := netLookup.checkNetAttachDef(network); err != nil {
+			return nil, err
+		}
+		ifNames = append(ifNames, network.Interface)
+	}
+	return ifNames, nil
+}
+
+// checkPlugins checks the plugins selected through the cni annotation and
+// returns the interface names requested for them
+func checkPlugins(annot string) ([]string, error) {
+	var ifNames []string
+	for _, plugin := range strings.Split(annot, ",") {
+		plugin = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(plugin), genieUtils.OptionalNetworkSuffix))
+		if i := strings.Index(plugin, genieUtils.IfNameDelimiter); i >= 0 {
+			ifNames = append(ifNames, strings.TrimSpace(plugin[i+1:]))
+			plugin = strings.TrimSpace(plugin[:i])
+		}
+		if plugin == "" {
+			return nil, fmt.Errorf("Empty plugin name in cni annotation %q", annot)
+		}
+		if !pluginKnown(plugin) {
+			return nil, fmt.Errorf("Plugin %s in cni annotation is not known. Known plugins are %s", plugin, knownPlugins)
+		}
+	}
+	return ifNames, nil
+}
+
+// checkLogicalNetworks checks the logical networks selected through the
+// networks annotation and returns the interface names requested for them
+func checkLogicalNetworks(annot, namespace string) ([]string, error) {
+	var ifNames []string
+	for _, name := range strings.Split(annot, ",") {
+		name = strings.TrimSpace(name)
+		if i := strings.Index(name, genieUtils.IfNameDelimiter); i >= 0 {
+			ifNames = append(ifNames, strings.TrimSpace(name[i+1:]))
+			name = strings.TrimSpace(name[:i])
+		}
+		if name == "" {
+			return nil, fmt.Errorf("Empty logical network name in networks annotation %q", annot)
+		}
+		logicalNw, err := netLookup.getLogicalNetwork(name, namespace)
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("Logical network %s/%s does not exist", namespace, name)
+		}
+		if err != nil {
+			glog.Warningf("Error getting logical network %s/%s: %v", namespace, name, err)
+			continue
+		}
+		if plugin := logicalNw.Spec.Plugin; plugin != "" && logicalNw.Spec.PhysicalNet == "" && !pluginKnown(plugin) {
+			return nil, fmt.Errorf("Plugin %s of logical network %s/%s is not known. Known plugins are %s", plugin, namespace, name, knownPlugins)
+		}
+	}
+	return ifNames, nil
+}
+
+// checkNetAttachDef checks that a network attachment definition exists. The
+// pod is not rejected if it could not be found out.
+func (nl *networkLookup) checkNetAttachDef(network networkcrd.NetworkSelectionElement) error {
+	_, err := nl.netClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(network.Namespace).Get(network.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return fmt.Errorf("Network attachment definition %s/%s does not exist", network.Namespace, network.Name)
+	}
+	if err != nil {
+		glog.Warningf("Error getting network attachment definition %s/%s: %v", network.Namespace, network.Name, err)
+	}
+	return nil
+}
+
+func pluginKnown(plugin string) bool {
+	if strings.TrimSpace(knownPlugins) == "" {
+		return true
+	}
+	for _, known := range strings.Split(knownPlugins, ",") {
+		if strings.EqualFold(strings.TrimSpace(known), plugin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
+	netattachfake "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned/fake"
+	"github.com/cni-genie/CNI-Genie/genie"
+	genieUtils "github.com/cni-genie/CNI-Genie/utils"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func admissionReview(t *testing.T, resource metav1.GroupVersionResource, obj interface{}) []byte {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Error marshalling %s: %v", resource.Resource, err)
+	}
+	review, err := json.Marshal(&v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			UID:       "uid1",
+			Resource:  resource,
+			Namespace: "tenant1",
+			Operation: v1beta1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error marshalling admission review: %v", err)
+	}
+	return review
+}
+
+func podReview(t *testing.T, annot map[string]string) []byte {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "testpod", Annotations: annot}}
+	return admissionReview(t, metav1.GroupVersionResource{Version: "v1", Resource: "pods"}, pod)
+}
+
+func TestValidatePodNamespaceIsolation(t *testing.T) {
+	tests := []struct {
+		annot            map[string]string
+		isolation        bool
+		globalNamespaces string
+		expectedAllowed  bool
+		expectedMessage  string
+	}{
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": "tenant2/macvlan-net"},
+			isolation:       false,
+			expectedAllowed: true,
+		},
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": "macvlan-net, tenant1/sriov-net@net2"},
+			isolation:       true,
+			expectedAllowed: true,
+		},
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": "macvlan-net, tenant2/sriov-net"},
+			isolation:       true,
+			expectedAllowed: false,
+			expectedMessage: "tenant2/sriov-net is not allowed for pods of namespace tenant1",
+		},
+		{
+			annot:            map[string]string{"k8s.v1.cni.cncf.io/networks": `[{"name": "sriov-net", "namespace": "shared"}]`},
+			isolation:        true,
+			globalNamespaces: "kube-system, shared",
+			expectedAllowed:  true,
+		},
+		{
+			annot:            map[string]string{"cni-default-network": "tenant2/sriov-net"},
+			isolation:        true,
+			globalNamespaces: "shared",
+			expectedAllowed:  false,
+			expectedMessage:  "cni-default-network annotation is not allowed",
+		},
+	}
+
+	defer func() { namespaceIsolation, globalNamespaces = false, "" }()
+	for i := range tests {
+		namespaceIsolation, globalNamespaces = tests[i].isolation, tests[i].globalNamespaces
+		resp := admit(podReview(t, tests[i].annot))
+		if resp == nil {
+			t.Fatalf("Test %d: no admission response", i)
+		}
+		if resp.Allowed != tests[i].expectedAllowed || resp.UID != "uid1" {
+			t.Errorf("Test %d: expected allowed: %v; got: %+v", i, tests[i].expectedAllowed, resp)
+		}
+		if tests[i].expectedMessage != "" && (resp.Result == nil || !strings.Contains(resp.Result.Message, tests[i].expectedMessage)) {
+			t.Errorf("Test %d: expected message containing %q; got: %+v", i, tests[i].expectedMessage, resp.Result)
+		}
+	}
+}
+
+func TestValidatePodAnnotations(t *testing.T) {
+	netClient := netattachfake.NewSimpleClientset()
+	for _, nad := range []struct{ namespace, name string }{
+		{"tenant1", "macvlan-net"},
+		{"tenant1", "sriov-net"},
+		{"shared", "storage-net"},
+	} {
+		netClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nad.namespace).Create(&netattachv1.NetworkAttachmentDefinition{ObjectMeta: metav1.ObjectMeta{Name: nad.name, Namespace: nad.namespace}})
+	}
+	// Plugins of the logical networks
+	logicalNetworks := map[string]string{
+		"tenant1/frontend": "calico",
+		"tenant1/legacy":   "contiv",
+	}
+	getLogicalNetwork := func(name, namespace string) (*genieUtils.LogicalNetwork, error) {
+		plugin, ok := logicalNetworks[namespace+"/"+name]
+		if !ok {
+			return nil, errors.NewNotFound(schema.GroupResource{Group: "alpha.network.k8s.io", Resource: "logicalnetworks"}, name)
+		}
+		logicalNw := &genieUtils.LogicalNetwork{}
+		logicalNw.Spec.Plugin = plugin
+		return logicalNw, nil
+	}
+
+	tests := []struct {
+		annot           map[string]string
+		expectedAllowed bool
+		expectedMessage string
+	}{
+		{
+			annot:           nil,
+			expectedAllowed: true,
+		},
+		{
+			annot:           map[string]string{"cni": ""},
+			expectedAllowed: true,
+		},
+		{
+			annot:           map[string]string{"cni": "weave, flannel@eth1, Romana?"},
+			expectedAllowed: true,
+		},
+		{
+			annot:           map[string]string{"cni": "weave, contiv"},
+			expectedAllowed: false,
+			expectedMessage: "Plugin contiv in cni annotation is not known",
+		},
+		{
+			annot:           map[string]string{"cni": "weave@eth1, flannel@eth1"},
+			expectedAllowed: false,
+			expectedMessage: "Repeated request for same interface name: eth1",
+		},
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": "macvlan-net@net1, shared/storage-net, sriov-net"},
+			expectedAllowed: true,
+		},
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": `[{"name": "macvlan-net", "interface": "net1"}, {"name": "sriov-net", "interface": "net1"}]`},
+			expectedAllowed: false,
+			expectedMessage: "Repeated request for same interface name: net1",
+		},
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": "macvlan-net@eth0"},
+			expectedAllowed: false,
+			expectedMessage: "Repeated request for same interface name: eth0",
+		},
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": "macvlan-net, tenant2/storage-net"},
+			expectedAllowed: false,
+			expectedMessage: "Network attachment definition tenant2/storage-net does not exist",
+		},
+		{
+			annot:           map[string]string{"k8s.v1.cni.cncf.io/networks": `[{"name": "macvlan-net"`},
+			expectedAllowed: false,
+			expectedMessage: "Error parsing network selection annotation",
+		},
+		{
+			annot:           map[string]string{"cni-default-network": "shared/missing-net"},
+			expectedAllowed: false,
+			expectedMessage: "Network attachment definition shared/missing-net does not exist",
+		},
+		{
+			annot:           map[string]string{"cni-default-network": "shared/missing-net", "cni": "weave"},
+			expectedAllowed: false,
+			expectedMessage: "Annotation cni-default-network cannot be used along with the cni annotation",
+		},
+		{
+			annot:           map[string]string{"cni": "", "networks": "frontend@eth1"},
+			expectedAllowed: true,
+		},
+		{
+			annot:           map[string]string{"cni": "", "networks": "frontend, backend"},
+			expectedAllowed: false,
+			expectedMessage: "Logical network tenant1/backend does not exist",
+		},
+		{
+			annot:           map[string]string{"cni": "", "networks": "legacy"},
+			expectedAllowed: false,
+			expectedMessage: "Plugin contiv of logical network tenant1/legacy is not known",
+		},
+		{
+			// The networks annotation is ignored without the cni annotation
+			annot:           map[string]string{"networks": "frontend, backend"},
+			expectedAllowed: true,
+		},
+	}
+
+	validatePodAnnotations, knownPlugins = true, genie.SupportedPlugins
+	netLookup = &networkLookup{netClient: netClient, getLogicalNetwork: getLogicalNetwork}
+	defer func() { validatePodAnnotations, netLookup = false, nil }()
+	for i := range tests {
+		resp := admit(podReview(t, tests[i].annot))
+		if resp == nil {
+			t.Fatalf("Test %d: no admission response", i)
+		}
+		if resp.Allowed != tests[i].expectedAllowed {
+			t.Errorf("Test %d: expected allowed: %v; got: %+v", i, tests[i].expectedAllowed, resp)
+		}
+		if tests[i].expectedMessage != "" && (resp.Result == nil || !strings.Contains(resp.Result.Message, tests[i].expectedMessage)) {
+			t.Errorf("Test %d: expected message containing %q; got: %+v", i, tests[i].expectedMessage, resp.Result)
+		}
+	}
+}
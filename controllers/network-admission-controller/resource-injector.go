@@ -0,0 +1,185 @@
+/*
+Copyright 2017 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"sort"
+	"strings"
+
+	netattachclient "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	"github.com/golang/glog"
+	"k8s.io/api/admission/v1beta1"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var injectDeviceResources bool
+
+func initResourceInjection() {
+	flag.BoolVar(&injectDeviceResources, "inject-device-resources", false, "Add requests of the device resources of the network attachment definitions selected by pods to their first container.")
+}
+
+// resourceInjector adds to pods the requests of the device resources (eg:
+// intel.com/sriov) of the network attachment definitions they select, so
+// that the kubelet allocates one device for each of their attachments
+type resourceInjector struct {
+	netClient netattachclient.Interface
+}
+
+// Mutate pod being created
+func (ri *resourceInjector) mutate(data []byte) *v1beta1.AdmissionResponse {
+	ar := v1beta1.AdmissionReview{}
+	if err := json.Unmarshal(data, &ar); err != nil {
+		glog.Error(err)
+		return nil
+	}
+	podResource := metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+	if ar.Request.Resource != podResource {
+		glog.Errorf("expect resource to be %s", podResource)
+		return nil
+	}
+	pod := v1.Pod{}
+	if err := json.Unmarshal(ar.Request.Object.Raw, &pod); err != nil {
+		glog.Error(err)
+		return nil
+	}
+
+	// The pod is never rejected here, broken annotations fail on validation
+	// or on the node
+	admissionResponse := v1beta1.AdmissionResponse{UID: ar.Request.UID, Allowed: true}
+	devices, err := ri.deviceResources(&pod, ar.Request.Namespace)
+	if err != nil {
+		glog.Errorf("Not injecting device resources into pod %s/%s: %v", ar.Request.Namespace, pod.Name, err)
+		return &admissionResponse
+	}
+	patch, err := resourcePatch(&pod, devices)
+	if err != nil {
+		glog.Errorf("Not injecting device resources into pod %s/%s: %v", ar.Request.Namespace, pod.Name, err)
+		return &admissionResponse
+	}
+	if patch != nil {
+		glog.Infof("Injecting device resources %v into pod %s/%s", devices, ar.Request.Namespace, pod.Name)
+		patchType := v1beta1.PatchTypeJSONPatch
+		admissionResponse.PatchType = &patchType
+		admissionResponse.Patch = patch
+	}
+	return &admissionResponse
+}
+
+// deviceResources counts the devices of each resource needed by the
+// attachments of the pod, the way genie assigns them on the node: one for
+// each attachment to a network of the resource, unless its device is given
+// in the annotation
+func (ri *resourceInjector) deviceResources(pod *v1.Pod, namespace string) (map[string]int64, error) {
+	var networks []networkcrd.NetworkSelectionElement
+	annot := strings.TrimSpace(pod.Annotations[genie.NetworkAttachmentDefinitionAnnot])
+	if annot != "" {
+		var err error
+		networks, err = networkcrd.GetNetworkInfo(annot, namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// A pod with the default network along with the cni annotation fails to
+	// start, it needs no device
+	_, cni := pod.Annotations["cni"]
+	if ref := strings.TrimSpace(pod.Annotations[genie.DefaultNetworkAnnot]); ref != "" && (annot != "" || !cni) {
+		network := networkcrd.NetworkSelectionElement{Namespace: namespace, Name: ref}
+		if i := strings.Index(ref, "/"); i >= 0 {
+			network.Namespace, network.Name = ref[:i], ref[i+1:]
+		}
+		networks = append(networks, network)
+	}
+
+	devices := map[string]int64{}
+	for _, network := range networks {
+		if network.DeviceID != "" {
+			continue
+		}
+		netAttachDef, err := ri.netClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(network.Namespace).Get(network.Name, metav1.GetOptions{})
+		if err != nil {
+			glog.Warningf("Error getting network attachment definition %s/%s: %v", network.Namespace, network.Name, err)
+			continue
+		}
+		if resourceName := strings.TrimSpace(netAttachDef.Annotations[networkcrd.ResourceNameAnnot]); resourceName != "" {
+			devices[resourceName]++
+		}
+	}
+	return devices, nil
+}
+
+// resourcePatch builds the json patch adding to the first container of the
+// pod the devices it does not request yet in any of its containers. Device
+// resources cannot be overcommitted, so requests and limits are the same.
+func resourcePatch(pod *v1.Pod, devices map[string]int64) ([]byte, error) {
+	if len(pod.Spec.Containers) == 0 || len(devices) == 0 {
+		return nil, nil
+	}
+	resources := pod.Spec.Containers[0].Resources.DeepCopy()
+	if resources.Requests == nil {
+		resources.Requests = v1.ResourceList{}
+	}
+	if resources.Limits == nil {
+		resources.Limits = v1.ResourceList{}
+	}
+
+	var names []string
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	changed := false
+	for _, name := range names {
+		resourceName := v1.ResourceName(name)
+		var requested int64
+		for _, container := range pod.Spec.Containers {
+			requested += requestedQuantity(&container.Resources, resourceName)
+		}
+		if requested >= devices[name] {
+			continue
+		}
+		quantity := *resource.NewQuantity(requestedQuantity(resources, resourceName)+devices[name]-requested, resource.DecimalSI)
+		resources.Requests[resourceName] = quantity
+		resources.Limits[resourceName] = quantity
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+
+	// Adding an existing member replaces it
+	return json.Marshal([]map[string]interface{}{{
+		"op":    "add",
+		"path":  "/spec/containers/0/resources",
+		"value": resources,
+	}})
+}
+
+func requestedQuantity(resources *v1.ResourceRequirements, name v1.ResourceName) int64 {
+	if q, ok := resources.Limits[name]; ok {
+		return q.Value()
+	}
+	if q, ok := resources.Requests[name]; ok {
+		return q.Value()
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
+	netattachfake "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned/fake"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInjectDeviceResources(t *testing.T) {
+	netClient := netattachfake.NewSimpleClientset()
+	for _, nad := range []struct{ namespace, name, resourceName string }{
+		{"tenant1", "sriov-net1", "intel.com/sriov"},
+		{"tenant1", "sriov-net2", "intel.com/sriov"},
+		{"shared", "storage-net", "intel.com/sriov_storage"},
+		{"tenant1", "macvlan-net", ""},
+	} {
+		netAttachDef := &netattachv1.NetworkAttachmentDefinition{ObjectMeta: metav1.ObjectMeta{Name: nad.name, Namespace: nad.namespace}}
+		if nad.resourceName != "" {
+			netAttachDef.Annotations = map[string]string{networkcrd.ResourceNameAnnot: nad.resourceName}
+		}
+		netClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nad.namespace).Create(netAttachDef)
+	}
+	injector := &resourceInjector{netClient: netClient}
+
+	sriov := v1.ResourceName("intel.com/sriov")
+	tests := []struct {
+		annot     map[string]string
+		requested []int64
+		// expected quantities of the resources in the first container, nil if
+		// the pod is not patched
+		expected map[v1.ResourceName]int64
+	}{
+		{
+			annot:     nil,
+			requested: []int64{0},
+			expected:  nil,
+		},
+		{
+			annot:     map[string]string{"k8s.v1.cni.cncf.io/networks": "sriov-net1, macvlan-net, sriov-net2, missing-net"},
+			requested: []int64{0},
+			expected:  map[v1.ResourceName]int64{sriov: 2},
+		},
+		{
+			// Devices requested in any container are counted
+			annot:     map[string]string{"k8s.v1.cni.cncf.io/networks": "sriov-net1, sriov-net2"},
+			requested: []int64{0, 1},
+			expected:  map[v1.ResourceName]int64{sriov: 1},
+		},
+		{
+			annot:     map[string]string{"k8s.v1.cni.cncf.io/networks": "sriov-net1, sriov-net2"},
+			requested: []int64{2},
+			expected:  nil,
+		},
+		{
+			annot:     map[string]string{"k8s.v1.cni.cncf.io/networks": `[{"name": "sriov-net1"}, {"name": "sriov-net2", "deviceID": "0000:03:02.0"}]`},
+			requested: []int64{0},
+			expected:  map[v1.ResourceName]int64{sriov: 1},
+		},
+		{
+			annot:     map[string]string{"cni-default-network": "shared/storage-net", "k8s.v1.cni.cncf.io/networks": "sriov-net1"},
+			requested: []int64{0},
+			expected:  map[v1.ResourceName]int64{sriov: 1, "intel.com/sriov_storage": 1},
+		},
+		{
+			// The pod is rejected, no device is injected for its default network
+			annot:     map[string]string{"cni-default-network": "shared/storage-net", "cni": "weave"},
+			requested: []int64{0},
+			expected:  nil,
+		},
+	}
+
+	for i := range tests {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "testpod", Annotations: tests[i].annot}}
+		for n, requested := range tests[i].requested {
+			container := v1.Container{Name: fmt.Sprintf("container%d", n)}
+			if requested > 0 {
+				container.Resources.Limits = v1.ResourceList{sriov: *resource.NewQuantity(requested, resource.DecimalSI)}
+			}
+			pod.Spec.Containers = append(pod.Spec.Containers, container)
+		}
+
+		resp := injector.mutate(admissionReview(t, metav1.GroupVersionResource{Version: "v1", Resource: "pods"}, pod))
+		if resp == nil || !resp.Allowed || resp.UID != "uid1" {
+			t.Fatalf("Test %d: expected pod to be allowed; got: %+v", i, resp)
+		}
+		if tests[i].expected == nil {
+			if resp.Patch != nil {
+				t.Errorf("Test %d: expected no patch; got: %s", i, resp.Patch)
+			}
+			continue
+		}
+
+		var patch []struct {
+			Op    string
+			Path  string
+			Value v1.ResourceRequirements
+		}
+		if err := json.Unmarshal(resp.Patch, &patch); err != nil || len(patch) != 1 || patch[0].Path != "/spec/containers/0/resources" {
+			t.Fatalf("Test %d: unexpected patch %s: %v", i, resp.Patch, err)
+		}
+		for name, expected := range tests[i].expected {
+			limit, request := patch[0].Value.Limits[name], patch[0].Value.Requests[name]
+			if limit.Value() != expected || request.Value() != expected {
+				t.Errorf("Test %d: expected %d of %s; got requests: %v, limits: %v", i, expected, name, patch[0].Value.Requests, patch[0].Value.Limits)
+			}
+		}
+	}
+}
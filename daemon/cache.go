@@ -0,0 +1,103 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/client"
+	lnv1 "github.com/cni-genie/CNI-Genie/controllers/logicalnetwork-pkg/apis/alpha/network/v1"
+	lnclient "github.com/cni-genie/CNI-Genie/controllers/logicalnetwork-pkg/client/clientset/versioned"
+	lninformers "github.com/cni-genie/CNI-Genie/controllers/logicalnetwork-pkg/client/informers/externalversions"
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
+	netattachclient "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned"
+	netattachinformers "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/informers/externalversions"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"time"
+)
+
+// StartCache starts the informers for the pods of the node, the network
+// attachment definitions and the logical networks, and waits until they are
+// synced. Informers are not started for the resources not served by the api
+// server, eg: when their CRD is not installed, leaving those to be fetched
+// from the api server.
+func StartCache(config *rest.Config, nodeName string, resync time.Duration, stopCh <-chan struct{}) (*client.Cache, error) {
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("Error building kubernetes client: %v", err)
+	}
+	c := &client.Cache{}
+	var synced []cache.InformerSynced
+
+	// Only the pods of the node are ever looked up by the daemon of the node
+	kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, resync,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			if nodeName != "" {
+				options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+			}
+		}))
+	pods := kubeInformers.Core().V1().Pods()
+	c.Pods = pods.Lister()
+	synced = append(synced, pods.Informer().HasSynced)
+	kubeInformers.Start(stopCh)
+
+	if served(kubeClient.Discovery(), netattachv1.SchemeGroupVersion.String(), "network-attachment-definitions") {
+		netClient, err := netattachclient.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("Error building network attachment definition client: %v", err)
+		}
+		netInformers := netattachinformers.NewSharedInformerFactory(netClient, resync)
+		netAttachDefs := netInformers.K8sCniCncfIo().V1().NetworkAttachmentDefinitions()
+		c.NetAttachDefs = netAttachDefs.Lister()
+		synced = append(synced, netAttachDefs.Informer().HasSynced)
+		netInformers.Start(stopCh)
+	}
+
+	if served(kubeClient.Discovery(), lnv1.SchemeGroupVersion.String(), "logicalnetworks") {
+		lnClient, err := lnclient.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("Error building logical network client: %v", err)
+		}
+		lnInformers := lninformers.NewSharedInformerFactory(lnClient, resync)
+		logicalNetworks := lnInformers.Alpha().V1().LogicalNetworks()
+		c.LogicalNetworks = logicalNetworks.Lister()
+		synced = append(synced, logicalNetworks.Informer().HasSynced)
+		lnInformers.Start(stopCh)
+	}
+
+	logging.Infof("Waiting for the caches to sync")
+	if !cache.WaitForCacheSync(stopCh, synced...) {
+		return nil, fmt.Errorf("Error syncing caches: stopped")
+	}
+	return c, nil
+}
+
+// served tells whether the api server serves the resource in the group version
+func served(d discovery.DiscoveryInterface, groupVersion, resource string) bool {
+	resources, err := d.ServerResourcesForGroupVersion(groupVersion)
+	if err == nil {
+		for _, r := range resources.APIResources {
+			if r.Name == resource {
+				return true
+			}
+		}
+	}
+	logging.Warningf("Resource %s of %s is not served, it is not cached: %v", resource, groupVersion, err)
+	return false
+}
@@ -0,0 +1,92 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Bounds of the requests of the plugin to the daemon, so that a wedged daemon
+// fails the invocation with a clear error instead of hanging until the
+// runtime gives up on the plugin
+const (
+	DefaultDialTimeout    = 5 * time.Second
+	DefaultRequestTimeout = 2 * time.Minute
+)
+
+// Forward sends the cni command invoked with args to the daemon listening on
+// socket. For ADD, the result printed by the daemon is returned. Errors of
+// the daemon are returned as *types.Error, to be reported to the runtime as is.
+func Forward(socket, command string, args *skel.CmdArgs) ([]byte, error) {
+	return post(socket, CNIPath, NewRequest(command, args), DefaultRequestTimeout)
+}
+
+// Resolve asks the daemon listening on socket for the attachments of the
+// container invoked with args, without attaching any
+func Resolve(socket string, args *skel.CmdArgs) ([]Attachment, error) {
+	out, err := post(socket, ResolvePath, NewRequest("", args), DefaultRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var attachments []Attachment
+	if err = json.Unmarshal(out, &attachments); err != nil {
+		return nil, fmt.Errorf("Error decoding attachments from genie daemon: %v", err)
+	}
+	return attachments, nil
+}
+
+// post sends the request to the daemon, failing if the daemon cannot be
+// connected to within DefaultDialTimeout or does not respond within timeout
+func post(socket, path string, req *Request, timeout time.Duration) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding request to genie daemon: %v", err)
+	}
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{Timeout: DefaultDialTimeout}).DialContext(ctx, "unix", socket)
+			},
+		},
+		Timeout: timeout,
+	}
+	resp, err := httpClient.Post("http://genie"+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Error contacting genie daemon at %s: %v", socket, err)
+	}
+	defer resp.Body.Close()
+	out, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response of genie daemon: %v", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return out, nil
+	case http.StatusInternalServerError:
+		e := &types.Error{}
+		if err = json.Unmarshal(out, e); err == nil {
+			return nil, e
+		}
+	}
+	return nil, fmt.Errorf("Error from genie daemon (%s): %s", resp.Status, bytes.TrimSpace(out))
+}
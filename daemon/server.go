@@ -0,0 +1,289 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/client"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"github.com/containernetworking/cni/pkg/types"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Server serves the requests of the genie plugin
+type Server struct {
+	// Kc is shared by all the requests. Its cache is filled by the informers
+	// of the daemon. It is not used for the requests in standalone mode.
+	Kc *client.KubeClient
+
+	// containers serializes the requests for the same container, eg: a DEL
+	// arriving while the ADD of the container is still running. Requests for
+	// different containers are served concurrently, GC alone.
+	containers containerLocks
+}
+
+type containerLocks struct {
+	// all is held for reading by the requests for a container, and for
+	// writing by the requests for all of them
+	all   sync.RWMutex
+	mu    sync.Mutex
+	locks map[string]*containerLock
+}
+
+type containerLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the container and returns the function unlocking it
+func (c *containerLocks) lock(containerID string) func() {
+	c.all.RLock()
+	c.mu.Lock()
+	if c.locks == nil {
+		c.locks = make(map[string]*containerLock)
+	}
+	l := c.locks[containerID]
+	if l == nil {
+		l = &containerLock{}
+		c.locks[containerID] = l
+	}
+	l.refs++
+	c.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		c.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(c.locks, containerID)
+		}
+		c.mu.Unlock()
+		c.all.RUnlock()
+	}
+}
+
+// lockAll locks all the containers, waiting for the requests in progress,
+// and returns the function unlocking them
+func (c *containerLocks) lockAll() func() {
+	c.all.Lock()
+	return c.all.Unlock
+}
+
+// Handler returns the handler of the requests of the plugin
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(CNIPath, s.handleCNI)
+	mux.HandleFunc(ResolvePath, s.handleResolve)
+	mux.HandleFunc(HealthzPath, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+// Serve listens on the unix socket at path and serves the requests until
+// stopCh is closed. A socket left behind by an earlier daemon is replaced.
+func (s *Server) Serve(path string, stopCh <-chan struct{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("Error creating directory of socket %s: %v", path, err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Error removing stale socket %s: %v", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("Error listening on socket %s: %v", path, err)
+	}
+	// Only root on the node, ie: the runtime invoking the plugin, may connect
+	if err = os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("Error setting permissions of socket %s: %v", path, err)
+	}
+
+	server := &http.Server{Handler: s.Handler()}
+	go func() {
+		<-stopCh
+		server.Close()
+	}()
+	logging.Infof("Serving genie requests on %s", path)
+	if err = server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleCNI(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	out, err := s.run(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(out)
+}
+
+func (s *Server) handleResolve(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRequest(w, r)
+	if !ok {
+		return
+	}
+	attachments, err := s.resolve(req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachments)
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request) (*Request, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("Method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	req := &Request{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+	return req, true
+}
+
+// writeError replies with the error in the format printed by cni plugins,
+// for the shim to pass it on to the runtime
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*types.Error)
+	if !ok {
+		e = &types.Error{Code: types.ErrUnknown, Msg: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(e)
+}
+
+// setup prepares the controller for a request in the same way as the plugin
+// does for an invocation, except for logging which is configured once for
+// the daemon. The fields of the request are carried by the logger of the
+// controller, as requests are served concurrently.
+func (s *Server) setup(req *Request) (*genie.GenieController, *utils.GenieConf, *utils.CNIArgs, error) {
+	args := req.CmdArgs()
+	conf, err := genie.ParseCNIConf(args.StdinData)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cniArgs := genie.PopulateCNIArgs(args)
+	gc := genie.NewGenieControllerWithClient(conf, s.Kc)
+	gc.Log = logging.WithFields(genie.LogFields(cniArgs))
+	gc.Log.Debugf("%s = %v", req.Command, logging.Redact(string(args.StdinData)))
+
+	return gc, conf, cniArgs, nil
+}
+
+// run executes a cni command and returns what the plugin is to print
+func (s *Server) run(req *Request) ([]byte, error) {
+	// GC deletes the attachments of any container and has the delegates
+	// release whatever is not valid, so it waits for the requests in progress
+	switch req.Command {
+	case "GC":
+		defer s.containers.lockAll()()
+	case "STATUS":
+		// No container is touched
+	default:
+		defer s.containers.lock(req.ContainerID)()
+	}
+
+	gc, conf, cniArgs, err := s.setup(req)
+	if err != nil {
+		return nil, err
+	}
+	defer genie.FlushMetrics(conf)
+
+	switch req.Command {
+	case "ADD":
+		gc.Log.Infof("Adding pod networks")
+		result, err := gc.AddPodNetwork(cniArgs, conf)
+		if err != nil || result == nil {
+			return nil, fmt.Errorf("CNI Genie Add IP internal error: %v, result: %s", err, result)
+		}
+		gc.Log.Infof("End result= %s", result)
+		versioned, err := result.GetAsVersion(genie.ResultVersion(conf))
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(versioned)
+	case "DEL":
+		gc.Log.Infof("Deleting pod networks")
+		if err = gc.DeletePodNetwork(cniArgs, conf); err != nil {
+			return nil, fmt.Errorf("CNI Genie release IP internal error: %v", err)
+		}
+	case "CHECK":
+		gc.Log.Infof("Checking pod networks")
+		if err = gc.CheckPodNetwork(cniArgs, conf); err != nil {
+			return nil, fmt.Errorf("CNI Genie check internal error: %v", err)
+		}
+	case "GC":
+		if err = gc.GarbageCollect(conf); err != nil {
+			return nil, err
+		}
+	case "STATUS":
+		if err = gc.Status(conf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported command %q", req.Command)
+	}
+	return nil, nil
+}
+
+// resolve resolves the attachments of the container without attaching any
+func (s *Server) resolve(req *Request) ([]Attachment, error) {
+	gc, conf, cniArgs, err := s.setup(req)
+	if err != nil {
+		return nil, err
+	}
+	defer genie.FlushMetrics(conf)
+	gc.DryRun = true
+
+	pluginInfoList, err := gc.ResolveContainerNetworks(cniArgs, conf)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]Attachment, 0, len(pluginInfoList))
+	for _, pluginInfo := range pluginInfoList {
+		attachment := Attachment{
+			IfName:       pluginInfo.IfName,
+			Network:      pluginInfo.PluginName,
+			ConfSource:   pluginInfo.ConfSource,
+			Subnet:       pluginInfo.Subnet,
+			Optional:     pluginInfo.Optional,
+			DefaultRoute: pluginInfo.DefaultRoute,
+		}
+		if pluginInfo.Config != nil {
+			for _, plugin := range pluginInfo.Config.Plugins {
+				attachment.Plugins = append(attachment.Plugins, plugin.Network.Type)
+			}
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
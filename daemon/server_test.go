@@ -0,0 +1,236 @@
+package daemon
+
+import (
+	"fmt"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves a daemon without kubernetes client on a socket in dir
+func startServer(t *testing.T, dir string) (string, chan struct{}) {
+	socket := filepath.Join(dir, "run", "genie.sock")
+	stopCh := make(chan struct{})
+	go func() {
+		if err := (&Server{}).Serve(socket, stopCh); err != nil {
+			t.Errorf("Error serving: %v", err)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return socket, stopCh
+}
+
+func TestForward(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"bridge", "weave"} {
+		conf := fmt.Sprintf(`{"cniVersion": "0.3.1", "name": "%s", "type": "%s"}`, name, name)
+		if err = ioutil.WriteFile(filepath.Join(dir, "10-"+name+".conf"), []byte(conf), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	socket, stopCh := startServer(t, dir)
+	defer close(stopCh)
+
+	netConf := fmt.Sprintf(`{"cniVersion": "0.3.1", "name": "genie", "type": "genie", "standalone": true, "conf_dir": %q, "state_dir": %q}`,
+		dir, filepath.Join(dir, "state"))
+	args := &skel.CmdArgs{
+		ContainerID: "ctr1",
+		Netns:       "/proc/self/ns/net",
+		IfName:      "eth0",
+		Args:        "IgnoreUnknown=1;K8S_POD_NAMESPACE=default;K8S_POD_NAME=web;cni=weave,bridge",
+		StdinData:   []byte(netConf),
+	}
+
+	tests := []struct {
+		command     string
+		netConf     string
+		expected    []Attachment
+		expectedErr string
+	}{
+		{
+			expected: []Attachment{
+				{IfName: "eth0", Network: "weave", Plugins: []string{"weave"}, ConfSource: filepath.Join(dir, "10-weave.conf")},
+				{IfName: "eth1", Network: "bridge", Plugins: []string{"bridge"}, ConfSource: filepath.Join(dir, "10-bridge.conf")},
+			},
+		},
+		{
+			netConf:     `{"cniVersion": "0.3.1", "name": "genie", "type": "genie", "dns_policy": "none"}`,
+			expectedErr: "failed to load netconf",
+		},
+		{
+			command: "GC",
+			netConf: fmt.Sprintf(`{"cniVersion": "1.1.0", "name": "genie", "type": "genie", "standalone": true, "conf_dir": %q, "state_dir": %q}`,
+				dir, filepath.Join(dir, "state")),
+		},
+		{
+			// The delegates are looked up in the default bin dir
+			command: "STATUS",
+			netConf: fmt.Sprintf(`{"cniVersion": "1.1.0", "name": "genie", "type": "genie", "standalone": true, "conf_dir": %q, "bin_dir": %q}`,
+				dir, filepath.Join(dir, "bin")),
+			expectedErr: "Plugin bridge not available",
+		},
+		{
+			command:     "VERSION",
+			expectedErr: `Unsupported command "VERSION"`,
+		},
+	}
+
+	for i := range tests {
+		a := *args
+		if tests[i].netConf != "" {
+			a.StdinData = []byte(tests[i].netConf)
+		}
+		if tests[i].command != "" {
+			_, err = Forward(socket, tests[i].command, &a)
+		} else {
+			var attachments []Attachment
+			attachments, err = Resolve(socket, &a)
+			if err == nil && !reflect.DeepEqual(attachments, tests[i].expected) {
+				t.Errorf("Expected attachments %+v; got %+v", tests[i].expected, attachments)
+			}
+		}
+		if tests[i].expectedErr == "" {
+			if err != nil {
+				t.Errorf("Expected no error; got %v", err)
+			}
+			continue
+		}
+		if _, ok := err.(*types.Error); !ok || !strings.Contains(err.Error(), tests[i].expectedErr) {
+			t.Errorf("Expected cni error %q; got %#v", tests[i].expectedErr, err)
+		}
+	}
+
+	if _, err = Forward(filepath.Join(dir, "absent.sock"), "ADD", args); err == nil || !strings.Contains(err.Error(), "Error contacting genie daemon") {
+		t.Errorf("Expected error contacting absent daemon; got %v", err)
+	}
+}
+
+func TestPostTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A wedged daemon accepts connections but never responds
+	socket := filepath.Join(dir, "wedged.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	args := &skel.CmdArgs{ContainerID: "c1", Netns: "/var/run/netns/c1", IfName: "eth0"}
+	start := time.Now()
+	_, err = post(socket, CNIPath, NewRequest("ADD", args), 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "Error contacting genie daemon") {
+		t.Errorf("Expected error contacting wedged daemon; got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected request to time out; took %v", elapsed)
+	}
+}
+
+func TestContainerLocks(t *testing.T) {
+	var locks containerLocks
+	unlock := locks.lock("c1")
+
+	// Another container is not held up by c1
+	done := make(chan struct{})
+	go func() {
+		locks.lock("c2")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected request for another container not to wait")
+	}
+
+	// The same container waits for c1 to be unlocked
+	locked := make(chan struct{})
+	go func() {
+		unlock := locks.lock("c1")
+		close(locked)
+		unlock()
+	}()
+	select {
+	case <-locked:
+		t.Fatalf("Expected request for the same container to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected request for the same container to go on once unlocked")
+	}
+
+	// GC waits for the requests in progress, and holds up the new ones
+	unlock = locks.lock("c1")
+	all := make(chan func())
+	go func() {
+		all <- locks.lockAll()
+	}()
+	select {
+	case <-all:
+		t.Fatalf("Expected locking all the containers to wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	var unlockAll func()
+	select {
+	case unlockAll = <-all:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected locking all the containers to go on once c1 is unlocked")
+	}
+	locked = make(chan struct{})
+	go func() {
+		locks.lock("c2")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatalf("Expected request for a container to wait for GC")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockAll()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected request for a container to go on after GC")
+	}
+
+	// The lock of c1 is released by the last of its requests
+	time.Sleep(50 * time.Millisecond)
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected no locks left; got: %v", locks.locks)
+	}
+}
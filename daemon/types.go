@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package daemon implements genie daemon, the node agent of the thick plugin
+mode. The daemon keeps informers for the pods of its node, the network
+attachment definitions and the logical networks, and serves the requests of
+the genie plugin over a unix socket. The plugin is then only a shim forwarding
+the cni invocations to the daemon.
+*/
+package daemon
+
+import (
+	"github.com/containernetworking/cni/pkg/skel"
+)
+
+const (
+	// DefaultSocket is the unix socket the daemon listens on by default
+	DefaultSocket = "/run/genie/genie.sock"
+
+	// CNIPath serves ADD, DEL, CHECK, GC and STATUS requests
+	CNIPath = "/cni"
+	// ResolvePath serves the resolution of the attachments of a container
+	// without attaching any
+	ResolvePath = "/resolve"
+	// HealthzPath reports that the daemon is serving
+	HealthzPath = "/healthz"
+)
+
+// Request is a cni invocation forwarded by the plugin
+type Request struct {
+	// Command is the cni command: ADD, DEL, CHECK, GC or STATUS. It is
+	// ignored on resolve.
+	Command     string `json:"command"`
+	ContainerID string `json:"containerID"`
+	Netns       string `json:"netns"`
+	IfName      string `json:"ifName"`
+	Args        string `json:"args"`
+	Path        string `json:"path"`
+	// StdinData is the network configuration passed to the plugin
+	StdinData []byte `json:"stdinData"`
+}
+
+// NewRequest creates the request for the cni command invoked with args
+func NewRequest(command string, args *skel.CmdArgs) *Request {
+	return &Request{
+		Command:     command,
+		ContainerID: args.ContainerID,
+		Netns:       args.Netns,
+		IfName:      args.IfName,
+		Args:        args.Args,
+		Path:        args.Path,
+		StdinData:   args.StdinData,
+	}
+}
+
+// CmdArgs returns the args of the cni invocation
+func (r *Request) CmdArgs() *skel.CmdArgs {
+	return &skel.CmdArgs{
+		ContainerID: r.ContainerID,
+		Netns:       r.Netns,
+		IfName:      r.IfName,
+		Args:        r.Args,
+		Path:        r.Path,
+		StdinData:   r.StdinData,
+	}
+}
+
+// Attachment is a network attachment resolved for a container
+type Attachment struct {
+	IfName  string `json:"ifName"`
+	Network string `json:"network"`
+	// Plugins are the types of the plugins in the configuration of the network
+	Plugins []string `json:"plugins,omitempty"`
+	// ConfSource tells where the configuration of the network comes from
+	ConfSource   string `json:"confSource,omitempty"`
+	Subnet       string `json:"subnet,omitempty"`
+	Optional     bool   `json:"optional,omitempty"`
+	DefaultRoute bool   `json:"defaultRoute,omitempty"`
+}
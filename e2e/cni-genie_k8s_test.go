@@ -33,6 +33,7 @@ const (
 
 var testKubeVersion string
 var testKubeConfig string
+var testOrchestrator string
 var clientset *kubernetes.Clientset
 var apiextensionsclient *apiextensionsclientset.Clientset
 
@@ -41,6 +42,7 @@ func init() {
 	// To override default values pass --testKubeVersion --testKubeConfig flags
 	flag.StringVar(&testKubeVersion, "testKubeVersion", "1.5", "Specify kubernetes version eg: 1.5 or 1.6 or 1.7")
 	flag.StringVar(&testKubeConfig, "testKubeConfig", "/root/admin.conf", "Specify testKubeConfig path eg: /root/kubeconfig")
+	flag.StringVar(&testOrchestrator, "testOrchestrator", "k8s", "Specify the orchestrator to run the tests for eg: k8s or mesos")
 }
 
 var _ = Describe("CNIGenie", func() {
@@ -48,6 +50,12 @@ var _ = Describe("CNIGenie", func() {
 	hostname, _ := os.Hostname()
 	glog.Info("Inside CNIGenie tests for k8s:", hostname)
 
+	BeforeEach(func() {
+		if testOrchestrator != "k8s" {
+			Skip("kubernetes tests run only for orchestrator k8s")
+		}
+	})
+
 	Describe("Add calico networking for Pod", func() {
 		glog.Info("Inside Check for adding Calico networking")
 		Context("using cni-genie for configuring calico CNI", func() {
@@ -972,6 +980,9 @@ var _ = Describe("CNIGenie", func() {
 
 })
 var _ = BeforeSuite(func() {
+	if testOrchestrator != "k8s" {
+		return
+	}
 	var config *rest.Config
 	var err error
 	glog.Infof("Kube version %s", testKubeVersion)
@@ -992,6 +1003,9 @@ var _ = BeforeSuite(func() {
 })
 
 var _ = AfterSuite(func() {
+	if testOrchestrator != "k8s" {
+		return
+	}
 
 	//Delete crd network-attachment-definitions.k8s.cni.cncf.io
 	err := apiextensionsclient.ApiextensionsV1beta1().CustomResourceDefinitions().Delete(NetAttachDef, &metav1.DeleteOptions{})
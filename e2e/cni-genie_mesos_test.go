@@ -1,17 +1,136 @@
 package main_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/golang/glog"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 )
 
-func init() {
+// fakeDelegate is a cni plugin answering ADD with a fixed address on the
+// interface it is asked to set up, and recording the interfaces it was
+// invoked for, so that the tests need no real network
+const fakeDelegate = `#!/bin/sh
+cat > /dev/null
+echo "$CNI_COMMAND $CNI_IFNAME" >> "$(dirname "$0")/invocations"
+if [ "$CNI_COMMAND" = "ADD" ]; then
+	echo '{"cniVersion": "0.3.1", "interfaces": [{"name": "'"$CNI_IFNAME"'"}], "ips": [{"version": "4", "address": "10.10.0.5/24", "gateway": "10.10.0.1", "interface": 0}]}'
+fi
+`
 
+// mesosNetConf is the network configuration as passed by the cni isolator of
+// Mesos, with the network info of the container in args
+func mesosNetConf(dir string, labels map[string]string) []byte {
+	type label struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	var l []label
+	for k, v := range labels {
+		l = append(l, label{Key: k, Value: v})
+	}
+	conf := map[string]interface{}{
+		"cniVersion": "0.3.1",
+		"name":       "genie",
+		"type":       "genie",
+		"conf_dir":   filepath.Join(dir, "conf"),
+		"bin_dir":    filepath.Join(dir, "bin"),
+		"state_dir":  filepath.Join(dir, "state"),
+		"args": map[string]interface{}{
+			"org.apache.mesos": map[string]interface{}{
+				"network_info": map[string]interface{}{
+					"name":   "genie",
+					"labels": map[string]interface{}{"labels": l},
+				},
+			},
+		},
+	}
+	data, _ := json.Marshal(conf)
+	return data
+}
+
+// runGenie invokes genie the way the cni isolator of Mesos does
+func runGenie(dir, command, containerID, ifName string, netConf []byte) ([]byte, error) {
+	cmd := exec.Command(filepath.Join(dir, "genie"))
+	cmd.Env = append(os.Environ(),
+		"CNI_COMMAND="+command,
+		"CNI_CONTAINERID="+containerID,
+		"CNI_NETNS=/proc/self/ns/net",
+		"CNI_IFNAME="+ifName,
+		"CNI_PATH="+filepath.Join(dir, "bin"))
+	cmd.Stdin = bytes.NewReader(netConf)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("%v: %s", err, stderr.String())
+	}
+	return out, nil
 }
 
 var _ = Describe("CNIGenie", func() {
 	glog.Info("Inside CNIGenie mesos tests")
 	Describe("Run Genie for mesos", func() {
-		glog.Info("Inside Run Genie for mesos...")
+		var dir string
+
+		BeforeEach(func() {
+			if testOrchestrator != "mesos" {
+				Skip("mesos tests run only for orchestrator mesos")
+			}
+			var err error
+			dir, err = ioutil.TempDir("", "genie-mesos")
+			Expect(err).NotTo(HaveOccurred())
+			for _, d := range []string{"bin", "conf", "state"} {
+				Expect(os.Mkdir(filepath.Join(dir, d), 0755)).To(Succeed())
+			}
+
+			_, file, _, _ := runtime.Caller(0)
+			build := exec.Command("go", "build", "-o", filepath.Join(dir, "genie"), filepath.Join(filepath.Dir(file), "..", "cni-genie.go"))
+			out, err := build.CombinedOutput()
+			Expect(err).NotTo(HaveOccurred(), string(out))
+
+			Expect(ioutil.WriteFile(filepath.Join(dir, "bin", "fake"), []byte(fakeDelegate), 0755)).To(Succeed())
+			for _, name := range []string{"fakenet", "fakenet2"} {
+				conf := fmt.Sprintf(`{"cniVersion": "0.3.1", "name": "%s", "type": "fake"}`, name)
+				Expect(ioutil.WriteFile(filepath.Join(dir, "conf", "10-"+name+".conf"), []byte(conf), 0644)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			if dir != "" {
+				os.RemoveAll(dir)
+			}
+		})
+
+		Context("using network info labels to select the networks", func() {
+			It("should return a result mesos accepts and delete the networks", func() {
+				netConf := mesosNetConf(dir, map[string]string{"cni": "fakenet, fakenet2", "rack": "r1"})
+
+				out, err := runGenie(dir, "ADD", "mesos-ctr1", "net1", netConf)
+				Expect(err).NotTo(HaveOccurred())
+				result := map[string]interface{}{}
+				Expect(json.Unmarshal(out, &result)).To(Succeed(), string(out))
+				Expect(result["cniVersion"]).To(Equal("0.2.0"))
+				Expect(result).To(HaveKey("ip4"))
+				Expect(result["ip4"].(map[string]interface{})["ip"]).To(Equal("10.10.0.5/24"))
+
+				_, err = runGenie(dir, "DEL", "mesos-ctr1", "net1", netConf)
+				Expect(err).NotTo(HaveOccurred())
+
+				invocations, err := ioutil.ReadFile(filepath.Join(dir, "bin", "invocations"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(invocations)).To(ContainSubstring("ADD net1\n"))
+				Expect(string(invocations)).To(ContainSubstring("ADD eth0\n"))
+				Expect(string(invocations)).To(ContainSubstring("DEL net1\n"))
+				Expect(string(invocations)).To(ContainSubstring("DEL eth0\n"))
+			})
+		})
 	})
 })
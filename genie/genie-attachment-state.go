@@ -0,0 +1,272 @@
+package genie
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/containernetworking/cni/libcni"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// StateFilePermission specifies the permission for attachment record files
+	StateFilePermission os.FileMode = 0600
+)
+
+// attachmentState is the node local record of all the attachments done
+// by genie for a container during ADD
+type attachmentState struct {
+	ContainerID string             `json:"containerId"`
+	Attachments []attachmentRecord `json:"attachments"`
+}
+
+// attachmentRecord describes a single delegated attachment
+type attachmentRecord struct {
+	PluginName   string            `json:"plugin"`
+	IfName       string            `json:"ifName"`
+	OptionalArgs map[string]string `json:"optionalArgs,omitempty"`
+	Optional     bool              `json:"optional,omitempty"`
+	// CapabilityArgs holds the runtime config passed to the delegate
+	CapabilityArgs map[string]interface{} `json:"capabilityArgs,omitempty"`
+	// Config holds the complete configuration, in conflist format,
+	// with which the delegate was invoked
+	Config json.RawMessage `json:"config"`
+}
+
+func (gc *GenieController) stateFile(containerID string) string {
+	return filepath.Join(gc.StateDir, containerID)
+}
+
+// confListBytes regenerates the conflist bytes from the individual plugin
+// configurations, as these might have been modified (eg: custom subnet)
+// after the list was loaded
+func confListBytes(config *libcni.NetworkConfigList) ([]byte, error) {
+	plugins := make([]json.RawMessage, 0, len(config.Plugins))
+	for _, plugin := range config.Plugins {
+		plugins = append(plugins, json.RawMessage(plugin.Bytes))
+	}
+
+	return json.Marshal(struct {
+		Name         string            `json:"name"`
+		CNIVersion   string            `json:"cniVersion,omitempty"`
+		DisableCheck bool              `json:"disableCheck,omitempty"`
+		Plugins      []json.RawMessage `json:"plugins"`
+	}{
+		Name:         config.Name,
+		CNIVersion:   config.CNIVersion,
+		DisableCheck: config.DisableCheck,
+		Plugins:      plugins,
+	})
+}
+
+// saveAttachments writes the resolved plugin list of a container into the
+// state directory, so that the attachments can be cleaned up on DEL even
+// if the pod object is no longer available
+func (gc *GenieController) saveAttachments(containerID string, pluginInfoList []*utils.PluginInfo) error {
+	if gc.StateDir == "" || containerID == "" {
+		return nil
+	}
+
+	state := attachmentState{ContainerID: containerID}
+	for _, pluginInfo := range pluginInfoList {
+		config, err := confListBytes(pluginInfo.Config)
+		if err != nil {
+			return fmt.Errorf("Error marshalling configuration of plugin %s: %v", pluginInfo.PluginName, err)
+		}
+		state.Attachments = append(state.Attachments, attachmentRecord{
+			PluginName:     pluginInfo.PluginName,
+			IfName:         pluginInfo.IfName,
+			OptionalArgs:   pluginInfo.OptionalArgs,
+			Optional:       pluginInfo.Optional,
+			CapabilityArgs: pluginInfo.CapabilityArgs,
+			Config:         config,
+		})
+	}
+
+	bytes, err := json.Marshal(&state)
+	if err != nil {
+		return fmt.Errorf("Error marshalling attachment state: %v", err)
+	}
+
+	if err = os.MkdirAll(gc.StateDir, 0700); err != nil {
+		return fmt.Errorf("Error creating state directory %s: %v", gc.StateDir, err)
+	}
+
+	// Write into a temporary file first and rename it, so that a
+	// partially written record is never read back
+	file := gc.stateFile(containerID)
+	tmpFile := file + ".tmp"
+	if err = ioutil.WriteFile(tmpFile, bytes, StateFilePermission); err != nil {
+		return fmt.Errorf("Error writing attachment state file %s: %v", tmpFile, err)
+	}
+	if err = os.Rename(tmpFile, file); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("Error renaming attachment state file %s: %v", tmpFile, err)
+	}
+
+	return nil
+}
+
+// loadAttachments reads back the plugin list recorded for a container.
+// A nil list is returned if no record is present.
+func (gc *GenieController) loadAttachments(containerID string) ([]*utils.PluginInfo, error) {
+	if gc.StateDir == "" || containerID == "" {
+		return nil, nil
+	}
+
+	bytes, err := ioutil.ReadFile(gc.stateFile(containerID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Error reading attachment state for container %s: %v", containerID, err)
+	}
+
+	state := attachmentState{}
+	if err = json.Unmarshal(bytes, &state); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling attachment state for container %s: %v", containerID, err)
+	}
+
+	pluginInfoList := make([]*utils.PluginInfo, 0, len(state.Attachments))
+	for _, attachment := range state.Attachments {
+		config, err := gc.Cfg.ConfListFromBytes(attachment.Config)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading recorded configuration of plugin %s: %v", attachment.PluginName, err)
+		}
+		pluginInfoList = append(pluginInfoList, &utils.PluginInfo{
+			PluginName:     attachment.PluginName,
+			IfName:         attachment.IfName,
+			OptionalArgs:   attachment.OptionalArgs,
+			Optional:       attachment.Optional,
+			CapabilityArgs: attachment.CapabilityArgs,
+			Config:         config,
+		})
+	}
+
+	return pluginInfoList, nil
+}
+
+// removeAttachments deletes the attachment record of a container
+func (gc *GenieController) removeAttachments(containerID string) error {
+	if gc.StateDir == "" || containerID == "" {
+		return nil
+	}
+
+	err := os.Remove(gc.stateFile(containerID))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Error removing attachment state for container %s: %v", containerID, err)
+	}
+
+	return nil
+}
+
+// listAttachments returns the ids of all the containers having an attachment record
+func (gc *GenieController) listAttachments() ([]string, error) {
+	if gc.StateDir == "" {
+		return nil, nil
+	}
+
+	files, err := ioutil.ReadDir(gc.StateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Error reading state directory %s: %v", gc.StateDir, err)
+	}
+
+	containers := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || strings.HasSuffix(file.Name(), ".tmp") {
+			continue
+		}
+		containers = append(containers, file.Name())
+	}
+
+	return containers, nil
+}
+
+type gcDelegate struct {
+	config      *libcni.NetworkConfigList
+	attachments []utils.GCAttachment
+}
+
+// GarbageCollect cleans up the attachments of all the containers which are
+// not present in the list of valid attachments passed by the runtime. The
+// delegates of the networks recorded for the remaining containers are then
+// asked to clean up whatever they hold apart from the attachments of these
+// containers. The attachments of valid containers without a record, eg: ones
+// created before attachment records were kept, are not known; delegates are
+// then not asked to clean up, lest they release what these containers hold.
+func (gc *GenieController) GarbageCollect(conf *utils.GenieConf) error {
+	valid := make(map[string]bool)
+	for _, attachment := range conf.ValidAttachments {
+		valid[attachment.ContainerID] = true
+	}
+
+	containers, err := gc.listAttachments()
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	var delegateNames []string
+	delegates := make(map[string]*gcDelegate)
+	recorded := make(map[string]bool)
+	for _, containerID := range containers {
+		pluginInfoList, err := gc.loadAttachments(containerID)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+
+		if !valid[containerID] {
+			gc.logger().Infof("Deleting stale attachments for container %s", containerID)
+			err = gc.deleteNetwork(pluginInfoList, &utils.CNIArgs{ContainerID: containerID})
+			if err == nil {
+				err = gc.removeAttachments(containerID)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("container %s: %v", containerID, err))
+			}
+			continue
+		}
+
+		recorded[containerID] = true
+		for _, pluginInfo := range pluginInfoList {
+			name := pluginInfo.Config.Name
+			if delegates[name] == nil {
+				delegates[name] = &gcDelegate{config: pluginInfo.Config}
+				delegateNames = append(delegateNames, name)
+			}
+			delegates[name].attachments = append(delegates[name].attachments, utils.GCAttachment{
+				ContainerID: containerID,
+				IfName:      pluginInfo.IfName,
+			})
+		}
+	}
+
+	for containerID := range valid {
+		if !recorded[containerID] {
+			gc.logger().Warningf("No attachment record for valid container %s, skipping GC of the delegates", containerID)
+			delegateNames = nil
+			break
+		}
+	}
+
+	for _, name := range delegateNames {
+		delegate := delegates[name]
+		gc.fillMandatoryCNIPara(delegate.config)
+		if err = gc.Invoke.InvokeExecGC(delegate.config, delegate.attachments); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("CNI Genie GC failed: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
@@ -28,11 +28,11 @@ import (
 	"encoding/json"
 	"fmt"
 	. "github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
 	"github.com/google/cadvisor/info/v1"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"path"
 	"strings"
 )
@@ -75,8 +75,8 @@ func containerInfoUrl(baseUrl string, name string) string {
 func httpGetJsonData(data, postData interface{}, url, infoName string, c Cadvisor) error {
 	var resp *http.Response
 	var err error
-	fmt.Fprintf(os.Stderr, "CAdvisor Client Inside httpGetJsonData() = %v\n", data)
-	fmt.Fprintf(os.Stderr, "CAdvisor Client postData = %v\n", postData)
+	logging.Debugf("CAdvisor client request data: %v", data)
+	logging.Debugf("CAdvisor client post data: %v", postData)
 	if postData != nil {
 		data, marshalErr := json.Marshal(postData)
 		if marshalErr != nil {
@@ -86,9 +86,9 @@ func httpGetJsonData(data, postData interface{}, url, infoName string, c Cadviso
 	} else {
 		resp, err = c.Get(url)
 	}
-	fmt.Fprintf(os.Stderr, "CAdvisor Client resp = %v\n", resp)
+	logging.Debugf("CAdvisor client response: %v", resp)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "CAdvisor Client err = %v\n", err)
+		logging.Errorf("CAdvisor client request failed: %v", err)
 		return fmt.Errorf("unable to get %q from %q: %v", infoName, url, err)
 	}
 	if resp == nil {
@@ -97,23 +97,23 @@ func httpGetJsonData(data, postData interface{}, url, infoName string, c Cadviso
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 
-	fmt.Fprintf(os.Stderr, "CAdvisor Client body = %v\n", string(body))
+	logging.Debugf("CAdvisor client response body: %v", string(body))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "CAdvisor Client err2 = %v\n", err)
+		logging.Errorf("CAdvisor client error reading response body: %v", err)
 		err = fmt.Errorf("unable to read all %q from %q: %v", infoName, url, err)
 		return err
 	}
 	if resp.StatusCode != 200 {
-		fmt.Fprintf(os.Stderr, "CAdvisor Client Unmarshal resp.StatusCode = %v\n", resp.StatusCode)
+		logging.Debugf("CAdvisor client response status code: %v", resp.StatusCode)
 		return fmt.Errorf("request %q failed with error: %q", url, strings.TrimSpace(string(body)))
 	}
 
 	if err = json.Unmarshal(body, data); err != nil {
-		fmt.Fprintf(os.Stderr, "CAdvisor Client Unmarshal err = %v\n", err)
+		logging.Errorf("CAdvisor client error unmarshalling response: %v", err)
 		err = fmt.Errorf("unable to unmarshal %q (Body: %q) from %q with error: %v", infoName, string(body), url, err)
 		return err
 	}
-	fmt.Fprintf(os.Stderr, "CAdvisor Client data = %v\n", data)
+	logging.Debugf("CAdvisor client data: %v", data)
 	return nil
 }
 
@@ -140,13 +140,13 @@ func computeNetworkUsage(cinfo []ContainerStatsGenie) string {
 
 	//TODO (Karun): Need to rethink on the logic. This is not an accurate measure.
 	for i, c := range cinfo {
-		fmt.Fprintf(os.Stderr, "CAdvisor Client computeNetworkUsage i = %v\n", i)
+		logging.Debugf("CAdvisor client computeNetworkUsage i = %v", i)
 		for _, intf := range c.Network.Interfaces {
 			if _, ok := m[intf.Name[:4]]; ok {
 				if oldrx, ok := rx[intf.Name]; ok {
-					fmt.Fprintf(os.Stderr, "CAdvisor Client computeNetworkUsage intfname = %v\n", intf.Name[:4])
-					fmt.Fprintf(os.Stderr, "CAdvisor Client computeNetworkUsage intf.RxBytes = %v\n", intf.RxBytes)
-					fmt.Fprintf(os.Stderr, "CAdvisor Client computeNetworkUsage oldrx = %v\n", oldrx)
+					logging.Debugf("CAdvisor client computeNetworkUsage intfname = %v", intf.Name[:4])
+					logging.Debugf("CAdvisor client computeNetworkUsage intf.RxBytes = %v", intf.RxBytes)
+					logging.Debugf("CAdvisor client computeNetworkUsage oldrx = %v", oldrx)
 					downlink = int(intf.RxBytes - oldrx)
 				}
 				rx[intf.Name] = intf.RxBytes
@@ -154,7 +154,7 @@ func computeNetworkUsage(cinfo []ContainerStatsGenie) string {
 			}
 		}
 	}
-	fmt.Fprintf(os.Stderr, "CAdvisor Client computeNetworkUsage m = %v\n", m)
+	logging.Debugf("CAdvisor client computeNetworkUsage m = %v", m)
 	//sort by values of map
 	cns := SortedKeys(m)
 	for i, c := range cns {
@@ -169,7 +169,7 @@ func computeNetworkUsage(cinfo []ContainerStatsGenie) string {
 			cns[i] = "calico"
 		}
 	}
-	fmt.Fprintf(os.Stderr, "CAdvisor Client computeNetworkUsage cns = %v\n", cns[0])
+	logging.Debugf("CAdvisor client computeNetworkUsage cns = %v", cns[0])
 	return cns[0]
 }
 
@@ -183,12 +183,12 @@ func (gc *GenieController) GetCNSOrderByNetworkBandwith(conf *GenieConf) (string
 
 	cinfo, err := gc.GetDockerContainers(fmt.Sprintf("%s/api/v1.3/", cAdvisorURL), nil)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "CAdvisor Client cinfo err = %v\n", err)
+		gc.logger().Errorf("CAdvisor client error getting container info: %v", err)
 		return "", err
 	}
-	fmt.Fprintf(os.Stderr, "CAdvisor Client cinfo is = %v\n", cinfo)
+	gc.logger().Debugf("CAdvisor client container info: %v", cinfo)
 	res := computeNetworkUsage(cinfo)
-	fmt.Fprintf(os.Stderr, "CAdvisor Client response = %v\n", res)
+	gc.logger().Debugf("CAdvisor client response: %v", res)
 	return res, nil
 }
 
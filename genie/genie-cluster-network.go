@@ -0,0 +1,207 @@
+package genie
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultNetworkAnnot names a network attachment definition, in the
+	// namespace of the pod unless given as namespace/name, replacing the
+	// cluster network as the primary network (eth0) of the pod
+	DefaultNetworkAnnot = "cni-default-network"
+)
+
+// getClusterNetwork gets the cluster wide default network, i.e. the primary
+// network of pods not selecting one through the cni annotation. If
+// "cluster_network" is set in genie configuration, the network it refers to
+// is used. Otherwise, the first (in lexical order) valid config file
+// (excluding genie configuration) will be treated as the configuration for
+// cluster wide default network. It will be assumed that the corresponding
+// plugin executable is present in the plugin directory and the corresponding
+// plugin service is running (if required) in the cluster. If no conf/conflist
+// file (other than genie configuration) is present in the directory, then
+// network attachment will fail assuming non-readiness of node.
+func (gc *GenieController) getClusterNetwork(conf *utils.GenieConf) (*utils.PluginInfo, error) {
+	var pluginInfo *utils.PluginInfo
+	if conf.ClusterNetwork != "" {
+		var err error
+		pluginInfo, err = gc.resolveNetworkReference(conf.ClusterNetwork)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting cluster network %s: %v", conf.ClusterNetwork, err)
+		}
+	} else {
+		found := false
+		for _, file := range gc.Cfg.Files {
+			if strings.Contains(file, GenieConfFile) {
+				continue
+			}
+			found = true
+			config, err := gc.Cfg.ParseCNIConfFromFile(file)
+			if err != nil || config.Plugins[0].Network.Type == "genie" {
+				continue
+			}
+			pluginInfo = &utils.PluginInfo{PluginName: config.Plugins[0].Network.Type, Config: config, ConfSource: file}
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("No cni plugin has been installed on node.")
+		}
+		if pluginInfo == nil {
+			return nil, fmt.Errorf("Unable to select default cluster network. No valid configuration file present in cni directory.")
+		}
+	}
+	pluginInfo.IfName = DefaultIfNamePrefix + "0"
+	return pluginInfo, nil
+}
+
+// getPodDefaultNetwork gets the primary network of a pod, i.e. the network
+// attachment definition named in its default network annotation if any, or
+// else the cluster network. The annotation is honored only for the pods of
+// the namespaces allowed in genie configuration.
+func (gc *GenieController) getPodDefaultNetwork(podAnnot map[string]string, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) (*utils.PluginInfo, error) {
+	ref, ok := podAnnot[DefaultNetworkAnnot]
+	if !ok {
+		return gc.getClusterNetwork(conf)
+	}
+
+	podNamespace := string(k8sArgs.K8S_POD_NAMESPACE)
+	if !defaultNetworkAllowed(podNamespace, conf) {
+		return nil, fmt.Errorf("Pods of namespace %q are not allowed to replace the cluster network through %s annotation", podNamespace, DefaultNetworkAnnot)
+	}
+	namespace, name := podNamespace, strings.TrimSpace(ref)
+	if i := strings.Index(name, "/"); i >= 0 {
+		namespace, name = name[:i], name[i+1:]
+	}
+	if namespace == "" || name == "" {
+		return nil, fmt.Errorf("Invalid network attachment definition %q in %s annotation", ref, DefaultNetworkAnnot)
+	}
+	if conf.NamespaceIsolation && !networkcrd.NamespaceAllowed(namespace, podNamespace, conf.GlobalNamespaces) {
+		return nil, fmt.Errorf("Network attachment definition %s/%s in %s annotation is not allowed for pods of namespace %s with namespace isolation", namespace, name, DefaultNetworkAnnot, podNamespace)
+	}
+
+	network, err := gc.getNetworkObject(name, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting default network of pod: %v", err)
+	}
+	config, source, err := gc.getNetworkConfig(network)
+	if err != nil {
+		return nil, err
+	}
+	gc.logger().Infof("Replacing cluster network with %s/%s as requested by the pod", namespace, name)
+	return &utils.PluginInfo{PluginName: network.Name, Config: config, ConfSource: source, IfName: DefaultIfNamePrefix + "0", ResourceName: resourceName(network)}, nil
+}
+
+func defaultNetworkAllowed(namespace string, conf *utils.GenieConf) bool {
+	for _, allowed := range conf.DefaultNetworkNamespaces {
+		if allowed == "*" || (allowed == namespace && namespace != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// usesNetAttachDefs tells whether the networks of a pod are selected as per
+// the network attachment definition annotations. A pod replacing its default
+// network without selecting any through the cni annotation selects no other
+// network attachment definition.
+func usesNetAttachDefs(podAnnot map[string]string) bool {
+	if _, ok := podAnnot[NetworkAttachmentDefinitionAnnot]; ok {
+		return true
+	}
+	_, override := podAnnot[DefaultNetworkAnnot]
+	_, cni := podAnnot["cni"]
+	return override && !cni
+}
+
+// checkDefaultNetworkAnnot rejects a pod replacing its default network while
+// selecting its networks through the cni annotation, the replacement not
+// applying to those networks
+func checkDefaultNetworkAnnot(podAnnot map[string]string) error {
+	_, override := podAnnot[DefaultNetworkAnnot]
+	_, cni := podAnnot["cni"]
+	if override && cni && !usesNetAttachDefs(podAnnot) {
+		return fmt.Errorf("Annotation %s cannot be used along with the cni annotation", DefaultNetworkAnnot)
+	}
+	return nil
+}
+
+// appendDefaultNetworks appends the "default_networks" of genie configuration
+// to the attachments selected for a pod. A default network which is already
+// attached, eg: as the cluster network, is not attached again.
+func (gc *GenieController) appendDefaultNetworks(pluginInfoList []*utils.PluginInfo, conf *utils.GenieConf) ([]*utils.PluginInfo, error) {
+	for _, ref := range conf.DefaultNetworks {
+		pluginInfo, err := gc.resolveNetworkReference(ref)
+		if err != nil {
+			return nil, fmt.Errorf("Error getting default network %s: %v", ref, err)
+		}
+		if isAttached(pluginInfoList, pluginInfo) {
+			gc.logger().Debugf("Default network %s is already selected for the pod", ref)
+			continue
+		}
+		pluginInfoList = append(pluginInfoList, pluginInfo)
+	}
+	return pluginInfoList, nil
+}
+
+func isAttached(pluginInfoList []*utils.PluginInfo, pluginInfo *utils.PluginInfo) bool {
+	for _, attached := range pluginInfoList {
+		if attached.Config != nil && attached.Config.Name == pluginInfo.Config.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveNetworkReference resolves a network referred to in genie
+// configuration. A reference ending with the extension of a configuration
+// file, or given as an absolute path, is a configuration file, relative to
+// the conf dir unless absolute. A reference of the form namespace/name is a
+// network attachment definition. Any other reference is the name of a
+// network configuration in the conf dir.
+func (gc *GenieController) resolveNetworkReference(ref string) (*utils.PluginInfo, error) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case ref == "":
+		return nil, fmt.Errorf("Network reference is empty")
+	case isConfFileReference(ref):
+		file := ref
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(gc.Cfg.NetDir, file)
+		}
+		config, err := gc.Cfg.ParseCNIConfFromFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return &utils.PluginInfo{PluginName: config.Plugins[0].Network.Type, Config: config, ConfSource: file}, nil
+	case isNetAttachDefReference(ref):
+		parts := strings.SplitN(ref, "/", 2)
+		network, err := gc.getNetworkObject(parts[1], parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("Error getting network crd object: %v", err)
+		}
+		config, source, err := gc.getNetworkConfig(network)
+		if err != nil {
+			return nil, err
+		}
+		return &utils.PluginInfo{PluginName: network.Name, Config: config, ConfSource: source, ResourceName: resourceName(network)}, nil
+	default:
+		config, err := gc.Cfg.LoadNetConfList(ref)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading configuration of network %s from %s: %v", ref, gc.Cfg.NetDir, err)
+		}
+		return &utils.PluginInfo{PluginName: ref, Config: config, ConfSource: fmt.Sprintf("configuration named %s in %s", ref, gc.Cfg.NetDir)}, nil
+	}
+}
+
+func isConfFileReference(ref string) bool {
+	ext := filepath.Ext(ref)
+	return filepath.IsAbs(ref) || ext == ".conf" || ext == ".conflist" || ext == ".json"
+}
+
+func isNetAttachDefReference(ref string) bool {
+	return !isConfFileReference(ref) && strings.Contains(ref, "/")
+}
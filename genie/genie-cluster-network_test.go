@@ -0,0 +1,192 @@
+package genie_test
+
+import (
+	"errors"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/genie/genietest"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"k8s.io/apimachinery/pkg/runtime"
+	"testing"
+)
+
+func TestClusterNetwork(t *testing.T) {
+	netAttachDefs := []runtime.Object{
+		newNetAttachDef("sriov-net", "default", `{"cniVersion": "0.3.1", "name": "sriov-net", "type": "sriov"}`),
+		newNetAttachDef("macvlan-net", "default", `{"cniVersion": "0.3.1", "name": "macvlan-net", "type": "macvlan"}`),
+	}
+
+	tests := []struct {
+		annot           map[string]string
+		clusterNetwork  string
+		defaultNetworks []string
+		expectedErr     error
+		expectedAdded   []string
+	}{
+		{
+			annot:         nil,
+			expectedAdded: []string{"bridge"},
+		},
+		{
+			annot:          nil,
+			clusterNetwork: "weave",
+			expectedAdded:  []string{"weave"},
+		},
+		{
+			annot:           nil,
+			clusterNetwork:  "10-weave.conf",
+			defaultNetworks: []string{"default/sriov-net"},
+			expectedAdded:   []string{"weave", "sriov-net"},
+		},
+		{
+			// The default network selected by the pod is not attached twice
+			annot:           map[string]string{genie.NetworkAttachmentDefinitionAnnot: "macvlan-net"},
+			clusterNetwork:  "/etc/cni/net.d/10-weave.conf",
+			defaultNetworks: []string{"default/sriov-net", "default/macvlan-net"},
+			expectedAdded:   []string{"weave", "macvlan-net", "sriov-net"},
+		},
+		{
+			annot:           map[string]string{"cni": "weave"},
+			defaultNetworks: []string{"bridge"},
+			expectedAdded:   []string{"weave", "bridge"},
+		},
+		{
+			annot:          nil,
+			clusterNetwork: "absent",
+			expectedErr:    errors.New("Error getting cluster network absent"),
+		},
+		{
+			annot:           map[string]string{"cni": "weave"},
+			defaultNetworks: []string{"default/absent"},
+			expectedErr:     errors.New("Error getting default network default/absent"),
+		},
+	}
+
+	for i := range tests {
+		// bridge is the first network of the conf dir
+		invoker := genietest.NewInvoker()
+		_, err := attach(newConfDir(), invoker, tests[i].annot, netAttachDefs, func(_ *genie.GenieController, conf *utils.GenieConf) {
+			conf.ClusterNetwork = tests[i].clusterNetwork
+			conf.DefaultNetworks = tests[i].defaultNetworks
+		})
+		checkError(t, i, tests[i].expectedErr, err)
+		checkAdded(t, i, invoker, tests[i].expectedAdded, nil)
+	}
+}
+
+func TestPodDefaultNetwork(t *testing.T) {
+	netAttachDefs := []runtime.Object{
+		newNetAttachDef("sriov-net", "default", `{"cniVersion": "0.3.1", "name": "sriov-net", "type": "sriov"}`),
+		newNetAttachDef("macvlan-net", "default", `{"cniVersion": "0.3.1", "name": "macvlan-net", "type": "macvlan"}`),
+		newNetAttachDef("storage-net", "kube-system", `{"cniVersion": "0.3.1", "name": "storage-net", "type": "macvlan"}`),
+	}
+
+	tests := []struct {
+		annot             map[string]string
+		allowedNamespaces []string
+		expectedErr       error
+		expectedAdded     []string
+		expectedIfNames   []string
+	}{
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "sriov-net"},
+			allowedNamespaces: []string{"default"},
+			expectedAdded:     []string{"sriov-net"},
+			expectedIfNames:   []string{"eth0"},
+		},
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "sriov-net", genie.NetworkAttachmentDefinitionAnnot: "macvlan-net"},
+			allowedNamespaces: []string{"*"},
+			expectedAdded:     []string{"sriov-net", "macvlan-net"},
+			expectedIfNames:   []string{"eth0", "eth1"},
+		},
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "kube-system/storage-net"},
+			allowedNamespaces: []string{"storage", "default"},
+			expectedAdded:     []string{"storage-net"},
+			expectedIfNames:   []string{"eth0"},
+		},
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "sriov-net"},
+			allowedNamespaces: nil,
+			expectedErr:       errors.New(`Pods of namespace "default" are not allowed`),
+		},
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "sriov-net"},
+			allowedNamespaces: []string{"kube-system"},
+			expectedErr:       errors.New(`Pods of namespace "default" are not allowed`),
+		},
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "absent-net"},
+			allowedNamespaces: []string{"default"},
+			expectedErr:       errors.New("Error getting default network of pod"),
+		},
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "sriov-net", "cni": "weave"},
+			allowedNamespaces: []string{"default"},
+			expectedErr:       errors.New("Annotation cni-default-network cannot be used along with the cni annotation"),
+		},
+	}
+
+	for i := range tests {
+		invoker := genietest.NewInvoker()
+		_, err := attach(newConfDir(), invoker, tests[i].annot, netAttachDefs, func(_ *genie.GenieController, conf *utils.GenieConf) {
+			conf.DefaultNetworkNamespaces = tests[i].allowedNamespaces
+		})
+		checkError(t, i, tests[i].expectedErr, err)
+		checkAdded(t, i, invoker, tests[i].expectedAdded, tests[i].expectedIfNames)
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	var netAttachDefs []runtime.Object
+	for _, ns := range []string{"default", "tenant2", "shared"} {
+		netAttachDefs = append(netAttachDefs, newNetAttachDef("macvlan-net", ns, `{"cniVersion": "0.3.1", "type": "macvlan"}`))
+	}
+
+	tests := []struct {
+		annot            map[string]string
+		isolation        bool
+		globalNamespaces []string
+		expectedErr      error
+		expectedAdded    []string
+	}{
+		{
+			annot:         map[string]string{genie.NetworkAttachmentDefinitionAnnot: "tenant2/macvlan-net"},
+			isolation:     false,
+			expectedAdded: []string{"bridge", "macvlan-net"},
+		},
+		{
+			annot:         map[string]string{genie.NetworkAttachmentDefinitionAnnot: "macvlan-net"},
+			isolation:     true,
+			expectedAdded: []string{"bridge", "macvlan-net"},
+		},
+		{
+			annot:            map[string]string{genie.NetworkAttachmentDefinitionAnnot: `[{"name": "macvlan-net", "namespace": "shared"}]`},
+			isolation:        true,
+			globalNamespaces: []string{"shared"},
+			expectedAdded:    []string{"bridge", "macvlan-net"},
+		},
+		{
+			annot:            map[string]string{genie.NetworkAttachmentDefinitionAnnot: "macvlan-net, tenant2/macvlan-net"},
+			isolation:        true,
+			globalNamespaces: []string{"shared"},
+			expectedErr:      errors.New("Network attachment definition tenant2/macvlan-net is not allowed for pods of namespace default"),
+		},
+		{
+			annot:       map[string]string{genie.DefaultNetworkAnnot: "tenant2/macvlan-net"},
+			isolation:   true,
+			expectedErr: errors.New("not allowed for pods of namespace default with namespace isolation"),
+		},
+	}
+
+	for i := range tests {
+		invoker := genietest.NewInvoker()
+		_, err := attach(newConfDir(), invoker, tests[i].annot, netAttachDefs, func(_ *genie.GenieController, conf *utils.GenieConf) {
+			conf.DefaultNetworkNamespaces = []string{"*"}
+			conf.NamespaceIsolation = tests[i].isolation
+			conf.GlobalNamespaces = tests[i].globalNamespaces
+		})
+		checkError(t, i, tests[i].expectedErr, err)
+		checkAdded(t, i, invoker, tests[i].expectedAdded, nil)
+	}
+}
@@ -19,11 +19,10 @@ networking or pod multi-IP based networking.
 package genie
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net"
-	"os"
 	"strconv"
 	"strings"
 
@@ -31,16 +30,20 @@ import (
 	"github.com/cni-genie/CNI-Genie/client"
 	it "github.com/cni-genie/CNI-Genie/interfaces"
 	"github.com/cni-genie/CNI-Genie/plugins"
+	"github.com/cni-genie/CNI-Genie/podresources"
 	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
 	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
-	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/containernetworking/cni/pkg/version"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	api "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"sync"
+	"time"
 )
 
 const (
@@ -62,14 +65,26 @@ const (
 	err_nopod_novar              = "No pod or env var found"
 	// Default value for cni version
 	DefaultCNIVersion = "0.3.0"
+	// CheckMinCNIVersion specifies the minimum cni version supporting CHECK command
+	CheckMinCNIVersion = "0.4.0"
+	// ErrPluginNotAvailable is the cni error code reported on STATUS when delegates are not ready
+	ErrPluginNotAvailable uint = 50
 )
 
-type SetStatus func(current.Result, string, string, interface{}) interface{}
+// PluginVersions lists the cni versions supported by Genie
+var PluginVersions = version.PluginSupports("0.1.0", "0.2.0", "0.3.0", "0.3.1", "0.4.0", "1.0.0", "1.1.0")
+
+// SetStatus updates the current status with either the result of an
+// attachment or the error due to which the attachment failed. The attachment
+// owning the default route of the pod is marked as default.
+type SetStatus func(result types100.Result, name, ifName string, isDefault bool, err error, currStatus interface{}) interface{}
 
 type sendCh struct {
-	name   string
-	ifName string
-	res    types.Result
+	name      string
+	ifName    string
+	isDefault bool
+	res       types.Result
+	err       error
 }
 
 type GenieController struct {
@@ -77,6 +92,31 @@ type GenieController struct {
 	Cfg    *it.CNIConfig
 	Kc     *client.KubeClient
 	Cad    Cadvisor
+	// StateDir is the directory in which attachment records of containers
+	// are kept. Attachment records are not maintained if it is empty.
+	StateDir string
+	// CNIVersion is the cni version requested by the runtime
+	CNIVersion string
+	Routes     it.Routes
+	// DNSPolicy and DNSAttachment decide the dns configuration of pods
+	// having multiple attachments
+	DNSPolicy     string
+	DNSAttachment string
+	// DryRun resolves the attachments without changing anything on the node or
+	// in the cluster. Default configuration files are not placed and the pod is
+	// not updated with the network selected through cAdvisor.
+	DryRun bool
+	// Standalone specifies that genie runs without kubernetes. Kc is not set
+	// and the network selections are taken from SelectionFile and from the args
+	// passed by the runtime.
+	Standalone    bool
+	SelectionFile string
+	// PodResources finds the devices allocated to pods by the device plugins,
+	// passed to the delegates of networks backed by a device resource
+	PodResources podresources.Lister
+	// Log, if set, carries the fields of the invocation on every log line of
+	// the controller. The standard logger is used otherwise.
+	Log *logging.Entry
 }
 
 // PopulateCNIArgs wraps skel.CmdArgs into Genie's native CNIArgs format.
@@ -93,21 +133,63 @@ func PopulateCNIArgs(args *skel.CmdArgs) *utils.CNIArgs {
 }
 
 func NewGenieController(conf *utils.GenieConf) (*GenieController, error) {
-	kc, err := client.BuildKubeClientFromConfig(conf)
-	if err != nil {
-		return nil, fmt.Errorf("Error building kubernetes client: %v", err)
+	var kc *client.KubeClient
+	if !isStandalone(conf) {
+		var err error
+		kc, err = client.BuildKubeClientFromConfig(conf)
+		if err != nil {
+			return nil, fmt.Errorf("Error building kubernetes client: %v", err)
+		}
+	}
+	return NewGenieControllerWithClient(conf, kc), nil
+}
+
+// isStandalone tells whether genie runs without kubernetes. Mesos agents run
+// without kubernetes, network selections come along with the network info of
+// the container.
+func isStandalone(conf *utils.GenieConf) bool {
+	return conf.Standalone || isMesos(conf)
+}
+
+// NewGenieControllerWithClient creates a controller using the given kubernetes
+// client, eg: one shared by the invocations served by genie daemon. The client
+// is not used in standalone mode.
+func NewGenieControllerWithClient(conf *utils.GenieConf, kc *client.KubeClient) *GenieController {
+	standalone := isStandalone(conf)
+	if standalone {
+		kc = nil
+	}
+	stateDir := conf.StateDir
+	if stateDir == "" {
+		stateDir = utils.DefaultStateDir
+	}
+	confDir := conf.ConfDir
+	if confDir == "" {
+		confDir = DefaultNetDir
+	}
+	binDir := conf.BinDir
+	if binDir == "" {
+		binDir = DefaultPluginDir
 	}
 	return &GenieController{
 		Kc: kc,
 		Cfg: &it.CNIConfig{
 			RW:     &it.IO{},
 			CNI:    &it.Cni{},
-			NetDir: DefaultNetDir,
-			BinDir: DefaultPluginDir,
+			NetDir: confDir,
+			BinDir: binDir,
 		},
-		Invoke: &it.Invoke{Path: []string{DefaultPluginDir}},
-		Cad:    getCadClient(),
-	}, nil
+		Invoke:        &it.Invoke{Path: []string{binDir}},
+		Cad:           getCadClient(),
+		StateDir:      stateDir,
+		CNIVersion:    conf.CNIVersion,
+		Routes:        &it.NetlinkRoutes{},
+		DNSPolicy:     conf.DNSPolicy,
+		DNSAttachment: conf.DNSAttachment,
+		Standalone:    standalone,
+		SelectionFile: conf.SelectionFile,
+		PodResources:  podresources.NewClient(conf.PodResourcesSocket, conf.DeviceCheckpointFile),
+	}
 }
 
 // ParseCNIConf parses input configuration file and returns
@@ -118,6 +200,9 @@ func ParseCNIConf(confData []byte) (*utils.GenieConf, error) {
 	if err := json.Unmarshal(confData, &conf); err != nil {
 		return &conf, fmt.Errorf("failed to load netconf: %v", err)
 	}
+	if err := validateDNSPolicy(&conf); err != nil {
+		return &conf, fmt.Errorf("failed to load netconf: %v", err)
+	}
 	return &conf, nil
 }
 
@@ -131,113 +216,273 @@ func (gc *GenieController) AddPodNetwork(cniArgs *utils.CNIArgs, conf *utils.Gen
 	if err != nil {
 		return nil, fmt.Errorf("CNI Genie internal error at loadArgs: %v", err)
 	}
-	_, _, err = getIdentifiers(cniArgs, k8sArgs)
+	_, _, err = getIdentifiers(cniArgs, k8sArgs, conf)
 	if err != nil {
 		return nil, fmt.Errorf("CNI Genie internal error at getIdentifiers: %v", err)
 	}
 
 	// Get pod annotations
-	podAnnot, err := gc.getPodAnnotationsForCNI(k8sArgs)
+	podAnnot, err := gc.getPodAnnotations(cniArgs, k8sArgs, conf)
 	if err != nil {
 		return nil, fmt.Errorf("Error getting annotations for pod (%s:%s): %v", k8sArgs.K8S_POD_NAMESPACE, k8sArgs.K8S_POD_NAME, err)
 	}
+	if err = checkDefaultNetworkAnnot(podAnnot); err != nil {
+		return nil, err
+	}
 
-	err = gc.Cfg.LoadConfFiles()
+	pluginInfoList, err := gc.resolvePluginInfoList(podAnnot, k8sArgs, conf)
 	if err != nil {
-		return nil, fmt.Errorf("Error loading configuration files from net dir (%s): %v", gc.Cfg.NetDir, err)
+		return nil, err
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie Found configuration files in %s: %v\n", gc.Cfg.NetDir, gc.Cfg.Files)
-	//fmt.Println("Files: ", gc.Cfg.Files)
-	// parse pod annotations for cns types
-	// eg:
-	//    cni: "canal,weave"
-	var pluginInfoList []*utils.PluginInfo
+	if err = gc.assignDevices(pluginInfoList, k8sArgs); err != nil {
+		return nil, err
+	}
+	setRuntimeIfName(pluginInfoList, cniArgs, conf)
+
 	var setStatus SetStatus
 	var statusAnnot string
-	if networkCrdAnnot, ok := podAnnot[NetworkAttachmentDefinitionAnnot]; ok {
-		pluginInfoList, err = gc.parseNetAttachDefAnnot(networkCrdAnnot, k8sArgs)
-		if err != nil {
-			return nil, fmt.Errorf("CNI Genie error at parseNetAttachDefAnnot: %v", err)
-		}
+	if usesNetAttachDefs(podAnnot) {
 		setStatus = setNetAttachStatus
 		statusAnnot = NetworkAttachmentStatusAnnot
-	} else {
-		pluginInfoList, err = gc.parseCNIAnnotations(podAnnot, k8sArgs, conf)
-		if err != nil {
-			return nil, fmt.Errorf("CNI Genie error at ParsePodAnnotations: %v", err)
-		}
-		if len(pluginInfoList) > 1 {
-			setStatus = setGenieStatus
-			statusAnnot = MultiIPPreferencesAnnotation
-		}
+	} else if len(pluginInfoList) > 1 {
+		setStatus = setGenieStatus
+		statusAnnot = MultiIPPreferencesAnnotation
 	}
 
-	result, status, err := gc.addNetwork(pluginInfoList, cniArgs, setStatus)
+	result, status, attached, err := gc.addNetwork(pluginInfoList, cniArgs, setStatus)
 	if err != nil {
 		return nil, err
 	}
 
+	err = gc.saveAttachments(cniArgs.ContainerID, attached)
+	if err != nil {
+		gc.logger().Warningf("Error while saving attachment state for container %s: %v", cniArgs.ContainerID, err)
+	}
+
 	var bytes []byte
 	if status != nil {
 		bytes = getStatusBytes(status)
 	}
 
-	if bytes != nil {
+	// There is no pod object to record the status into in standalone mode
+	if bytes != nil && !gc.Standalone {
 		err = gc.UpdatePodDefinition(statusAnnot, bytes, k8sArgs)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "CNI Genie error while setting pod status(%v): %v:\n", string(bytes), err)
+			gc.logger().Warningf("Error while setting pod status(%v): %v", string(bytes), err)
 		}
 	}
 
 	return result, nil
 }
 
-// DeletePodNetwork deletes pod networking. It has logic to parse each pod
-// definition's annotations. It looks for container networking solutions (CNS)
-// types passed as annotation in pod defintion. For every CNS types, it talks
-// to corresponding CNS object and releases an IP from it's IPAM.
+// DeletePodNetwork deletes pod networking. If an attachment record was saved
+// for the container during ADD, the recorded attachments are deleted.
+// Otherwise, it has logic to parse each pod definition's annotations. It
+// looks for container networking solutions (CNS) types passed as annotation
+// in pod defintion. For every CNS types, it talks to corresponding CNS
+// object and releases an IP from it's IPAM.
 func (gc *GenieController) DeletePodNetwork(cniArgs *utils.CNIArgs, conf *utils.GenieConf) error {
 	k8sArgs, err := loadArgs(cniArgs)
 	if err != nil {
 		return fmt.Errorf("CNI Genie internal error at loadArgs: %v", err)
 	}
-	_, _, err = getIdentifiers(cniArgs, k8sArgs)
+	_, _, err = getIdentifiers(cniArgs, k8sArgs, conf)
 	if err != nil {
 		return fmt.Errorf("CNI Genie internal error at getIdentifiers: %v", err)
 	}
 
-	podAnnot, err := gc.getPodAnnotationsForCNI(k8sArgs)
+	// Attachments recorded during ADD are exactly what needs to be cleaned up,
+	// and they can be replayed even if the pod object is already gone
+	recorded, err := gc.loadAttachments(cniArgs.ContainerID)
+	if err != nil {
+		gc.logger().Warningf("Error while loading attachment state for container %s: %v", cniArgs.ContainerID, err)
+	}
+	if recorded != nil {
+		gc.logger().Infof("Deleting recorded attachments for container %s", cniArgs.ContainerID)
+		err = gc.deleteNetwork(recorded, cniArgs)
+		if err != nil {
+			return err
+		}
+		return gc.removeAttachments(cniArgs.ContainerID)
+	}
+
+	podAnnot, err := gc.getPodAnnotations(cniArgs, k8sArgs, conf)
 	if err != nil {
 		if err_nopod_novar == err.Error() {
 			//Incase of pos container delete, getting pod info will fail. So return success in this case
 			//to ensure complete cleanup of pos container
-			fmt.Fprintf(os.Stderr, "Pod annotations not found during pod delete, proceeding to delete pod")
+			gc.logger().Infof("Pod annotations not found during pod delete, proceeding to delete pod")
 			return nil
 		}
 		return fmt.Errorf("Error getting annotations for pod (%s:%s): %v", k8sArgs.K8S_POD_NAMESPACE, k8sArgs.K8S_POD_NAME, err)
 	}
 
-	err = gc.Cfg.LoadConfFiles()
+	pluginInfoList, err := gc.resolvePluginInfoList(podAnnot, k8sArgs, conf)
+	if err != nil {
+		return err
+	}
+	// Devices may already be released by the kubelet, the delegates are then
+	// left to clean up without them
+	if err = gc.assignDevices(pluginInfoList, k8sArgs); err != nil {
+		gc.logger().Warningf("Error while assigning devices to attachments for delete: %v", err)
+	}
+	setRuntimeIfName(pluginInfoList, cniArgs, conf)
+
+	return gc.deleteNetwork(pluginInfoList, cniArgs)
+}
+
+// CheckPodNetwork checks pod networking. If an attachment record was saved
+// for the container during ADD, the recorded attachments are checked, so that
+// changes of the networks since ADD do not change what is checked. Otherwise,
+// the list of plugins is resolved from the pod as AddPodNetwork does. Every
+// delegate is asked to verify its attachment against the result cached while
+// adding the network, and failures of all the attachments are combined into a
+// single error.
+func (gc *GenieController) CheckPodNetwork(cniArgs *utils.CNIArgs, conf *utils.GenieConf) error {
+	k8sArgs, err := loadArgs(cniArgs)
+	if err != nil {
+		return fmt.Errorf("CNI Genie internal error at loadArgs: %v", err)
+	}
+	_, _, err = getIdentifiers(cniArgs, k8sArgs, conf)
+	if err != nil {
+		return fmt.Errorf("CNI Genie internal error at getIdentifiers: %v", err)
+	}
+
+	recorded, err := gc.loadAttachments(cniArgs.ContainerID)
+	if err != nil {
+		gc.logger().Warningf("Error while loading attachment state for container %s: %v", cniArgs.ContainerID, err)
+	}
+	if recorded != nil {
+		gc.logger().Infof("Checking recorded attachments for container %s", cniArgs.ContainerID)
+		return gc.checkNetwork(recorded, cniArgs)
+	}
+
+	podAnnot, err := gc.getPodAnnotations(cniArgs, k8sArgs, conf)
+	if err != nil {
+		return fmt.Errorf("Error getting annotations for pod (%s:%s): %v", k8sArgs.K8S_POD_NAMESPACE, k8sArgs.K8S_POD_NAME, err)
+	}
+
+	pluginInfoList, err := gc.resolvePluginInfoList(podAnnot, k8sArgs, conf)
+	if err != nil {
+		return err
+	}
+	if err = gc.assignDevices(pluginInfoList, k8sArgs); err != nil {
+		return err
+	}
+	setRuntimeIfName(pluginInfoList, cniArgs, conf)
+
+	return gc.checkNetwork(pluginInfoList, cniArgs)
+}
+
+// Status reports whether genie is ready to add pod networking, i.e. whether
+// the delegates to be used for pods without any network selection are ready.
+func (gc *GenieController) Status(conf *utils.GenieConf) error {
+	err := gc.Cfg.LoadConfFiles()
+	if err != nil {
+		return &types.Error{Code: ErrPluginNotAvailable, Msg: "Error loading configuration files", Details: err.Error()}
+	}
+	pluginInfoList, err := gc.handleNoCniCase(conf)
+	if err != nil {
+		return &types.Error{Code: ErrPluginNotAvailable, Msg: "Default network not available", Details: err.Error()}
+	}
+
+	for _, pluginInfo := range pluginInfoList {
+		gc.fillMandatoryCNIPara(pluginInfo.Config)
+		err = gc.Invoke.InvokeExecStatus(pluginInfo.Config)
+		if err != nil {
+			return &types.Error{Code: ErrPluginNotAvailable, Msg: fmt.Sprintf("Plugin %s not available", pluginInfo.PluginName), Details: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// ResolvePodNetworks resolves the networks requested through the given pod
+// annotations in the same way as AddPodNetwork, but without invoking any
+// delegate. Attachments are returned in the order they would be added, with
+// their interface names assigned.
+func (gc *GenieController) ResolvePodNetworks(podAnnot map[string]string, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) ([]*utils.PluginInfo, error) {
+	if err := checkDefaultNetworkAnnot(podAnnot); err != nil {
+		return nil, err
+	}
+	pluginInfoList, err := gc.resolvePluginInfoList(podAnnot, k8sArgs, conf)
+	if err != nil {
+		return nil, err
+	}
+	if err = assignIfNames(pluginInfoList); err != nil {
+		return nil, err
+	}
+	return pluginInfoList, nil
+}
+
+// ResolveContainerNetworks resolves the networks of the pod of the container
+// identified by cniArgs in the same way as AddPodNetwork, but without invoking
+// any delegate
+func (gc *GenieController) ResolveContainerNetworks(cniArgs *utils.CNIArgs, conf *utils.GenieConf) ([]*utils.PluginInfo, error) {
+	k8sArgs, err := loadArgs(cniArgs)
+	if err != nil {
+		return nil, fmt.Errorf("CNI Genie internal error at loadArgs: %v", err)
+	}
+	podAnnot, err := gc.getPodAnnotations(cniArgs, k8sArgs, conf)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting annotations for pod (%s:%s): %v", k8sArgs.K8S_POD_NAMESPACE, k8sArgs.K8S_POD_NAME, err)
+	}
+
+	return gc.ResolvePodNetworks(podAnnot, k8sArgs, conf)
+}
+
+// resolvePluginInfoList loads the configuration files from net dir and
+// selects the attachments as per the pod annotations
+func (gc *GenieController) resolvePluginInfoList(podAnnot map[string]string, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) ([]*utils.PluginInfo, error) {
+	err := gc.Cfg.LoadConfFiles()
 	if err != nil {
-		return fmt.Errorf("Error loading configuration files from net dir (%s): %v", gc.Cfg.NetDir, err)
+		return nil, fmt.Errorf("Error loading configuration files from net dir (%s): %v", gc.Cfg.NetDir, err)
 	}
-	// parse pod annotations for cns types
-	// eg:
-	//    cni: "canal,weave"
+	gc.logger().Debugf("Found configuration files in %s: %v", gc.Cfg.NetDir, gc.Cfg.Files)
+
+	return gc.getPluginInfoList(podAnnot, k8sArgs, conf)
+}
+
+// getPluginInfoList resolves the list of plugins to be used for the pod
+// from its network selection annotations.
+func (gc *GenieController) getPluginInfoList(podAnnot map[string]string, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) ([]*utils.PluginInfo, error) {
 	var pluginInfoList []*utils.PluginInfo
-	if networkCrdAnnot, ok := podAnnot[NetworkAttachmentDefinitionAnnot]; ok {
-		pluginInfoList, err = gc.parseNetAttachDefAnnot(networkCrdAnnot, k8sArgs)
+	var err error
+	if usesNetAttachDefs(podAnnot) {
+		pluginInfoList, err = gc.parseNetAttachDefAnnot(podAnnot, k8sArgs, conf)
 		if err != nil {
-			return fmt.Errorf("CNI Genie error at parseNetAttachDefAnnot: %v", err)
+			return nil, fmt.Errorf("CNI Genie error at parseNetAttachDefAnnot: %v", err)
 		}
 	} else {
 		pluginInfoList, err = gc.parseCNIAnnotations(podAnnot, k8sArgs, conf)
 		if err != nil {
-			return fmt.Errorf("CNI Genie error at ParsePodAnnotations: %v", err)
+			return nil, fmt.Errorf("CNI Genie error at ParsePodAnnotations: %v", err)
+		}
+		if annot, ok := podAnnot[DefaultRouteAnnot]; ok {
+			if err = setDefaultRouteFromAnnot(pluginInfoList, annot); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	return gc.deleteNetwork(pluginInfoList, cniArgs)
+	if _, err = getDefaultRouteOwner(pluginInfoList); err != nil {
+		return nil, err
+	}
+
+	// Runtime config passed to genie (eg: port mappings of the pod) belongs
+	// to the first attachment, unless requested explicitly for it
+	if len(conf.RuntimeConfig) > 0 && len(pluginInfoList) > 0 {
+		if pluginInfoList[0].CapabilityArgs == nil {
+			pluginInfoList[0].CapabilityArgs = make(map[string]interface{})
+		}
+		for capability, arg := range conf.RuntimeConfig {
+			if _, ok := pluginInfoList[0].CapabilityArgs[capability]; !ok {
+				pluginInfoList[0].CapabilityArgs[capability] = arg
+			}
+		}
+	}
+
+	return pluginInfoList, nil
 }
 
 func getReservedIfnames(pluginElems []*utils.PluginInfo) (map[int64]bool, error) {
@@ -263,6 +508,20 @@ func getReservedIfnames(pluginElems []*utils.PluginInfo) (map[int64]bool, error)
 	return reserved, nil
 }
 
+// assignIfNames assigns interface names to the attachments not requesting
+// any, skipping the names requested by the others
+func assignIfNames(pluginElems []*utils.PluginInfo) error {
+	reservedIfNames, err := getReservedIfnames(pluginElems)
+	if err != nil {
+		return err
+	}
+	currIndex := -1
+	for _, pluginElem := range pluginElems {
+		pluginElem.IfName, currIndex = getIntfName(pluginElem.IfName, reservedIfNames, currIndex)
+	}
+	return nil
+}
+
 func getIntfName(intfName string, reserved map[int64]bool, curr int) (string, int) {
 	if intfName == "" {
 		for curr++; true == reserved[int64(curr)]; curr++ {
@@ -273,24 +532,41 @@ func getIntfName(intfName string, reserved map[int64]bool, curr int) (string, in
 	return intfName, curr
 }
 
-func parseResult(setStatus SetStatus, ch chan sendCh) (types.Result, interface{}) {
+// parseResult merges the results of all the delegates. Results are merged in
+// the 1.x format, which is a superset of the older result versions, and can
+// later be converted to the version requested by the runtime.
+func (gc *GenieController) parseResult(setStatus SetStatus, ch chan sendCh) (types.Result, interface{}) {
 	var status interface{}
-	var endResult *current.Result
+	var endResult *types100.Result
+	var dnsEntries []dnsEntry
 	for r := range ch {
-		currentResult, err := current.NewResultFromResult(r.res)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "CNI Genie error converting result to current version for plugin %s: %v\n", r.name, err)
+		if r.err != nil {
+			if setStatus != nil {
+				status = setStatus(types100.Result{}, r.name, r.ifName, false, r.err, status)
+			}
 			continue
 		}
-		endResult, err = mergeWithResult(currentResult, endResult)
+		currentResult, err := types100.NewResultFromResult(r.res)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "CNI Genie error merging current result for plugin %s with end result: %v\n", r.res, err)
+			gc.logger().Warningf("Error converting result to current version for plugin %s: %v", r.name, err)
 			continue
 		}
+		// The status is set before merging, so that it carries the dns
+		// configuration of this attachment alone
 		if setStatus != nil {
-			status = setStatus(*currentResult, r.name, r.ifName, status)
+			status = setStatus(*currentResult, r.name, r.ifName, r.isDefault, nil, status)
 		}
+		dnsEntries = append(dnsEntries, dnsEntry{r.name, r.ifName, r.isDefault, currentResult.DNS})
+		endResult, err = mergeWithResult(currentResult, endResult)
+		if err != nil {
+			gc.logger().Warningf("Error merging current result for plugin %s with end result: %v", r.name, err)
+			continue
+		}
+	}
+	if endResult == nil {
+		return nil, interface{}(status)
 	}
+	endResult.DNS = gc.mergeDNS(dnsEntries)
 	return endResult, interface{}(status)
 }
 
@@ -298,85 +574,124 @@ func (gc *GenieController) fillMandatoryCNIPara(config *libcni.NetworkConfigList
 	// This function is used to check if any cni mandatory parameters are missing. If missing, they will be filled
 	//with default parameters
 
-	// Check for cni version field
+	// Check for cni version field. The version requested by the runtime is
+	// preferred over the default value
 	if config.CNIVersion == "" {
-		fmt.Fprintf(os.Stderr, "CNI Version is missing, filling with default value: %v\n", DefaultCNIVersion)
-		config.CNIVersion = DefaultCNIVersion
+		cniVersion := gc.CNIVersion
+		if cniVersion == "" {
+			cniVersion = DefaultCNIVersion
+		}
+		gc.logger().Debugf("CNI Version is missing, filling with value: %v", cniVersion)
+		config.CNIVersion = cniVersion
 	}
 
 	return
 }
 
-func (gc *GenieController) addNetwork(pluginElements []*utils.PluginInfo, cniArgs *utils.CNIArgs, setStatus SetStatus) (types.Result, interface{}, error) {
+// addNetwork attaches the pod to all the networks in pluginElements and returns
+// the merged result, the status and the list of attachments which succeeded.
+// Failure in attaching an optional network is recorded in the status and the
+// remaining networks are attached. Failure in attaching any other network rolls
+// back all the attachments done so far, including the failed one, as the failed
+// delegate might have partly done the attachment (eg: allocated an IP).
+func (gc *GenieController) addNetwork(pluginElements []*utils.PluginInfo, cniArgs *utils.CNIArgs, setStatus SetStatus) (types.Result, interface{}, []*utils.PluginInfo, error) {
 	// Collect the result in this variable - this is ultimately what gets "returned" by this function by printing
 	// it to stdout.
 	var endResult types.Result
 	var result types.Result
 
-	reservedIfNames, err := getReservedIfnames(pluginElements)
+	if err := assignIfNames(pluginElements); err != nil {
+		return nil, nil, nil, err
+	}
+	defaultRouteOwner, err := getDefaultRouteOwner(pluginElements)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	var currIndex int = -1
 	var status interface{}
 	ch := make(chan sendCh, len(pluginElements))
 
 	var wg sync.WaitGroup
+	wg.Add(1)
 	go func(SetStatus, chan sendCh) {
-		wg.Add(1)
 		defer wg.Done()
-		endResult, status = parseResult(setStatus, ch)
+		endResult, status = gc.parseResult(setStatus, ch)
 	}(setStatus, ch)
 
-	var i int
-	var pluginElement *utils.PluginInfo
-	for i, pluginElement = range pluginElements {
-		pluginElement.IfName, currIndex = getIntfName(pluginElement.IfName, reservedIfNames, currIndex)
-		fmt.Fprintf(os.Stderr, "CNI Genie adding network for plugin element: %+v\n", *pluginElement)
+	var attached []*utils.PluginInfo
+	var attachedResults []types.Result
+	var failed *utils.PluginInfo
+	for i, pluginElement := range pluginElements {
+		log := gc.attachmentLog(pluginElement)
+		log.Debugf("Adding network for plugin element: %+v", *pluginElement)
 		// fetches an IP from corresponding CNS IPAM and returns result object
 		result, err = gc.delegateAddNetwork(pluginElement, cniArgs)
-		fmt.Fprintf(os.Stderr, "CNI Genie addNetwork (%s) err: %v; result: %v\n", pluginElement.PluginName, err, result)
 		if err != nil {
-			break
+			log.Errorf("Error adding network: %v", err)
+		} else {
+			log.Infof("Network added, result: %v", result)
 		}
 
-		if pluginElement.ValidateRes != nil {
+		if err == nil && pluginElement.ValidateRes != nil {
 			err = pluginElement.ValidateRes(result, pluginElement.ValidationParams)
-			if err != nil {
+		}
+
+		if err == nil && defaultRouteOwner >= 0 {
+			result, err = gc.fixDefaultRoute(pluginElement, result, cniArgs)
+		}
+
+		if err != nil {
+			gc.recordAttachFailed(cniArgs, pluginElement, err)
+			if !pluginElement.Optional {
+				failed = pluginElement
 				break
 			}
+			log.Warningf("Skipping optional network which failed to attach: %v", err)
+			_ = gc.deleteNetwork([]*utils.PluginInfo{pluginElement}, cniArgs)
+			ch <- sendCh{name: pluginElement.PluginName, ifName: pluginElement.IfName, err: err}
+			continue
 		}
-		ch <- sendCh{pluginElement.PluginName, pluginElement.IfName, result}
-		i++
+
+		// Without an attachment requesting for it, the default route is the
+		// one of the first attachment
+		isDefault := i == defaultRouteOwner || (defaultRouteOwner < 0 && len(attached) == 0)
+		attached = append(attached, pluginElement)
+		attachedResults = append(attachedResults, result)
+		ch <- sendCh{name: pluginElement.PluginName, ifName: pluginElement.IfName, isDefault: isDefault, res: result}
 	}
 	close(ch)
-	if i < len(pluginElements) {
-		_ = gc.deleteNetwork(pluginElements[:i], cniArgs)
-		return nil, nil, err
+	if failed != nil {
+		gc.attachmentLog(failed).Errorf("Rolling back all the attachments as network failed to attach: %v", err)
+		_ = gc.deleteNetwork(append(attached, failed), cniArgs)
+		return nil, nil, nil, err
+	}
+
+	// The attachments are recorded on the pod once they are not rolled back
+	for i, pluginElement := range attached {
+		gc.recordAttached(cniArgs, pluginElement, attachedResults[i])
 	}
 
 	wg.Wait()
-	return endResult, status, nil
+	if len(attached) == 0 {
+		return nil, nil, nil, fmt.Errorf("CNI Genie failed to attach any of the requested networks")
+	}
+
+	return endResult, status, attached, nil
 }
 
 // addNetwork is a core function that delegates call to pull IP from a Container Networking Solution (CNI Plugin)
 func (gc *GenieController) delegateAddNetwork(pluginInfo *utils.PluginInfo, cniArgs *utils.CNIArgs) (types.Result, error) {
-	if err := os.Unsetenv("CNI_IFNAME"); err != nil {
-		fmt.Fprintf(os.Stderr, "CNI Genie Error while unsetting env variable CNI_IFNAME: %v\n", err)
-	}
-	if err := os.Unsetenv("CNI_ARGS"); err != nil {
-		fmt.Fprintf(os.Stderr, "CNI Genie Error while unsetting env variable CNI_Args: %v\n", err)
-	}
-	rtConf, err := runtimeConf(cniArgs, pluginInfo.IfName, pluginInfo.OptionalArgs)
+	rtConf, err := runtimeConf(cniArgs, pluginInfo)
 	if err != nil {
 		return nil, fmt.Errorf("Error generating runtime conf: %v", err)
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie runtime conf for plugin (%s): %v\n", pluginInfo.PluginName, *rtConf)
+	gc.attachmentLog(pluginInfo).Debugf("Runtime conf: %v", *rtConf)
 
 	gc.fillMandatoryCNIPara(pluginInfo.Config)
 
+	start := time.Now()
 	res, err := gc.Invoke.InvokeExecAdd(pluginInfo.Config, rtConf)
+	observeDelegate(pluginInfo, VerbAdd, start, err)
 	if err != nil {
 		return nil, fmt.Errorf("Error from cni: %v", err)
 	}
@@ -392,12 +707,13 @@ func (gc *GenieController) deleteNetwork(pluginElements []*utils.PluginInfo, cni
 	for i := len(pluginElements) - 1; i >= 0; i-- {
 		pluginElement := pluginElements[i]
 		pluginElement.IfName, currIndex = getIntfName(pluginElement.IfName, reservedIfNames, currIndex)
-		fmt.Fprintf(os.Stderr, "CNI Genie deleting network for plugin %s\n", pluginElement.PluginName)
+		log := gc.attachmentLog(pluginElement)
+		log.Infof("Deleting network")
 		// releases an IP from corresponding CNS IPAM and returns error if any exception
 		err := gc.delegateDelNetwork(pluginElement, cniArgs)
 		if err != nil {
 			cnierr = err
-			fmt.Fprintf(os.Stderr, "CNI Genie Error while deleting network (%s): %v\n", pluginElement.PluginName, err)
+			log.Errorf("Error while deleting network: %v", err)
 			continue
 		}
 	}
@@ -406,21 +722,82 @@ func (gc *GenieController) deleteNetwork(pluginElements []*utils.PluginInfo, cni
 		return cnierr
 	}
 
-	fmt.Fprintln(os.Stderr, "CNI Genie deleteNetwork successful")
+	gc.logger().Infof("deleteNetwork successful")
 	return nil
 }
 
 func (gc *GenieController) delegateDelNetwork(pluginInfo *utils.PluginInfo, cniArgs *utils.CNIArgs) error {
-	rtConf, err := runtimeConf(cniArgs, pluginInfo.IfName, pluginInfo.OptionalArgs)
+	rtConf, err := runtimeConf(cniArgs, pluginInfo)
 	if err != nil {
 		return fmt.Errorf("CNI Genie couldn't convert cniArgs to RuntimeConf: %v", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "CNI Genie runtime conf for plugin (%s): %v\n", pluginInfo.PluginName, *rtConf)
+	gc.attachmentLog(pluginInfo).Debugf("Runtime conf: %v", *rtConf)
 
 	gc.fillMandatoryCNIPara(pluginInfo.Config)
 
+	start := time.Now()
 	err = gc.Invoke.InvokeExecDel(pluginInfo.Config, rtConf)
+	observeDelegate(pluginInfo, VerbDel, start, err)
+	if err != nil {
+		return fmt.Errorf("Error from cni: %v", err)
+	}
+
+	return nil
+}
+
+// checkNetwork is a core function that delegates call to verify the attachment done by a Container Networking Solution (CNI Plugin)
+func (gc *GenieController) checkNetwork(pluginElements []*utils.PluginInfo, cniArgs *utils.CNIArgs) error {
+	reservedIfNames, err := getReservedIfnames(pluginElements)
+	if err != nil {
+		return err
+	}
+	currIndex := -1
+	var failed []string
+	for _, pluginElement := range pluginElements {
+		pluginElement.IfName, currIndex = getIntfName(pluginElement.IfName, reservedIfNames, currIndex)
+		log := gc.attachmentLog(pluginElement)
+		log.Infof("Checking network")
+		err := gc.delegateCheckNetwork(pluginElement, cniArgs)
+		if err != nil && pluginElement.Optional {
+			// Optional networks may have failed to attach during ADD
+			log.Warningf("Ignoring check failure of optional network: %v", err)
+		} else if err != nil {
+			log.Errorf("Error while checking network: %v", err)
+			failed = append(failed, fmt.Sprintf("%s@%s: %v", pluginElement.PluginName, pluginElement.IfName, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("CNI Genie check failed for attachment(s): %s", strings.Join(failed, "; "))
+	}
+
+	gc.logger().Infof("checkNetwork successful")
+	return nil
+}
+
+func (gc *GenieController) delegateCheckNetwork(pluginInfo *utils.PluginInfo, cniArgs *utils.CNIArgs) error {
+	rtConf, err := runtimeConf(cniArgs, pluginInfo)
+	if err != nil {
+		return fmt.Errorf("CNI Genie couldn't convert cniArgs to RuntimeConf: %v", err)
+	}
+
+	gc.fillMandatoryCNIPara(pluginInfo.Config)
+
+	// CHECK is supported only from cni version 0.4.0 onwards. Attachments
+	// with older configuration versions can not be verified by the delegate.
+	gtet, err := version.GreaterThanOrEqualTo(pluginInfo.Config.CNIVersion, CheckMinCNIVersion)
+	if err != nil {
+		return fmt.Errorf("Error comparing cni version %s: %v", pluginInfo.Config.CNIVersion, err)
+	}
+	if !gtet {
+		gc.attachmentLog(pluginInfo).Infof("Skipping check for cni version %s", pluginInfo.Config.CNIVersion)
+		return nil
+	}
+
+	start := time.Now()
+	err = gc.Invoke.InvokeExecCheck(pluginInfo.Config, rtConf)
+	observeDelegate(pluginInfo, VerbCheck, start, err)
 	if err != nil {
 		return fmt.Errorf("Error from cni: %v", err)
 	}
@@ -433,8 +810,8 @@ func (gc *GenieController) UpdatePodDefinition(statusAnnot string, status []byte
 	annot := fmt.Sprintf(
 		`{"metadata":{"annotations":{"%s":%s}}}`, statusAnnot, strconv.Quote(string(status)))
 
-	fmt.Fprintf(os.Stderr, "CNI Genie pod.Annotations[%s] after = %s\n", statusAnnot, annot)
-	_, err := gc.Kc.CoreV1().Pods(string(k8sArgs.K8S_POD_NAMESPACE)).Patch(string(k8sArgs.K8S_POD_NAME), api.StrategicMergePatchType, []byte(annot))
+	gc.logger().Debugf("Patching pod annotation %s: %s", statusAnnot, annot)
+	_, err := gc.Kc.PatchPod(context.TODO(), string(k8sArgs.K8S_POD_NAME), string(k8sArgs.K8S_POD_NAMESPACE), api.StrategicMergePatchType, []byte(annot))
 	if err != nil {
 		return fmt.Errorf("CNI Genie Error updating pod = %s", err)
 	}
@@ -455,33 +832,28 @@ func (gc *GenieController) getPodAnnotationsForCNI(k8sArgs *utils.K8sArgs) (map[
 	if err != nil {
 		args := k8sArgs.K8S_ANNOT
 		if len(args) == 0 {
-			fmt.Fprintln(os.Stderr, "CNI Genie no env var and no pod")
+			// Only a pod which is gone is reported as such, failures to reach
+			// the api server are returned for the runtime to retry
+			if !client.IsNotFound(err) {
+				return annot, err
+			}
+			gc.logger().Errorf("No env var and no pod")
 			return annot, errors.New(err_nopod_novar)
 		}
-		fmt.Fprintf(os.Stderr, "CNI Genie env  annot val: %s\n", args)
+		gc.logger().Debugf("Annotations from env: %s", args)
 		envAnnot := map[string]string{}
 		errEnv := json.Unmarshal([]byte(args), &envAnnot)
 		if errEnv != nil {
-			fmt.Fprintf(os.Stderr, "CNI Genie error getting annotations from pod: `%v` and Error Using annotations from ENV: `%v`\n", err, errEnv)
+			gc.logger().Warningf("Error getting annotations from pod: `%v` and Error Using annotations from ENV: `%v`", err, errEnv)
 			return annot, err
 		}
 		annot = envAnnot
-		fmt.Fprintf(os.Stderr, "CNI Genie error getting annotations from pod: %v. Using annotations from ENV: annot= %v\n", err, annot)
+		gc.logger().Warningf("Error getting annotations from pod: %v. Using annotations from ENV: annot= %v", err, annot)
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie annot= [%s]\n", annot)
+	gc.logger().Debugf("Pod annotations: %v", annot)
 
-	return annot, err
-
-}
+	return annot, nil
 
-// ParsePodAnnotationsForNetworks does following tasks
-// - get pod definition
-// - parses annotation section for "networks"
-// - Returns string
-func (gc *GenieController) parsePodAnnotationsForNetworks(k8sArgs *utils.K8sArgs) string {
-	annot, _ := gc.getK8sPodAnnotations(k8sArgs)
-	networks := annot["networks"]
-	return networks
 }
 
 // List all configuration files in the given directory with specified extensions
@@ -490,7 +862,7 @@ func getConfFiles(dir string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error listing configuration files in %s: %v", dir, err)
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie files: %v\n", files)
+	logging.Debugf("Configuration files: %v", files)
 	return files, err
 }
 
@@ -499,9 +871,14 @@ func (gc *GenieController) getPluginInfo(plugins []string) ([]*utils.PluginInfo,
 	pluginInfoList := make([]*utils.PluginInfo, len(plugins))
 	pluginMap := make(map[string]map[bool][]int)
 	ifNameMap := make(map[int]string)
+	optionalMap := make(map[int]bool)
 	for i := range plugins {
 		pluginName := strings.TrimSpace(plugins[i])
 		ifName := ""
+		if strings.HasSuffix(pluginName, utils.OptionalNetworkSuffix) {
+			optionalMap[i] = true
+			pluginName = strings.TrimSpace(strings.TrimSuffix(pluginName, utils.OptionalNetworkSuffix))
+		}
 		if true == strings.Contains(pluginName, utils.IfNameDelimiter) {
 			netNIfName := strings.Split(pluginName, utils.IfNameDelimiter)
 			pluginName = strings.TrimSpace(netNIfName[0])
@@ -515,7 +892,7 @@ func (gc *GenieController) getPluginInfo(plugins []string) ([]*utils.PluginInfo,
 		}
 		ifNameMap[i] = ifName
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie plugin map: %+v\n", pluginMap)
+	gc.logger().Debugf("Plugin map: %+v", pluginMap)
 	for _, file := range gc.Cfg.Files {
 		// Parse file name and check whether it matches any of the requested plugins
 		// In conf file name, the plugin name should be followed by a '.' and
@@ -530,15 +907,17 @@ func (gc *GenieController) getPluginInfo(plugins []string) ([]*utils.PluginInfo,
 			pluginMap[pluginName][true] = indices
 			config, err := gc.Cfg.ParseCNIConfFromFile(file)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "CNI Genie Error getting CNI config from conf file (%s) for user requested plugin (%s): %v\n", file, pluginName, err)
+				gc.logger().Warningf("Error getting CNI config from conf file (%s) for user requested plugin (%s): %v", file, pluginName, err)
 				continue
 			}
-			fmt.Fprintf(os.Stderr, "CNI Genie found configuration file (%s) for plugin %s\n", file, pluginName)
+			gc.logger().Infof("Found configuration file (%s) for plugin %s", file, pluginName)
 			for _, index := range indices {
 				pluginInfoList[index-1] = &utils.PluginInfo{
 					PluginName: pluginName,
 					Config:     config,
 					IfName:     ifNameMap[index-1],
+					Optional:   optionalMap[index-1],
+					ConfSource: file,
 				}
 			}
 			delete(pluginMap, pluginName)
@@ -556,7 +935,7 @@ func (gc *GenieController) getPluginInfo(plugins []string) ([]*utils.PluginInfo,
 		if _, ok := v[true]; ok {
 			return nil, fmt.Errorf("No valid configuration file present for plugin %s", plugin)
 		}
-		config, err := gc.generateConf(plugin)
+		config, source, err := gc.generateConf(plugin)
 		if err != nil {
 			return nil, err
 		}
@@ -564,6 +943,8 @@ func (gc *GenieController) getPluginInfo(plugins []string) ([]*utils.PluginInfo,
 			pluginInfoList[index-1] = &utils.PluginInfo{
 				PluginName: plugin,
 				Config:     config,
+				Optional:   optionalMap[index-1],
+				ConfSource: source,
 			}
 		}
 	}
@@ -571,9 +952,11 @@ func (gc *GenieController) getPluginInfo(plugins []string) ([]*utils.PluginInfo,
 	return pluginInfoList, nil
 }
 
-func (gc *GenieController) loadPluginConfig(plugin string) (*libcni.NetworkConfigList, error) {
+// loadPluginConfig loads the configuration for a plugin from net dir, or
+// generates a default one. It also returns where the configuration comes from.
+func (gc *GenieController) loadPluginConfig(plugin string) (*libcni.NetworkConfigList, string, error) {
 	if plugin = strings.TrimSpace(plugin); plugin == "" {
-		return nil, fmt.Errorf("Plugin name is empty")
+		return nil, "", fmt.Errorf("Plugin name is empty")
 	}
 
 	found := false
@@ -586,22 +969,18 @@ func (gc *GenieController) loadPluginConfig(plugin string) (*libcni.NetworkConfi
 			found = true
 			config, err := gc.Cfg.ParseCNIConfFromFile(file)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "CNI Genie Error getting CNI config from conf file (%s) for user requested plugin (%s): %v\n", file, plugin, err)
+				gc.logger().Warningf("Error getting CNI config from conf file (%s) for user requested plugin (%s): %v", file, plugin, err)
 				continue
 			}
-			return config, nil
+			return config, file, nil
 		}
 	}
 
 	if found == true {
-		return nil, fmt.Errorf("No valid configuration file present for plugin %s", plugin)
-	}
-	config, err := gc.generateConf(plugin)
-	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("No valid configuration file present for plugin %s", plugin)
 	}
 
-	return config, nil
+	return gc.generateConf(plugin)
 }
 
 //  parseCNIAnnotations parses pod yaml defintion for "cni" annotations.
@@ -612,7 +991,7 @@ func (gc *GenieController) parseCNIAnnotations(annot map[string]string, k8sArgs
 	_, annotExists := annot["cni"]
 
 	if !annotExists {
-		fmt.Fprintf(os.Stderr, "CNI Genie no annotations is given! Using default plugins \n")
+		gc.logger().Infof("No cni annotation given, using default plugins")
 		finalPluginInfos, err = gc.handleNoCniCase(conf)
 		if err != nil {
 			return nil, err
@@ -623,28 +1002,30 @@ func (gc *GenieController) parseCNIAnnotations(annot map[string]string, k8sArgs
 		if err != nil {
 			return nil, err
 		}
-	} else if networksAnnot := gc.parsePodAnnotationsForNetworks(k8sArgs); networksAnnot != "" {
-		fmt.Fprintf(os.Stderr, "CNI Genie networks annotation passed\n")
+	} else if networksAnnot := strings.TrimSpace(annot["networks"]); networksAnnot != "" {
+		gc.logger().Infof("Networks annotation passed")
 
 		var err error
 
-		finalPluginInfos, err = gc.getPluginInfoFromNwAnnot(strings.TrimSpace(annot["networks"]), string(k8sArgs.K8S_POD_NAMESPACE))
+		finalPluginInfos, err = gc.getPluginInfoFromNwAnnot(networksAnnot, string(k8sArgs.K8S_POD_NAMESPACE))
 		if err != nil {
 			return finalPluginInfos, fmt.Errorf("CNI Genie GetPluginInfoFromNwAnnot err= %v\n", err)
 		}
 	} else {
-		fmt.Fprintf(os.Stderr, "CNI Genie Inside no cni annotation, calling cAdvisor client to retrieve ideal network solution\n")
+		gc.logger().Infof("Empty cni annotation, calling cAdvisor client to retrieve ideal network solution")
 		cns, err := gc.GetCNSOrderByNetworkBandwith(conf)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "CNI Genie GetCNSOrderByNetworkBandwith err= %v\n", err)
+			gc.logger().Errorf("GetCNSOrderByNetworkBandwith err= %v", err)
 			return finalPluginInfos, fmt.Errorf("CNI Genie failed to retrieve CNS list from cAdvisor = %v", err)
 		}
-		fmt.Fprintf(os.Stderr, "CNI Genie cns= %v\n", cns)
+		gc.logger().Infof("CAdvisor selected network solution: %v", cns)
 
-		cni := fmt.Sprintf(`{"metadata":{"annotations":{"cni":"%s"}}}`, cns)
-		_, err = gc.Kc.PatchPod(string(k8sArgs.K8S_POD_NAME), string(k8sArgs.K8S_POD_NAMESPACE), api.StrategicMergePatchType, []byte(cni))
-		if err != nil {
-			return finalPluginInfos, fmt.Errorf("CNI Genie Error updating pod = %s", err)
+		if !gc.DryRun && !gc.Standalone {
+			cni := fmt.Sprintf(`{"metadata":{"annotations":{"cni":"%s"}}}`, cns)
+			_, err = gc.Kc.PatchPod(context.TODO(), string(k8sArgs.K8S_POD_NAME), string(k8sArgs.K8S_POD_NAMESPACE), api.StrategicMergePatchType, []byte(cni))
+			if err != nil {
+				return finalPluginInfos, fmt.Errorf("CNI Genie Error updating pod = %s", err)
+			}
 		}
 
 		finalPluginInfos, err = gc.getPluginInfo([]string{cns})
@@ -653,13 +1034,21 @@ func (gc *GenieController) parseCNIAnnotations(annot map[string]string, k8sArgs
 		}
 	}
 
-	fmt.Fprintf(os.Stderr, "CNI Genie length of finalPluginInfos= %v\n", len(finalPluginInfos))
+	// Without the annotation, the default networks are added by handleNoCniCase
+	if annotExists {
+		finalPluginInfos, err = gc.appendDefaultNetworks(finalPluginInfos, conf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	gc.logger().Debugf("Number of plugins selected: %v", len(finalPluginInfos))
 	return finalPluginInfos, nil
 }
 
-// checkPluginBinary checks for existence of plugin binary file
-func checkPluginBinary(cniName string) error {
-	binaries, err := ioutil.ReadDir(DefaultPluginDir)
+// checkPluginBinary checks for existence of plugin binary file in bin dir
+func (gc *GenieController) checkPluginBinary(cniName string) error {
+	binaries, err := gc.Cfg.ReadDir(gc.Cfg.BinDir)
 	if err != nil {
 		return fmt.Errorf("Error while checking binary file for plugin %s: %v", cniName, err)
 	}
@@ -669,15 +1058,16 @@ func checkPluginBinary(cniName string) error {
 			return nil
 		}
 	}
-	return fmt.Errorf("Corresponding binary for user requested plugin (%s) is not present in plugin directory (%s)", cniName, DefaultPluginDir)
+	return fmt.Errorf("Corresponding binary for user requested plugin (%s) is not present in plugin directory (%s)", cniName, gc.Cfg.BinDir)
 }
 
-// createConfIfBinaryExists checks for the binary file for a cni type and creates the conf if binary exists
-func (gc *GenieController) createConfIfBinaryExists(cniName string) (*libcni.NetworkConfigList, error) {
+// createConfIfBinaryExists checks for the binary file for a cni type and creates the conf if binary exists.
+// It also returns where the configuration comes from.
+func (gc *GenieController) createConfIfBinaryExists(cniName string) (*libcni.NetworkConfigList, string, error) {
 	// Check for the corresponding binary file.
 	// If binary is not present, then do not create the conf file
-	if err := checkPluginBinary(cniName); err != nil {
-		return nil, err
+	if err := gc.checkPluginBinary(cniName); err != nil {
+		return nil, "", err
 	}
 
 	var pluginObj interface{}
@@ -692,26 +1082,29 @@ func (gc *GenieController) createConfIfBinaryExists(cniName string) (*libcni.Net
 		pluginObj = plugins.GetSriovConfig()
 		break
 	default:
-		return nil, fmt.Errorf("Configuration file is missing from cni directory (%s) for user requested plugin: %s", DefaultNetDir, cniName)
+		return nil, "", fmt.Errorf("Configuration file is missing from cni directory (%s) for user requested plugin: %s", DefaultNetDir, cniName)
 	}
 
 	confBytes, err := json.MarshalIndent(pluginObj, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("Error while marshalling configuration object for plugin %s: %v", cniName, err)
+		return nil, "", fmt.Errorf("Error while marshalling configuration object for plugin %s: %v", cniName, err)
 	}
 
-	err = gc.Cfg.CreateConfFile(cniName, confBytes)
+	confList, err := gc.Cfg.ConfListFromConfBytes(confBytes)
 	if err != nil {
-		return nil, fmt.Errorf("Error placing conf file for plugin %s: %v", cniName, err)
+		return nil, "", err
+	}
+	if gc.DryRun {
+		return confList, "default configuration (not placed in dry run)", nil
 	}
 
-	confList, err := gc.Cfg.ConfListFromConfBytes(confBytes)
+	err = gc.Cfg.CreateConfFile(cniName, confBytes)
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("Error placing conf file for plugin %s: %v", cniName, err)
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie Placed default conf file for cni type %s.\n", cniName)
+	gc.logger().Infof("Placed default conf file for cni type %s.", cniName)
 
-	return confList, nil
+	return confList, gc.Cfg.Files[len(gc.Cfg.Files)-1], nil
 }
 
 func insertSubnet(conf map[string]interface{}, subnet string) {
@@ -747,7 +1140,7 @@ func useCustomSubnet(confdata []byte, subnet string) ([]byte, error) {
 	return confbytes, nil
 }
 
-func (gc *GenieController) generateConf(cniName string) (*libcni.NetworkConfigList, error) {
+func (gc *GenieController) generateConf(cniName string) (*libcni.NetworkConfigList, string, error) {
 	supportedPlugins := strings.Split(SupportedPlugins, ",")
 	var cnt int
 	for _, plugin := range supportedPlugins {
@@ -757,7 +1150,7 @@ func (gc *GenieController) generateConf(cniName string) (*libcni.NetworkConfigLi
 		cnt++
 	}
 	if cnt >= len(supportedPlugins) {
-		return nil, fmt.Errorf("User requested for unsupported plugin type %s. Only supported are %s", cniName, SupportedPlugins)
+		return nil, "", fmt.Errorf("User requested for unsupported plugin type %s. Only supported are %s", cniName, SupportedPlugins)
 	}
 
 	return gc.createConfIfBinaryExists(cniName)
@@ -765,36 +1158,39 @@ func (gc *GenieController) generateConf(cniName string) (*libcni.NetworkConfigLi
 
 func loadArgs(cniArgs *utils.CNIArgs) (*utils.K8sArgs, error) {
 	k8sArgs := &utils.K8sArgs{}
-	err := types.LoadArgs(cniArgs.Args, k8sArgs)
+	err := types.LoadArgs(stripSelectionLabels(cniArgs.Args), k8sArgs)
 	if err != nil {
 		return k8sArgs, err
 	}
 	return k8sArgs, nil
 }
 
-func getIdentifiers(cniArgs *utils.CNIArgs, k8sArgs *utils.K8sArgs) (workloadID string, orchestratorID string, err error) {
+func getIdentifiers(cniArgs *utils.CNIArgs, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) (workloadID string, orchestratorID string, err error) {
 	// Determine if running under k8s by checking the CNI args
 	if string(k8sArgs.K8S_POD_NAMESPACE) != "" && string(k8sArgs.K8S_POD_NAME) != "" {
 		workloadID = fmt.Sprintf("%s.%s", k8sArgs.K8S_POD_NAMESPACE, k8sArgs.K8S_POD_NAME)
 		orchestratorID = "k8s"
+	} else if isMesos(conf) {
+		workloadID = cniArgs.ContainerID
+		orchestratorID = "mesos"
 	} else {
 		workloadID = cniArgs.ContainerID
 		orchestratorID = "cni"
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie workloadID= %s\n", workloadID)
-	fmt.Fprintf(os.Stderr, "CNI Genie orchestratorID= %s\n", orchestratorID)
+	logging.Debugf("WorkloadID= %s", workloadID)
+	logging.Debugf("OrchestratorID= %s", orchestratorID)
 	return workloadID, orchestratorID, nil
 }
 
 func (gc *GenieController) getK8sPodAnnotations(k8sArgs *utils.K8sArgs) (map[string]string, error) {
-	pod, err := gc.Kc.GetPod(string(k8sArgs.K8S_POD_NAME), string(k8sArgs.K8S_POD_NAMESPACE))
+	pod, err := gc.Kc.GetPod(context.TODO(), string(k8sArgs.K8S_POD_NAME), string(k8sArgs.K8S_POD_NAMESPACE))
 	if err != nil {
 		return nil, err
 	}
 	return pod.Annotations, nil
 }
 
-func runtimeConf(cniArgs *utils.CNIArgs, iface string, optionalArgs map[string]string) (*libcni.RuntimeConf, error) {
+func runtimeConf(cniArgs *utils.CNIArgs, pluginInfo *utils.PluginInfo) (*libcni.RuntimeConf, error) {
 	k8sArgs, err := loadArgs(cniArgs)
 	if err != nil {
 		return nil, err
@@ -813,13 +1209,33 @@ func runtimeConf(cniArgs *utils.CNIArgs, iface string, optionalArgs map[string]s
 		args = append(args, [2]string{"K8S_POD_INFRA_CONTAINER_ID", string(k8sArgs.K8S_POD_INFRA_CONTAINER_ID)})
 	}
 
-	args = append(args, setOptionalArgs(optionalArgs)...)
+	args = append(args, setOptionalArgs(pluginInfo.OptionalArgs)...)
 
 	return &libcni.RuntimeConf{
-		ContainerID: cniArgs.ContainerID,
-		NetNS:       cniArgs.Netns,
-		IfName:      iface,
-		Args:        args}, nil
+		ContainerID:    cniArgs.ContainerID,
+		NetNS:          cniArgs.Netns,
+		IfName:         pluginInfo.IfName,
+		Args:           args,
+		CapabilityArgs: supportedCapabilityArgs(pluginInfo.Config, pluginInfo.CapabilityArgs)}, nil
+}
+
+// supportedCapabilityArgs filters out the capability args which are not
+// declared as capability by any of the plugins in the configuration list
+func supportedCapabilityArgs(config *libcni.NetworkConfigList, capabilityArgs map[string]interface{}) map[string]interface{} {
+	supported := make(map[string]interface{})
+	if config == nil {
+		return supported
+	}
+	for capability, arg := range capabilityArgs {
+		for _, plugin := range config.Plugins {
+			if plugin.Network.Capabilities[capability] {
+				supported[capability] = arg
+				break
+			}
+		}
+	}
+
+	return supported
 }
 
 func setOptionalArgs(optionalParam map[string]string) [][2]string {
@@ -838,15 +1254,15 @@ func (gc *GenieController) handleNoCniCase(conf *utils.GenieConf) ([]*utils.Plug
 	var pluginInfoList []*utils.PluginInfo
 	var err error
 
-	//If no default plugin is mentioned, select first valid conf/conflist file in cni dir as kubelet does
+	//If no default plugin is mentioned, select the cluster network
 	if conf.DefaultPlugin == "" {
-		config, err := gc.getClusterNetwork(gc.Cfg.NetDir)
+		clusterNetwork, err := gc.getClusterNetwork(conf)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to get default plugin: %v", err)
 		}
 
-		fmt.Fprintf(os.Stderr, "CNI Genie no default plugin provided, selected plugin: %s\n", config.Plugins[0].Network.Type)
-		pluginInfoList = append(pluginInfoList, &utils.PluginInfo{PluginName: config.Plugins[0].Network.Type, Config: config, IfName: DefaultIfNamePrefix + "0"})
+		gc.logger().Infof("No default plugin provided, selected plugin: %s", clusterNetwork.PluginName)
+		pluginInfoList = append(pluginInfoList, clusterNetwork)
 	} else {
 		//Use default plugin specified
 		plugins := strings.Split(conf.DefaultPlugin, ",")
@@ -855,10 +1271,10 @@ func (gc *GenieController) handleNoCniCase(conf *utils.GenieConf) ([]*utils.Plug
 			return nil, err
 		}
 	}
-	return pluginInfoList, nil
+	return gc.appendDefaultNetworks(pluginInfoList, conf)
 }
 
-func mergeWithResult(src *current.Result, dst *current.Result) (*current.Result, error) {
+func mergeWithResult(src *types100.Result, dst *types100.Result) (*types100.Result, error) {
 	err := updateRoutes(src)
 	if err != nil {
 		return nil, fmt.Errorf("Routes update failed: %v", err)
@@ -879,7 +1295,7 @@ func mergeWithResult(src *current.Result, dst *current.Result) (*current.Result,
 	}
 	for _, ip := range src.IPs {
 		if ip.Interface != nil && *(ip.Interface) != -1 {
-			ip.Interface = current.Int(*(ip.Interface) + ifacesLength)
+			ip.Interface = types100.Int(*(ip.Interface) + ifacesLength)
 		}
 		dst.IPs = append(dst.IPs, ip)
 	}
@@ -887,16 +1303,7 @@ func mergeWithResult(src *current.Result, dst *current.Result) (*current.Result,
 		dst.Routes = append(dst.Routes, route)
 	}
 
-	for _, ns := range src.DNS.Nameservers {
-		dst.DNS.Nameservers = append(dst.DNS.Nameservers, ns)
-	}
-	for _, s := range src.DNS.Search {
-		dst.DNS.Search = append(dst.DNS.Search, s)
-	}
-	for _, opt := range src.DNS.Options {
-		dst.DNS.Options = append(dst.DNS.Options, opt)
-	}
-	// TODO: what about DNS.domain?
+	// DNS is merged separately as per the dns policy
 	return dst, nil
 }
 
@@ -904,7 +1311,7 @@ func mergeWithResult(src *current.Result, dst *current.Result) (*current.Result,
 // nil gw in route means default gw from result. When merging results from
 // many results default gw may be set from another CNI network. This may lead to
 // wrong routes.
-func updateRoutes(result *current.Result) error {
+func updateRoutes(result *types100.Result) error {
 	if len(result.Routes) == 0 {
 		return nil
 	}
@@ -931,7 +1338,7 @@ func updateRoutes(result *current.Result) error {
 // fixInterfaces fixes bad result returned by CNI plugin
 // some plugins(for example calico) return empty Interfaces list but
 // in IPConfig sets Interface index to 0. In such case it should be nil
-func fixInterfaces(result *current.Result) error {
+func fixInterfaces(result *types100.Result) error {
 	if len(result.Interfaces) == 0 {
 		for _, ip := range result.IPs {
 			ip.Interface = nil
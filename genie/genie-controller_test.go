@@ -1,17 +1,27 @@
 package genie
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/cni-genie/CNI-Genie/client"
+	netattachfake "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned/fake"
 	it "github.com/cni-genie/CNI-Genie/interfaces"
 	"github.com/cni-genie/CNI-Genie/networkcrd"
 	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
+	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"io/ioutil"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/fake"
+	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -30,17 +40,30 @@ var defaultGenieConf *utils.GenieConf = &utils.GenieConf{
 }
 
 func newController(plugins []string, obj ...runtime.Object) *GenieController {
+	config := it.NewFakeConfig()
 	gc := &GenieController{
 		Invoke: &it.FakeInvoke{},
+		Routes: &it.FakeRoutes{},
 		Cfg: &it.CNIConfig{
-			CNI:    &it.FakeCni{InstalledPlugins: plugins},
-			RW:     &it.FakeIo{Files: plugins},
+			CNI:    &it.FakeCni{InstalledPlugins: plugins, Config: config},
+			RW:     &it.FakeIo{Files: plugins, Config: config},
 			NetDir: DefaultNetDir,
 			BinDir: DefaultPluginDir,
 		},
 	}
 
-	gc.Kc = &client.KubeClient{Interface: fake.NewSimpleClientset(obj...)}
+	// The tracker of the fake clientset would guess the resource of net-attach-defs
+	// without hyphens, so they are created through the typed client instead
+	netClient := netattachfake.NewSimpleClientset()
+	var kubeObjs []runtime.Object
+	for _, o := range obj {
+		if nad, ok := o.(*networkcrd.NetworkAttachmentDefinition); ok {
+			netClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nad.Namespace).Create(nad)
+		} else {
+			kubeObjs = append(kubeObjs, o)
+		}
+	}
+	gc.Kc = &client.KubeClient{Interface: fake.NewSimpleClientset(kubeObjs...), NetClient: netClient}
 	it.SetIp(plugins)
 
 	return gc
@@ -121,3 +144,955 @@ func TestAddNetwork(t *testing.T) {
 		fmt.Println("result: ", res)
 	}
 }
+
+func TestAddOptionalNetwork(t *testing.T) {
+	tests := []struct {
+		cni             string
+		addErr          map[string]error
+		expectedErr     error
+		expectedDeleted []string
+	}{
+		{
+			cni:             "weave, macvlan?, bridge",
+			addErr:          map[string]error{"macvlan": errors.New("no free ip")},
+			expectedErr:     nil,
+			expectedDeleted: []string{"macvlan"},
+		},
+		{
+			cni:             "weave, macvlan, bridge",
+			addErr:          map[string]error{"macvlan": errors.New("no free ip")},
+			expectedErr:     errors.New("Error from cni: no free ip"),
+			expectedDeleted: []string{"macvlan", "weave"},
+		},
+		{
+			cni:             "macvlan?",
+			addErr:          map[string]error{"macvlan": errors.New("no free ip")},
+			expectedErr:     errors.New("CNI Genie failed to attach any of the requested networks"),
+			expectedDeleted: []string{"macvlan"},
+		},
+	}
+
+	for i := range tests {
+		gc := newController([]string{"weave", "macvlan", "bridge"})
+		invoke := &it.FakeInvoke{AddError: tests[i].addErr}
+		gc.Invoke = invoke
+		_ = gc.Cfg.LoadConfFiles()
+
+		pluginInfos, err := gc.getPluginInfo(strings.Split(tests[i].cni, ","))
+		if err != nil {
+			t.Fatalf("Error getting plugin info for %q: %v", tests[i].cni, err)
+		}
+
+		_, status, attached, err := gc.addNetwork(pluginInfos, getCniArgs("testpod", "default"), setNetAttachStatus)
+		if false == compareErrors(tests[i].expectedErr, err) {
+			t.Errorf("Expected error: %v; got error: %v", tests[i].expectedErr, err)
+		}
+		if fmt.Sprint(invoke.Deleted) != fmt.Sprint(tests[i].expectedDeleted) {
+			t.Errorf("Expected deleted networks: %v; got: %v", tests[i].expectedDeleted, invoke.Deleted)
+		}
+		if err != nil {
+			continue
+		}
+
+		if len(attached) != 2 {
+			t.Errorf("Expected 2 attached networks; got: %d", len(attached))
+		}
+		netStatus := *(status.(*[]networkcrd.NetworkStatus))
+		if len(netStatus) != 3 || netStatus[1].Error != "Error from cni: no free ip" || len(netStatus[1].IPs) != 0 {
+			t.Errorf("Expected failure of optional network in status; got: %+v", netStatus)
+		}
+	}
+}
+
+func TestAttachmentEvents(t *testing.T) {
+	gc := newController([]string{"weave", "macvlan", "bridge"})
+	gc.Invoke = &it.FakeInvoke{AddError: map[string]error{"macvlan": errors.New("no free ip")}}
+	_ = gc.Cfg.LoadConfFiles()
+
+	pluginInfos, err := gc.getPluginInfo([]string{"weave", "macvlan?", "bridge@net1"})
+	if err != nil {
+		t.Fatalf("Error getting plugin info: %v", err)
+	}
+	if _, _, _, err = gc.addNetwork(pluginInfos, getCniArgs("testpod", "default"), nil); err != nil {
+		t.Fatalf("Error adding network: %v", err)
+	}
+
+	events, err := gc.Kc.CoreV1().Events("default").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing events: %v", err)
+	}
+	expected := map[string]string{
+		EventNetworkAttached + ":weave":  "Attached network weave on interface eth0 with IPs 10.32.0.",
+		EventAttachFailed + ":macvlan":   "Failed to attach optional network macvlan on interface eth1, continuing without it: Error from cni: no free ip",
+		EventNetworkAttached + ":bridge": "Attached network bridge on interface net1 with IPs 10.10.0.",
+	}
+	if len(events.Items) != len(expected) {
+		t.Fatalf("Expected %d events; got: %+v", len(expected), events.Items)
+	}
+	for _, event := range events.Items {
+		if event.InvolvedObject.Name != "testpod" {
+			t.Errorf("Expected event on testpod; got: %+v", event.InvolvedObject)
+		}
+		found := false
+		for key, message := range expected {
+			if strings.HasPrefix(key, event.Reason+":") && strings.HasPrefix(event.Message, message) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Unexpected event %s: %s", event.Reason, event.Message)
+		}
+	}
+}
+
+func TestAttachmentEventsRollback(t *testing.T) {
+	gc := newController([]string{"weave", "macvlan", "bridge"})
+	gc.Invoke = &it.FakeInvoke{AddError: map[string]error{"macvlan": errors.New("no free ip")}}
+	_ = gc.Cfg.LoadConfFiles()
+
+	pluginInfos, err := gc.getPluginInfo([]string{"weave", "macvlan", "bridge@net1"})
+	if err != nil {
+		t.Fatalf("Error getting plugin info: %v", err)
+	}
+	if _, _, _, err = gc.addNetwork(pluginInfos, getCniArgs("testpod", "default"), nil); err == nil {
+		t.Fatalf("Expected error adding network with a mandatory network failing")
+	}
+
+	// The attachment of weave is rolled back, so it is not recorded
+	events, err := gc.Kc.CoreV1().Events("default").List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Error listing events: %v", err)
+	}
+	if len(events.Items) != 1 || events.Items[0].Reason != EventAttachFailed || !strings.HasPrefix(events.Items[0].Message, "Failed to attach network macvlan on interface eth1") {
+		t.Errorf("Expected only the failure of macvlan to be recorded; got: %+v", events.Items)
+	}
+}
+
+func TestNetAttachStatusDefault(t *testing.T) {
+	tests := []struct {
+		cni          string
+		defaultRoute string
+		addErr       map[string]error
+		// expected name of the default network in status
+		expectedDefault string
+	}{
+		{
+			cni:             "weave, macvlan, bridge",
+			expectedDefault: "weave",
+		},
+		{
+			cni:             "macvlan?, weave, bridge",
+			addErr:          map[string]error{"macvlan": errors.New("no free ip")},
+			expectedDefault: "weave",
+		},
+		{
+			cni:             "weave, macvlan, bridge",
+			defaultRoute:    "bridge",
+			expectedDefault: "bridge",
+		},
+	}
+
+	for i := range tests {
+		gc := newController([]string{"weave", "macvlan", "bridge"})
+		gc.Invoke = &it.FakeInvoke{AddError: tests[i].addErr}
+		_ = gc.Cfg.LoadConfFiles()
+
+		pluginInfos, err := gc.getPluginInfo(strings.Split(tests[i].cni, ","))
+		if err != nil {
+			t.Fatalf("Test %d: error getting plugin info for %q: %v", i, tests[i].cni, err)
+		}
+		for _, pluginInfo := range pluginInfos {
+			pluginInfo.DefaultRoute = pluginInfo.PluginName == tests[i].defaultRoute
+		}
+
+		_, status, _, err := gc.addNetwork(pluginInfos, getCniArgs("testpod", "default"), setNetAttachStatus)
+		if err != nil {
+			t.Fatalf("Test %d: error adding network: %v", i, err)
+		}
+		var defaults []string
+		for _, netStatus := range *(status.(*[]networkcrd.NetworkStatus)) {
+			if netStatus.Default {
+				defaults = append(defaults, netStatus.Name)
+			}
+		}
+		if len(defaults) != 1 || defaults[0] != tests[i].expectedDefault {
+			t.Errorf("Test %d: expected %s as the default network; got: %v", i, tests[i].expectedDefault, defaults)
+		}
+	}
+}
+
+func TestRuntimeConfCapabilityArgs(t *testing.T) {
+	gc := newController(nil)
+	portmapList, err := gc.Cfg.ParseCNIConfFromBytes([]byte(`{"cniVersion": "0.4.0", "name": "net1", "plugins": [
+		{"type": "bridge", "capabilities": {"ips": true, "mac": false}},
+		{"type": "portmap", "capabilities": {"portMappings": true}}]}`))
+	if err != nil {
+		t.Fatalf("Error parsing configuration: %v", err)
+	}
+
+	networks, err := networkcrd.GetNetworkInfo(`[{"name": "net1", "ips": ["10.1.1.5"], "mac": "c2:11:22:33:44:55",
+		"portMappings": [{"hostPort": 8080, "containerPort": 80, "protocol": "tcp"}], "deviceID": "0000:03:00.1"}]`, "default")
+	if err != nil {
+		t.Fatalf("Error parsing network selection: %v", err)
+	}
+
+	pluginInfo := &utils.PluginInfo{
+		PluginName:     "net1",
+		IfName:         "net1",
+		Config:         portmapList,
+		CapabilityArgs: getCapabilityArgs(&networks[0]),
+	}
+	rtConf, err := runtimeConf(getCniArgs("testpod", "default"), pluginInfo)
+	if err != nil {
+		t.Fatalf("Error generating runtime conf: %v", err)
+	}
+
+	if len(rtConf.CapabilityArgs) != 2 {
+		t.Errorf("Expected only ips and portMappings in capability args; got: %v", rtConf.CapabilityArgs)
+	}
+	if ips, ok := rtConf.CapabilityArgs[utils.CapabilityIPs].([]string); !ok || ips[0] != "10.1.1.5" {
+		t.Errorf("Expected requested ips in capability args; got: %v", rtConf.CapabilityArgs)
+	}
+	if portMaps, ok := rtConf.CapabilityArgs[utils.CapabilityPortMappings].([]*networkcrd.PortMapEntry); !ok || portMaps[0].HostPort != 8080 {
+		t.Errorf("Expected requested port mappings in capability args; got: %v", rtConf.CapabilityArgs)
+	}
+}
+
+func TestFixDefaultRoute(t *testing.T) {
+	newResult := func() *types100.Result {
+		dst, _ := types.ParseCIDR("0.0.0.0/0")
+		subnet, _ := types.ParseCIDR("10.96.0.0/12")
+		return &types100.Result{
+			CNIVersion: "1.0.0",
+			Routes:     []*types100.Route{{Dst: *dst, GW: net.ParseIP("10.10.0.1")}, {Dst: *subnet}},
+		}
+	}
+
+	tests := []struct {
+		pluginInfo      *utils.PluginInfo
+		expectedRoutes  []string
+		expectedDeleted []string
+		expectedAdded   []string
+	}{
+		{
+			pluginInfo:      &utils.PluginInfo{PluginName: "weave", IfName: "eth0"},
+			expectedRoutes:  []string{"10.96.0.0/12"},
+			expectedDeleted: []string{"eth0"},
+		},
+		{
+			pluginInfo:     &utils.PluginInfo{PluginName: "bridge", IfName: "eth1", DefaultRoute: true},
+			expectedRoutes: []string{"0.0.0.0/0 via 10.10.0.1", "10.96.0.0/12"},
+		},
+		{
+			pluginInfo:     &utils.PluginInfo{PluginName: "bridge", IfName: "eth1", DefaultRoute: true, GatewayRequest: []net.IP{net.ParseIP("10.10.0.254")}},
+			expectedRoutes: []string{"0.0.0.0/0 via 10.10.0.254", "10.96.0.0/12"},
+			expectedAdded:  []string{"10.10.0.254"},
+		},
+	}
+
+	for i := range tests {
+		gc := newController(nil)
+		routes := &it.FakeRoutes{}
+		gc.Routes = routes
+		cniArgs := getCniArgs("testpod", "default")
+		cniArgs.Netns = "/var/run/netns/testpod"
+
+		res, err := gc.fixDefaultRoute(tests[i].pluginInfo, newResult(), cniArgs)
+		if err != nil {
+			t.Errorf("Unexpected error: %v", err)
+			continue
+		}
+
+		var gotRoutes []string
+		for _, route := range res.(*types100.Result).Routes {
+			if route.GW != nil {
+				gotRoutes = append(gotRoutes, fmt.Sprintf("%s via %s", route.Dst.String(), route.GW))
+			} else {
+				gotRoutes = append(gotRoutes, route.Dst.String())
+			}
+		}
+		if fmt.Sprint(gotRoutes) != fmt.Sprint(tests[i].expectedRoutes) {
+			t.Errorf("Expected routes: %v; got: %v", tests[i].expectedRoutes, gotRoutes)
+		}
+		if fmt.Sprint(routes.Deleted) != fmt.Sprint(tests[i].expectedDeleted) {
+			t.Errorf("Expected default routes deleted through: %v; got: %v", tests[i].expectedDeleted, routes.Deleted)
+		}
+		if fmt.Sprint(routes.Added[tests[i].pluginInfo.IfName]) != fmt.Sprint(tests[i].expectedAdded) {
+			t.Errorf("Expected default routes added via: %v; got: %v", tests[i].expectedAdded, routes.Added)
+		}
+		if cached := gc.Invoke.(*it.FakeInvoke).Cached[tests[i].pluginInfo.IfName]; cached != res {
+			t.Errorf("Expected the fixed result to be cached; got: %v", cached)
+		}
+	}
+}
+
+func TestDefaultRouteOwner(t *testing.T) {
+	tests := []struct {
+		annot         string
+		preset        int
+		expectedOwner int
+		expectedErr   error
+	}{
+		{annot: "bridge", preset: -1, expectedOwner: 1},
+		{annot: "weave@net2", preset: -1, expectedOwner: 2},
+		{annot: "macvlan", preset: -1, expectedOwner: -1, expectedErr: errors.New("Plugin macvlan requested for default route is not present in cni annotation")},
+		{annot: "bridge", preset: 0, expectedOwner: -1, expectedErr: errors.New("Default route requested for multiple attachments: weave and bridge")},
+	}
+
+	for i := range tests {
+		pluginInfos := []*utils.PluginInfo{{PluginName: "weave"}, {PluginName: "bridge"}, {PluginName: "weave", IfName: "net2"}}
+		if tests[i].preset >= 0 {
+			pluginInfos[tests[i].preset].DefaultRoute = true
+		}
+
+		err := setDefaultRouteFromAnnot(pluginInfos, tests[i].annot)
+		owner := -1
+		if err == nil {
+			owner, err = getDefaultRouteOwner(pluginInfos)
+		}
+		if false == compareErrors(tests[i].expectedErr, err) {
+			t.Errorf("Expected error: %v; got error: %v", tests[i].expectedErr, err)
+		}
+		if owner != tests[i].expectedOwner {
+			t.Errorf("Expected default route owner: %d; got: %d", tests[i].expectedOwner, owner)
+		}
+	}
+}
+
+func newNetAttachDef(name, namespace, config string) *networkcrd.NetworkAttachmentDefinition {
+	return &networkcrd.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       networkcrd.NetworkAttachmentDefinitionSpec{Config: config},
+	}
+}
+
+func TestExplainPod(t *testing.T) {
+	tests := []struct {
+		annot         map[string]string
+		netAttachDefs []runtime.Object
+		expectedLines []string
+		expectedErr   error
+	}{
+		{
+			annot: map[string]string{CniAnnotation: "weave, bridge@eth0?", DefaultRouteAnnot: "bridge"},
+			expectedLines: []string{
+				"Selection:  cni annotation",
+				"eth1    weave    weave    /etc/cni/net.d/10-weave.conf",
+				"eth0    bridge   bridge   /etc/cni/net.d/10-bridge.conf",
+				"optional,default-route",
+			},
+		},
+		{
+			annot:       map[string]string{CniAnnotation: "weave@net1, bridge@net1"},
+			expectedErr: errors.New("Error resolving networks of pod default/testpod: Repeated request for same interface name: net1"),
+		},
+		{
+			annot: map[string]string{NetAttachDefAnnotation: "bridge-net, other/weave-net"},
+			netAttachDefs: []runtime.Object{
+				newNetAttachDef("bridge-net", "default", `{"cniVersion": "0.3.1", "name": "bridge-net", "type": "bridge"}`),
+				newNetAttachDef("weave-net", "other", `{"cniVersion": "0.3.1", "name": "weave-net", "type": "weave-net"}`),
+				newNetAttachDef("weave-net", "default", `{"cniVersion": "0.3.1", "name": "weave-net", "type": "macvlan"}`),
+			},
+			expectedLines: []string{
+				"spec of net-attach-def default/bridge-net",
+				"spec of net-attach-def other/weave-net",
+			},
+		},
+		{
+			annot:       map[string]string{NetAttachDefAnnotation: "absent-net"},
+			expectedErr: errors.New("Error getting network attachment definition: Error getting network attachment definition default/absent-net (NotFound)"),
+		},
+	}
+
+	for i := range tests {
+		gc := newController([]string{"weave", "bridge"}, tests[i].netAttachDefs...)
+		gc.DryRun = true
+		invoke := &it.FakeInvoke{}
+		gc.Invoke = invoke
+
+		out := &bytes.Buffer{}
+		err := gc.explainPod(newPod("testpod", "default", tests[i].annot), defaultGenieConf, out)
+		if false == compareErrors(tests[i].expectedErr, err) {
+			t.Errorf("Expected error: %v; got error: %v", tests[i].expectedErr, err)
+		}
+		for _, line := range tests[i].expectedLines {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("Expected %q in explanation:\n%s", line, out.String())
+			}
+		}
+		if len(invoke.Deleted) != 0 {
+			t.Errorf("Expected no delegate to be invoked; got: %v", invoke.Deleted)
+		}
+	}
+}
+
+func TestExplainManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-explain")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "10-weave.conf"), []byte(`{"cniVersion": "0.3.1", "name": "weave", "type": "weave-net"}`), 0600); err != nil {
+		t.Fatalf("Error writing configuration file: %v", err)
+	}
+
+	// The kubeconfig does not exist, the api server is not reachable
+	tests := []struct {
+		annot           string
+		defaultNetworks string
+		expectedLines   []string
+		expectedErr     error
+	}{
+		{
+			annot:         `{"cni": "weave"}`,
+			expectedLines: []string{"eth0    weave    weave-net"},
+		},
+		{
+			annot:         `{}`,
+			expectedLines: []string{"eth0    weave-net"},
+		},
+		{
+			annot:       `{"k8s.v1.cni.cncf.io/networks": "macvlan-net"}`,
+			expectedErr: errors.New("Error building kubernetes client"),
+		},
+		{
+			annot:       `{"cni": "", "networks": "frontend"}`,
+			expectedErr: errors.New("Error building kubernetes client"),
+		},
+		{
+			annot:           `{"cni": "weave"}`,
+			defaultNetworks: `"default/sriov-net"`,
+			expectedErr:     errors.New("Error building kubernetes client"),
+		},
+	}
+
+	for i := range tests {
+		genieConf := filepath.Join(dir, "genie.conf")
+		conf := fmt.Sprintf(`{"name": "k8s-pod-network", "type": "genie", "state_dir": %q, "kubernetes": {"kubeconfig": %q}, "default_networks": [%s]}`,
+			filepath.Join(dir, "state"), filepath.Join(dir, "absent"), tests[i].defaultNetworks)
+		if err = ioutil.WriteFile(genieConf, []byte(conf), 0600); err != nil {
+			t.Fatalf("Error writing genie configuration: %v", err)
+		}
+		manifest := filepath.Join(dir, "pod.json")
+		pod := fmt.Sprintf(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "testpod", "annotations": %s}}`, tests[i].annot)
+		if err = ioutil.WriteFile(manifest, []byte(pod), 0600); err != nil {
+			t.Fatalf("Error writing pod manifest: %v", err)
+		}
+
+		out := &bytes.Buffer{}
+		err = Explain([]string{"--conf-dir", dir, "--genie-conf", genieConf, "--manifest", manifest}, out)
+		if tests[i].expectedErr == nil && err != nil || tests[i].expectedErr != nil && (err == nil || !strings.Contains(err.Error(), tests[i].expectedErr.Error())) {
+			t.Errorf("Test %d: expected error: %v; got error: %v", i, tests[i].expectedErr, err)
+		}
+		for _, line := range tests[i].expectedLines {
+			if !strings.Contains(out.String(), line) {
+				t.Errorf("Test %d: expected %q in explanation:\n%s", i, line, out.String())
+			}
+		}
+	}
+}
+
+func TestCheckNetwork(t *testing.T) {
+	newPluginInfo := func(name, ifName, cniVersion string) *utils.PluginInfo {
+		return &utils.PluginInfo{
+			PluginName: name,
+			IfName:     ifName,
+			Config:     &libcni.NetworkConfigList{Name: name, CNIVersion: cniVersion},
+		}
+	}
+
+	tests := []struct {
+		pluginInfos []*utils.PluginInfo
+		invokeErr   error
+		expectedErr error
+	}{
+		{
+			pluginInfos: []*utils.PluginInfo{newPluginInfo("weave", "eth0", "0.4.0"), newPluginInfo("macvlan", "net1", "0.4.0")},
+			invokeErr:   nil,
+			expectedErr: nil,
+		},
+		{
+			pluginInfos: []*utils.PluginInfo{newPluginInfo("weave", "eth0", "0.4.0"), newPluginInfo("macvlan", "net1", "0.4.0")},
+			invokeErr:   errors.New("interface not found"),
+			expectedErr: errors.New("weave@eth0: Error from cni: interface not found; macvlan@net1: Error from cni: interface not found"),
+		},
+		{
+			pluginInfos: []*utils.PluginInfo{newPluginInfo("flannel", "", "0.3.0")},
+			invokeErr:   errors.New("interface not found"),
+			expectedErr: nil,
+		},
+	}
+
+	for i := range tests {
+		gc := newController(nil)
+		gc.Invoke = &it.FakeInvoke{Error: tests[i].invokeErr}
+
+		err := gc.checkNetwork(tests[i].pluginInfos, getCniArgs("testpod", "default"))
+		if false == compareErrors(tests[i].expectedErr, err) {
+			t.Errorf("Expected error: %v; got error: %v", tests[i].expectedErr, err)
+		}
+	}
+}
+
+func TestDeleteRecordedNetwork(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "genie-state")
+	if err != nil {
+		t.Fatalf("Error creating state directory: %v", err)
+	}
+	defer os.RemoveAll(stateDir)
+
+	tests := []struct {
+		containerID string
+		invokeErr   error
+		expectedErr error
+		recordLeft  bool
+	}{
+		{
+			containerID: "container1",
+			invokeErr:   nil,
+			expectedErr: nil,
+			recordLeft:  false,
+		},
+		{
+			containerID: "container2",
+			invokeErr:   errors.New("failed to release ip"),
+			expectedErr: errors.New("failed to release ip"),
+			recordLeft:  true,
+		},
+	}
+
+	for i := range tests {
+		// The pod object is not present, so only the record can be used for DEL
+		gc := newController([]string{"weave", "bridge"})
+		gc.StateDir = stateDir
+		gc.Invoke = &it.FakeInvoke{Error: tests[i].invokeErr}
+
+		weave, err := gc.Cfg.ParseCNIConfFromBytes([]byte(`{"cniVersion": "0.3.0", "name": "weave", "plugins": [{"type": "weave"}]}`))
+		if err != nil {
+			t.Fatalf("Error parsing weave config: %v", err)
+		}
+		bridge, err := gc.Cfg.ParseCNIConfFromBytes([]byte(`{"name": "mybridgenet", "type": "bridge", "ipam": {"type": "host-local"}}`))
+		if err != nil {
+			t.Fatalf("Error parsing bridge config: %v", err)
+		}
+		pluginInfos := []*utils.PluginInfo{
+			{PluginName: "weave", IfName: "eth0", Config: weave},
+			{PluginName: "bridge", IfName: "eth1", Config: bridge, OptionalArgs: map[string]string{"mac": "c2:11:22:33:44:55"}},
+		}
+		if err = gc.saveAttachments(tests[i].containerID, pluginInfos); err != nil {
+			t.Fatalf("Error saving attachments: %v", err)
+		}
+
+		recorded, err := gc.loadAttachments(tests[i].containerID)
+		if err != nil || len(recorded) != len(pluginInfos) {
+			t.Fatalf("Error loading attachments: %v; loaded: %v", err, recorded)
+		}
+		if recorded[1].IfName != "eth1" || recorded[1].Config.Plugins[0].Network.Type != "bridge" || recorded[1].OptionalArgs["mac"] == "" {
+			t.Errorf("Recorded attachment mismatch: %+v", *recorded[1])
+		}
+
+		cniArgs := getCniArgs("testpod", "default")
+		cniArgs.ContainerID = tests[i].containerID
+		err = gc.DeletePodNetwork(cniArgs, defaultGenieConf)
+		if false == compareErrors(tests[i].expectedErr, err) {
+			t.Errorf("Expected error: %v; got error: %v", tests[i].expectedErr, err)
+		}
+
+		_, err = os.Stat(filepath.Join(stateDir, tests[i].containerID))
+		if tests[i].recordLeft != (err == nil) {
+			t.Errorf("Expected record to be left: %v; stat error: %v", tests[i].recordLeft, err)
+		}
+	}
+}
+
+func TestCheckRecordedNetwork(t *testing.T) {
+	stateDir, err := ioutil.TempDir("", "genie-state")
+	if err != nil {
+		t.Fatalf("Error creating state directory: %v", err)
+	}
+	defer os.RemoveAll(stateDir)
+
+	tests := []struct {
+		containerID     string
+		record          []string
+		expectedChecked []string
+	}{
+		{
+			// The recorded attachments are checked, not the ones the pod selects now
+			containerID:     "container1",
+			record:          []string{"weave", "bridge"},
+			expectedChecked: []string{"weave", "bridge"},
+		},
+		{
+			// Without a record, the networks are resolved from the pod
+			containerID:     "container2",
+			expectedChecked: []string{"macvlan"},
+		},
+	}
+
+	pod := newPod("testpod", "default", map[string]string{NetworkAttachmentDefinitionAnnot: "macvlan-net"})
+	nad := newNetAttachDef("macvlan-net", "default", `{"cniVersion": "0.4.0", "name": "macvlan-net", "type": "macvlan"}`)
+	for i := range tests {
+		gc := newController([]string{"weave"}, pod, nad)
+		gc.StateDir = stateDir
+		invoke := &it.FakeInvoke{}
+		gc.Invoke = invoke
+
+		var pluginInfos []*utils.PluginInfo
+		for j, plugin := range tests[i].record {
+			config, err := gc.Cfg.ParseCNIConfFromBytes([]byte(fmt.Sprintf(`{"cniVersion": "0.4.0", "name": "%s", "type": "%s"}`, plugin, plugin)))
+			if err != nil {
+				t.Fatalf("Test %d: error parsing %s config: %v", i, plugin, err)
+			}
+			pluginInfos = append(pluginInfos, &utils.PluginInfo{PluginName: plugin, IfName: fmt.Sprintf("eth%d", j), Config: config})
+		}
+		if pluginInfos != nil {
+			if err = gc.saveAttachments(tests[i].containerID, pluginInfos); err != nil {
+				t.Fatalf("Test %d: error saving attachments: %v", i, err)
+			}
+		}
+
+		cniArgs := getCniArgs("testpod", "default")
+		cniArgs.ContainerID = tests[i].containerID
+		if err = gc.CheckPodNetwork(cniArgs, defaultGenieConf); err != nil {
+			t.Errorf("Test %d: error checking network: %v", i, err)
+		}
+		if fmt.Sprint(invoke.Checked) != fmt.Sprint(tests[i].expectedChecked) {
+			t.Errorf("Test %d: expected CHECK of %v; got: %v", i, tests[i].expectedChecked, invoke.Checked)
+		}
+	}
+}
+
+func TestParseResultVersions(t *testing.T) {
+	ipnet1, _ := types.ParseCIDR("10.244.0.5/24")
+	ipnet2, _ := types.ParseCIDR("10.10.0.5/16")
+	_, dst, _ := net.ParseCIDR("0.0.0.0/0")
+
+	ch := make(chan sendCh, 2)
+	ch <- sendCh{name: "flannel", ifName: "eth0", isDefault: true, res: &current.Result{
+		CNIVersion: "0.3.1",
+		IPs:        []*current.IPConfig{{Version: "4", Address: *ipnet1, Gateway: net.ParseIP("10.244.0.1")}},
+		Routes:     []*types.Route{{Dst: *dst}},
+	}}
+	ch <- sendCh{name: "macvlan", ifName: "eth1", res: &types100.Result{
+		CNIVersion: "1.1.0",
+		Interfaces: []*types100.Interface{{Name: "eth1", Sandbox: "/var/run/netns/test"}},
+		IPs:        []*types100.IPConfig{{Interface: types100.Int(0), Address: *ipnet2}},
+	}}
+	close(ch)
+
+	res, status := newController(nil).parseResult(setNetAttachStatus, ch)
+	if res == nil || status == nil {
+		t.Fatalf("Expected merged result and status; got result: %v, status: %v", res, status)
+	}
+
+	for _, ver := range []string{"0.3.0", "0.4.0", "1.0.0", "1.1.0"} {
+		converted, err := res.GetAsVersion(ver)
+		if err != nil {
+			t.Errorf("Error converting merged result to version %s: %v", ver, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err = converted.PrintTo(&buf); err != nil {
+			t.Errorf("Error printing result of version %s: %v", ver, err)
+			continue
+		}
+		if !strings.Contains(buf.String(), `"cniVersion": "`+ver+`"`) {
+			t.Errorf("Expected result of version %s; got: %s", ver, buf.String())
+		}
+		if !strings.Contains(buf.String(), "10.244.0.1") || !strings.Contains(buf.String(), "10.10.0.5/16") {
+			t.Errorf("Merged result of version %s is missing entries: %s", ver, buf.String())
+		}
+	}
+
+	netStatus := *(status.(*[]networkcrd.NetworkStatus))
+	if len(netStatus) != 2 || netStatus[1].IPs[0] != "10.10.0.5" {
+		t.Errorf("Unexpected network status: %+v", netStatus)
+	}
+}
+
+func TestMergeDNS(t *testing.T) {
+	entries := []dnsEntry{
+		{name: "weave", ifName: "eth0", dns: types.DNS{Nameservers: []string{"10.96.0.10"}, Domain: "cluster.local", Search: []string{"default.svc.cluster.local", "svc.cluster.local"}, Options: []string{"ndots:5"}}},
+		{name: "macvlan", ifName: "net1", dns: types.DNS{Nameservers: []string{"192.168.1.1", "10.96.0.10"}, Domain: "corp.local", Search: []string{"corp.local", "svc.cluster.local"}, Options: []string{"ndots:5", "timeout:2"}}},
+	}
+
+	tests := []struct {
+		policy       string
+		attachment   string
+		defaultRoute string
+		expected     types.DNS
+	}{
+		{
+			policy: DNSPolicyUnion,
+			expected: types.DNS{Nameservers: []string{"10.96.0.10", "192.168.1.1"}, Domain: "cluster.local",
+				Search: []string{"default.svc.cluster.local", "svc.cluster.local", "corp.local"}, Options: []string{"ndots:5", "timeout:2"}},
+		},
+		{
+			policy:   DNSPolicyPrimary,
+			expected: entries[0].dns,
+		},
+		{
+			// The primary attachment is the one owning the default route
+			policy:       DNSPolicyPrimary,
+			defaultRoute: "macvlan",
+			expected:     entries[1].dns,
+		},
+		{
+			policy:     DNSPolicyAttachment,
+			attachment: "macvlan@net1",
+			expected:   entries[1].dns,
+		},
+		{
+			policy:     DNSPolicyAttachment,
+			attachment: "sriov",
+			expected:   entries[0].dns,
+		},
+		{
+			policy:       DNSPolicyAttachment,
+			attachment:   "sriov",
+			defaultRoute: "macvlan",
+			expected:     entries[1].dns,
+		},
+	}
+
+	for i := range tests {
+		gc := newController(nil)
+		gc.DNSPolicy = tests[i].policy
+		gc.DNSAttachment = tests[i].attachment
+		for n := range entries {
+			entries[n].isDefault = entries[n].name == tests[i].defaultRoute
+		}
+
+		dns := gc.mergeDNS(entries)
+		if fmt.Sprintf("%+v", dns) != fmt.Sprintf("%+v", tests[i].expected) {
+			t.Errorf("Expected dns for policy %s: %+v; got: %+v", tests[i].policy, tests[i].expected, dns)
+		}
+	}
+
+	conf, err := ParseCNIConf([]byte(`{"name": "k8s-pod-network", "type": "genie", "dns_policy": "attachment"}`))
+	if false == compareErrors(errors.New("dns_attachment must be specified"), err) {
+		t.Errorf("Expected error for dns policy without attachment; got conf: %+v, error: %v", conf, err)
+	}
+}
+
+func TestStandaloneAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-selection")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	selectionFile := filepath.Join(dir, "selections.json")
+	selections := `{
+		"default": {"cni": "weave"},
+		"pods": {"default/web": {"cni": "weave, bridge", "cni-default-route": "weave"}},
+		"containers": {"ctr2": {"cni": "bridge"}}
+	}`
+	if err = ioutil.WriteFile(selectionFile, []byte(selections), 0600); err != nil {
+		t.Fatalf("Error writing selection file: %v", err)
+	}
+
+	tests := []struct {
+		containerID   string
+		args          string
+		runtimeConfig map[string]interface{}
+		expected      map[string]string
+		expectedErr   error
+	}{
+		{
+			containerID: "ctr1",
+			args:        "K8S_POD_NAMESPACE=default;K8S_POD_NAME=db",
+			expected:    map[string]string{CniAnnotation: "weave"},
+		},
+		{
+			containerID: "ctr1",
+			args:        "K8S_POD_NAMESPACE=default;K8S_POD_NAME=web",
+			expected:    map[string]string{CniAnnotation: "weave, bridge", DefaultRouteAnnot: "weave"},
+		},
+		{
+			containerID: "ctr2",
+			args:        "IgnoreUnknown=1;K8S_POD_NAMESPACE=default;K8S_POD_NAME=web;cni-default-route=bridge;app=web",
+			expected:    map[string]string{CniAnnotation: "bridge", DefaultRouteAnnot: "bridge"},
+		},
+		{
+			containerID:   "ctr2",
+			args:          "cni=macvlan",
+			runtimeConfig: map[string]interface{}{PodAnnotationsCapability: map[string]interface{}{NetAttachDefAnnotation: "net1", "team": "a"}},
+			expected:      map[string]string{CniAnnotation: "macvlan", NetAttachDefAnnotation: "net1", "team": "a"},
+		},
+		{
+			containerID:   "ctr1",
+			runtimeConfig: map[string]interface{}{PodAnnotationsCapability: "cni=weave"},
+			expectedErr:   errors.New("Invalid runtime config io.kubernetes.cri.pod-annotations: cni=weave"),
+		},
+	}
+
+	for i := range tests {
+		gc := newController(nil)
+		gc.Kc = nil
+		gc.Standalone = true
+		gc.SelectionFile = selectionFile
+		conf := &utils.GenieConf{RuntimeConfig: tests[i].runtimeConfig}
+
+		cniArgs := &utils.CNIArgs{ContainerID: tests[i].containerID, Args: tests[i].args}
+		k8sArgs, err := loadArgs(cniArgs)
+		if err != nil {
+			t.Fatalf("Error loading args %q: %v", tests[i].args, err)
+		}
+		annot, err := gc.getPodAnnotations(cniArgs, k8sArgs, conf)
+		if false == compareErrors(tests[i].expectedErr, err) {
+			t.Errorf("Expected error: %v; got error: %v", tests[i].expectedErr, err)
+		}
+		if err == nil && fmt.Sprint(annot) != fmt.Sprint(tests[i].expected) {
+			t.Errorf("Expected annotations: %v; got: %v", tests[i].expected, annot)
+		}
+	}
+}
+
+func TestAddNetworkStandalone(t *testing.T) {
+	gc := newController([]string{"weave", "bridge"})
+	gc.Kc = nil
+	gc.Standalone = true
+
+	cniArgs := getCniArgs("testpod", "default")
+	cniArgs.Args += ";cni=weave,bridge"
+	res, err := gc.AddPodNetwork(cniArgs, defaultGenieConf)
+	if err != nil {
+		t.Fatalf("Error adding network in standalone mode: %v", err)
+	}
+	if res == nil {
+		t.Errorf("Expected result of adding network in standalone mode")
+	}
+}
+
+func TestMesosNetworkInfo(t *testing.T) {
+	tests := []struct {
+		netConf         string
+		ifName          string
+		expectedIfNames []string
+		expectedErr     error
+	}{
+		{
+			netConf: `{"name": "genie", "type": "genie", "args": {"org.apache.mesos": {"network_info": {"name": "genie",
+				"labels": {"labels": [{"key": "cni", "value": "weave, bridge"}, {"key": "rack", "value": "r1"}]}}}}}`,
+			ifName:          "net1",
+			expectedIfNames: []string{"net1", "eth0"},
+		},
+		{
+			netConf: `{"name": "genie", "type": "genie",
+				"args": {"cni": {"labels": [{"key": "cni", "value": "bridge"}]},
+				"org.apache.mesos": {"network_info": {"labels": {"labels": [{"key": "cni", "value": "weave"}]}}}}}`,
+			ifName:          "eth0",
+			expectedIfNames: []string{"eth0"},
+		},
+		{
+			netConf: `{"name": "genie", "type": "genie", "args": {"org.apache.mesos": {"network_info": {"name": "genie",
+				"labels": {"labels": [{"key": "cni", "value": "weave, bridge"}]}}}}}`,
+			ifName:          "eth1",
+			expectedIfNames: []string{"eth1", "eth0"},
+		},
+	}
+
+	for i := range tests {
+		conf, err := ParseCNIConf([]byte(tests[i].netConf))
+		if err != nil {
+			t.Fatalf("Error parsing netconf: %v", err)
+		}
+		if !isMesos(conf) || ResultVersion(conf) != MesosResultVersion {
+			t.Errorf("Expected netconf to be recognized as a Mesos invocation: %s", tests[i].netConf)
+		}
+
+		gc := newController([]string{"weave", "bridge"})
+		gc.Kc = nil
+		gc.Standalone = true
+		cniArgs := &utils.CNIArgs{ContainerID: "mesos-ctr", IfName: tests[i].ifName}
+		k8sArgs, err := loadArgs(cniArgs)
+		if err != nil {
+			t.Fatalf("Error loading args: %v", err)
+		}
+		if id, orchestrator, _ := getIdentifiers(cniArgs, k8sArgs, conf); id != "mesos-ctr" || orchestrator != "mesos" {
+			t.Errorf("Expected mesos workload mesos-ctr; got %s workload %s", orchestrator, id)
+		}
+
+		annot, err := gc.getPodAnnotations(cniArgs, k8sArgs, conf)
+		if err != nil {
+			t.Fatalf("Error getting network selection: %v", err)
+		}
+		pluginInfoList, err := gc.resolvePluginInfoList(annot, k8sArgs, conf)
+		if false == compareErrors(tests[i].expectedErr, err) {
+			t.Errorf("Expected error: %v; got error: %v", tests[i].expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		setRuntimeIfName(pluginInfoList, cniArgs, conf)
+		if err = assignIfNames(pluginInfoList); err != nil {
+			t.Fatalf("Error assigning interface names: %v", err)
+		}
+		ifNames := make([]string, 0, len(pluginInfoList))
+		for _, pluginInfo := range pluginInfoList {
+			ifNames = append(ifNames, pluginInfo.IfName)
+		}
+		if fmt.Sprint(ifNames) != fmt.Sprint(tests[i].expectedIfNames) {
+			t.Errorf("Expected interfaces: %v; got: %v", tests[i].expectedIfNames, ifNames)
+		}
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	tests := []struct {
+		valid []string
+		// expected valid attachments passed to the delegates, keyed by network
+		expectedCollected map[string][]utils.GCAttachment
+	}{
+		{
+			valid:             []string{"valid"},
+			expectedCollected: map[string][]utils.GCAttachment{"weave": {{ContainerID: "valid", IfName: "eth0"}}},
+		},
+		{
+			// The attachments of the container without a record are not known
+			valid:             []string{"valid", "unrecorded"},
+			expectedCollected: nil,
+		},
+	}
+
+	for i := range tests {
+		stateDir, err := ioutil.TempDir("", "genie-state")
+		if err != nil {
+			t.Fatalf("Error creating state directory: %v", err)
+		}
+		defer os.RemoveAll(stateDir)
+
+		gc := newController([]string{"weave", "bridge"})
+		gc.StateDir = stateDir
+		invoke := &it.FakeInvoke{}
+		gc.Invoke = invoke
+
+		config, err := gc.Cfg.ParseCNIConfFromBytes([]byte(`{"cniVersion": "1.1.0", "name": "weave", "plugins": [{"type": "weave"}]}`))
+		if err != nil {
+			t.Fatalf("Error parsing weave config: %v", err)
+		}
+		for _, containerID := range []string{"valid", "stale"} {
+			err = gc.saveAttachments(containerID, []*utils.PluginInfo{{PluginName: "weave", IfName: "eth0", Config: config}})
+			if err != nil {
+				t.Fatalf("Error saving attachments: %v", err)
+			}
+		}
+
+		conf := *defaultGenieConf
+		for _, containerID := range tests[i].valid {
+			conf.ValidAttachments = append(conf.ValidAttachments, utils.GCAttachment{ContainerID: containerID, IfName: "eth0"})
+		}
+		if err = gc.GarbageCollect(&conf); err != nil {
+			t.Errorf("Test %d: unexpected error on GC: %v", i, err)
+		}
+
+		containers, err := gc.listAttachments()
+		if err != nil || len(containers) != 1 || containers[0] != "valid" {
+			t.Errorf("Test %d: expected only the valid container to be left; got: %v, error: %v", i, containers, err)
+		}
+		if fmt.Sprint(invoke.Collected) != fmt.Sprint(tests[i].expectedCollected) {
+			t.Errorf("Test %d: expected GC of delegates with %v; got: %v", i, tests[i].expectedCollected, invoke.Collected)
+		}
+	}
+}
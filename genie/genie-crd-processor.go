@@ -19,10 +19,10 @@ networking or pod multi-IP based networking.
 package genie
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/cni-genie/CNI-Genie/utils"
-	"os"
 	"strings"
 )
 
@@ -34,8 +34,8 @@ func (gc *GenieController) getPluginInfoFromPhysicalNw(phyNwName string, namespa
 	physicalNwPath := fmt.Sprintf("/apis/alpha.network.k8s.io/v1/namespaces/%s/physicalnetworks/%s", namespace, phyNwName)
 
 	//fmt.Fprintf(os.Stderr, "CNI Genie networks out =%v, err=%v\n", out, err)
-	fmt.Fprintf(os.Stderr, "CNI Genie physical newtwork self link=%v\n", physicalNwPath)
-	physicalNwObj, err := gc.Kc.GetRaw(physicalNwPath)
+	gc.logger().Debugf("Physical newtwork self link=%v", physicalNwPath)
+	physicalNwObj, err := gc.Kc.GetRaw(context.TODO(), physicalNwPath)
 
 	if err != nil {
 		return fmt.Errorf("CNI Genie failed to get physical network object for the network %v, namespace %v\n", phyNwName, namespace)
@@ -46,14 +46,14 @@ func (gc *GenieController) getPluginInfoFromPhysicalNw(phyNwName string, namespa
 		return fmt.Errorf("CNI Genie failed to physical network info: %v", err)
 	}
 	pluginInfo.Refer_nic = physicalNwInfo.Spec.ReferNic
-	fmt.Fprintf(os.Stderr, "CNI Genie physicalNwInfo=%v\n", physicalNwInfo)
+	gc.logger().Debugf("PhysicalNwInfo=%v", physicalNwInfo)
 	if physicalNwInfo.Spec.SharedStatus.DedicatedStatus == true {
 
 		pluginInfo.PluginName = physicalNwInfo.Spec.SharedStatus.Plugin
 	}
 
 	pluginInfo.Subnet = physicalNwInfo.Spec.SharedStatus.Subnet
-	fmt.Fprintf(os.Stderr, "CNI Genie pluginInfo =%v\n", *pluginInfo)
+	gc.logger().Debugf("PluginInfo= %v", *pluginInfo)
 	return nil
 }
 
@@ -64,6 +64,10 @@ Returns the list of plugins intended by user through network crd
 func (gc *GenieController) getPluginInfoFromNwAnnot(networkAnnot string, namespace string) ([]*utils.PluginInfo, error) {
 	var networkName string
 
+	if gc.Standalone {
+		return nil, fmt.Errorf("Logical networks are not supported in standalone mode")
+	}
+
 	logicalNwList := strings.Split(networkAnnot, ",")
 	pluginInfoList := make([]*utils.PluginInfo, 0, len(logicalNwList))
 	for _, logicalNw := range logicalNwList {
@@ -76,21 +80,12 @@ func (gc *GenieController) getPluginInfoFromNwAnnot(networkAnnot string, namespa
 			networkName = strings.TrimSpace(logicalNw)
 		}
 
-		logicalNwPath := fmt.Sprintf("/apis/alpha.network.k8s.io/v1/namespaces/%s/logicalnetworks/%s", namespace,
-			networkName)
-		//fmt.Fprintf(os.Stderr, "CNI Genie networks out =%v, err=%v\n", out, err)
-		fmt.Fprintf(os.Stderr, "CNI Genie logical newtwork self link=%v\n", logicalNwPath)
-		logicalNwObj, err := gc.Kc.GetRaw(logicalNwPath)
-
+		gc.logger().Debugf("Logical network=%s/%s", namespace, networkName)
+		logicalNwInfo, err := gc.Kc.GetLogicalNetwork(context.TODO(), networkName, namespace)
 		if err != nil {
 			return pluginInfoList, fmt.Errorf("CNI Genie failed to get logical network object for the network %v, namespace %v\n", networkName, namespace)
 		}
 
-		logicalNwInfo := utils.LogicalNetwork{}
-		if err = json.Unmarshal(logicalNwObj, &logicalNwInfo); err != nil {
-			return pluginInfoList, fmt.Errorf("CNI Genie failed to logical network (%s:%s) info: %v", namespace, networkName, err)
-		}
-
 		if logicalNwInfo.Spec.PhysicalNet == "" {
 			if logicalNwInfo.Spec.Plugin != "" {
 				pluginInfo.PluginName = logicalNwInfo.Spec.Plugin
@@ -109,9 +104,9 @@ func (gc *GenieController) getPluginInfoFromNwAnnot(networkAnnot string, namespa
 		if logicalNwInfo.Spec.SubSubnet != "" {
 			pluginInfo.Subnet = logicalNwInfo.Spec.SubSubnet
 		}
-		fmt.Fprintf(os.Stderr, "CNI Genie pluginInfoList pluginInfo=%v\n", *pluginInfo)
+		gc.logger().Debugf("PluginInfoList pluginInfo= %v", *pluginInfo)
 
-		pluginInfo.Config, err = gc.loadPluginConfig(pluginInfo.PluginName)
+		pluginInfo.Config, pluginInfo.ConfSource, err = gc.loadPluginConfig(pluginInfo.PluginName)
 		if err != nil {
 			return nil, fmt.Errorf("Error loading plugin configuration for plugin (%s) for logical network (%s:%s): %v", pluginInfo.PluginName, namespace, networkName, err)
 		}
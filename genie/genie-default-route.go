@@ -0,0 +1,128 @@
+package genie
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
+	"github.com/containernetworking/cni/pkg/types"
+	"net"
+	"strings"
+)
+
+const (
+	// DefaultRouteAnnot specifies the plugin, out of the ones in "cni"
+	// annotation, owning the default route of the pod, eg: "macvlan@eth1"
+	DefaultRouteAnnot = "cni-default-route"
+)
+
+// setDefaultRouteFromAnnot marks the attachment named in the default route
+// annotation as the owner of the default route
+func setDefaultRouteFromAnnot(pluginInfoList []*utils.PluginInfo, annot string) error {
+	pluginName := strings.TrimSpace(annot)
+	ifName := ""
+	if i := strings.Index(pluginName, utils.IfNameDelimiter); i >= 0 {
+		ifName = strings.TrimSpace(pluginName[i+1:])
+		pluginName = strings.TrimSpace(pluginName[:i])
+	}
+
+	for _, pluginInfo := range pluginInfoList {
+		if pluginInfo.PluginName == pluginName && (ifName == "" || pluginInfo.IfName == ifName) {
+			pluginInfo.DefaultRoute = true
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Plugin %s requested for default route is not present in cni annotation", annot)
+}
+
+// getDefaultRouteOwner returns the index of the attachment owning the default
+// route, or -1 if no attachment has requested for it
+func getDefaultRouteOwner(pluginInfoList []*utils.PluginInfo) (int, error) {
+	owner := -1
+	for i, pluginInfo := range pluginInfoList {
+		if !pluginInfo.DefaultRoute {
+			continue
+		}
+		if owner >= 0 {
+			return -1, fmt.Errorf("Default route requested for multiple attachments: %s and %s", pluginInfoList[owner].PluginName, pluginInfo.PluginName)
+		}
+		owner = i
+	}
+
+	return owner, nil
+}
+
+func isDefaultRoute(dst net.IPNet) bool {
+	ones, _ := dst.Mask.Size()
+	return ones == 0
+}
+
+// fixDefaultRoute is applied on the result of each attachment when one of the
+// attachments owns the default route. Default routes of the other attachments
+// are removed from their result and from the pod network namespace. The owner
+// gets default routes via the requested gateways, if any. The fixed result
+// replaces the one cached on ADD, so that CHECK and DEL of the attachment are
+// based on the routes actually left in the pod.
+func (gc *GenieController) fixDefaultRoute(pluginInfo *utils.PluginInfo, result types.Result, cniArgs *utils.CNIArgs) (types.Result, error) {
+	res, err := types100.NewResultFromResult(result)
+	if err != nil {
+		return nil, fmt.Errorf("Error converting result to current version: %v", err)
+	}
+
+	if !pluginInfo.DefaultRoute {
+		routes := make([]*types100.Route, 0, len(res.Routes))
+		for _, route := range res.Routes {
+			if !isDefaultRoute(route.Dst) {
+				routes = append(routes, route)
+			}
+		}
+		res.Routes = routes
+
+		// The delegate might have set up a default route without reporting it
+		if cniArgs.Netns != "" && gc.Routes != nil {
+			gc.attachmentLog(pluginInfo).Infof("Removing default routes")
+			if err = gc.Routes.DelDefaultRoutes(cniArgs.Netns, pluginInfo.IfName); err != nil {
+				return nil, err
+			}
+		}
+		return res, gc.cacheFixedResult(pluginInfo, res, cniArgs)
+	}
+
+	for _, gw := range pluginInfo.GatewayRequest {
+		dst := net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
+		if gw.To4() != nil {
+			dst = net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+		}
+
+		replaced := false
+		for _, route := range res.Routes {
+			if isDefaultRoute(route.Dst) && (route.Dst.IP.To4() != nil) == (gw.To4() != nil) {
+				route.GW = gw
+				replaced = true
+			}
+		}
+		if !replaced {
+			res.Routes = append(res.Routes, &types100.Route{Dst: dst, GW: gw})
+		}
+
+		if cniArgs.Netns != "" && gc.Routes != nil {
+			gc.attachmentLog(pluginInfo).Infof("Setting default route via %s", gw)
+			if err = gc.Routes.AddDefaultRoute(cniArgs.Netns, pluginInfo.IfName, gw); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, gc.cacheFixedResult(pluginInfo, res, cniArgs)
+}
+
+func (gc *GenieController) cacheFixedResult(pluginInfo *utils.PluginInfo, result types.Result, cniArgs *utils.CNIArgs) error {
+	rtConf, err := runtimeConf(cniArgs, pluginInfo)
+	if err != nil {
+		return fmt.Errorf("Error generating runtime conf: %v", err)
+	}
+	if err = gc.Invoke.SetCachedResult(pluginInfo.Config, rtConf, result); err != nil {
+		return fmt.Errorf("Error caching result with the default route fixed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package genie_test
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/genie/genietest"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"net"
+	"testing"
+)
+
+func TestCheckAfterDefaultRouteFixed(t *testing.T) {
+	dir := genietest.NewConfDir()
+	invoker := genietest.NewInvoker()
+	for n, name := range []string{"weave", "bridge"} {
+		// CHECK needs version 0.4.0 or later
+		dir.AddFile("10-"+name+".conf", []byte(fmt.Sprintf(`{"cniVersion": "0.4.0", "name": %q, "type": %q}`, name, name)))
+		dir.AddBinary(name)
+		addr, _ := types.ParseCIDR(fmt.Sprintf("10.%d.0.2/24", n+1))
+		dst, _ := types.ParseCIDR("0.0.0.0/0")
+		invoker.Results[name] = &current.Result{
+			CNIVersion: "0.4.0",
+			IPs:        []*current.IPConfig{{Version: "4", Address: *addr}},
+			Routes:     []*types.Route{{Dst: *dst, GW: net.IPv4(10, byte(n+1), 0, 1)}},
+		}
+	}
+
+	gc, err := attach(dir, invoker, map[string]string{"cni": "weave, bridge", genie.DefaultRouteAnnot: "bridge"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Error adding pod network: %v", err)
+	}
+	if err = gc.CheckPodNetwork(cniArgs(), genieConf); err != nil {
+		t.Fatalf("Error checking pod network: %v", err)
+	}
+
+	// The delegates are checked against the routes left in the pod, not the
+	// ones they reported on ADD
+	expectedDefaultRoutes := map[string]int{"weave": 0, "bridge": 1}
+	checks := invoker.Calls(genietest.CommandCheck)
+	if len(checks) != len(expectedDefaultRoutes) {
+		t.Fatalf("Expected CHECK of %d networks; got: %v", len(expectedDefaultRoutes), invoker.Networks(genietest.CommandCheck))
+	}
+	for _, c := range checks {
+		if c.PrevResult == nil {
+			t.Errorf("Expected prevResult on CHECK of %s", c.Network)
+			continue
+		}
+		prevResult, err := types100.NewResultFromResult(c.PrevResult)
+		if err != nil {
+			t.Errorf("Error converting prevResult of %s: %v", c.Network, err)
+			continue
+		}
+		defaultRoutes := 0
+		for _, route := range prevResult.Routes {
+			if ones, _ := route.Dst.Mask.Size(); ones == 0 {
+				defaultRoutes++
+			}
+		}
+		if defaultRoutes != expectedDefaultRoutes[c.Network] {
+			t.Errorf("Expected %d default routes in prevResult of %s; got: %v", expectedDefaultRoutes[c.Network], c.Network, prevResult.Routes)
+		}
+	}
+}
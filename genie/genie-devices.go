@@ -0,0 +1,89 @@
+package genie
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	"github.com/cni-genie/CNI-Genie/podresources"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"strings"
+)
+
+// resourceName gets the device plugin resource of a network attachment definition
+func resourceName(network *networkcrd.NetworkAttachmentDefinition) string {
+	return strings.TrimSpace(network.Annotations[networkcrd.ResourceNameAnnot])
+}
+
+// assignDevices passes the devices allocated to the pod by the device plugins
+// to the attachments of networks backed by a device resource, eg: the VFs of
+// an SR-IOV network, as deviceID runtime config. Each attachment gets its own
+// device, in the order of the attachments, unless a device is requested for
+// it in the network selection annotation.
+func (gc *GenieController) assignDevices(pluginInfoList []*utils.PluginInfo, k8sArgs *utils.K8sArgs) error {
+	pod := podresources.Pod{
+		Namespace: string(k8sArgs.K8S_POD_NAMESPACE),
+		Name:      string(k8sArgs.K8S_POD_NAME),
+		UID:       string(k8sArgs.K8S_POD_UID),
+	}
+	// Devices requested in the annotation are reserved first, so that they
+	// are not passed to earlier attachments
+	free := map[string][]string{}
+	for _, pluginInfo := range pluginInfoList {
+		if pluginInfo.ResourceName == "" {
+			continue
+		}
+		if _, ok := free[pluginInfo.ResourceName]; !ok {
+			devices, err := gc.getDevices(pod, pluginInfo)
+			if err != nil {
+				return err
+			}
+			free[pluginInfo.ResourceName] = devices
+		}
+		if requested := requestedDevice(pluginInfo); requested != "" {
+			free[pluginInfo.ResourceName] = removeDevice(free[pluginInfo.ResourceName], requested)
+		}
+	}
+
+	for _, pluginInfo := range pluginInfoList {
+		if pluginInfo.ResourceName == "" || requestedDevice(pluginInfo) != "" {
+			continue
+		}
+		devices := free[pluginInfo.ResourceName]
+		if len(devices) == 0 {
+			return fmt.Errorf("No device of %s left for network %s: the pod must request one %s for each of its attachments to networks of the resource", pluginInfo.ResourceName, pluginInfo.PluginName, pluginInfo.ResourceName)
+		}
+		gc.logger().Infof("Passing device %s of %s to network %s", devices[0], pluginInfo.ResourceName, pluginInfo.PluginName)
+		if pluginInfo.CapabilityArgs == nil {
+			pluginInfo.CapabilityArgs = make(map[string]interface{})
+		}
+		pluginInfo.CapabilityArgs[utils.CapabilityDeviceID] = devices[0]
+		free[pluginInfo.ResourceName] = devices[1:]
+	}
+	return nil
+}
+
+func (gc *GenieController) getDevices(pod podresources.Pod, pluginInfo *utils.PluginInfo) ([]string, error) {
+	if gc.PodResources == nil {
+		return nil, fmt.Errorf("Error getting devices of %s for network %s: device plugin resources are not available", pluginInfo.ResourceName, pluginInfo.PluginName)
+	}
+	devices, err := gc.PodResources.DeviceIDs(pod, pluginInfo.ResourceName)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting devices of %s for network %s: %v", pluginInfo.ResourceName, pluginInfo.PluginName, err)
+	}
+	gc.logger().Debugf("Devices of %s allocated to pod %s/%s: %v", pluginInfo.ResourceName, pod.Namespace, pod.Name, devices)
+	return devices, nil
+}
+
+func requestedDevice(pluginInfo *utils.PluginInfo) string {
+	requested, _ := pluginInfo.CapabilityArgs[utils.CapabilityDeviceID].(string)
+	return requested
+}
+
+func removeDevice(devices []string, device string) []string {
+	var left []string
+	for _, d := range devices {
+		if d != device {
+			left = append(left, d)
+		}
+	}
+	return left
+}
@@ -0,0 +1,91 @@
+package genie_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/genie/genietest"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	"github.com/cni-genie/CNI-Genie/podresources"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssignDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genietest")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	server, err := genietest.NewPodResourcesServer(dir)
+	if err != nil {
+		t.Fatalf("Error starting PodResources server: %v", err)
+	}
+	defer server.Close()
+	server.AddDevices("default", "testpod", "app", "intel.com/sriov", "0000:03:02.0", "0000:03:02.1")
+
+	sriovNet := func(name string) runtime.Object {
+		nad := newNetAttachDef(name, "default", `{"cniVersion": "0.3.1", "name": "`+name+`", "type": "sriov", "capabilities": {"deviceID": true}}`)
+		nad.Annotations = map[string]string{networkcrd.ResourceNameAnnot: "intel.com/sriov"}
+		return nad
+	}
+	netAttachDefs := []runtime.Object{
+		sriovNet("sriov-net1"),
+		sriovNet("sriov-net2"),
+		sriovNet("sriov-net3"),
+		newNetAttachDef("macvlan-net", "default", `{"cniVersion": "0.3.1", "name": "macvlan-net", "type": "macvlan"}`),
+	}
+
+	tests := []struct {
+		annot             map[string]string
+		noPodResources    bool
+		expectedErr       error
+		expectedDeviceIDs []string
+	}{
+		{
+			annot:             map[string]string{genie.NetworkAttachmentDefinitionAnnot: "sriov-net1, macvlan-net, sriov-net2"},
+			expectedDeviceIDs: []string{"", "0000:03:02.0", "", "0000:03:02.1"},
+		},
+		{
+			// A device requested in the annotation is not passed to another attachment
+			annot:             map[string]string{genie.NetworkAttachmentDefinitionAnnot: `[{"name": "sriov-net1"}, {"name": "sriov-net2", "deviceID": "0000:03:02.0"}]`},
+			expectedDeviceIDs: []string{"", "0000:03:02.1", "0000:03:02.0"},
+		},
+		{
+			annot:             map[string]string{genie.DefaultNetworkAnnot: "sriov-net1", genie.NetworkAttachmentDefinitionAnnot: "sriov-net2"},
+			expectedDeviceIDs: []string{"0000:03:02.0", "0000:03:02.1"},
+		},
+		{
+			annot:       map[string]string{genie.NetworkAttachmentDefinitionAnnot: "sriov-net1, sriov-net2, sriov-net3"},
+			expectedErr: errors.New("No device of intel.com/sriov left for network sriov-net3"),
+		},
+		{
+			annot:          map[string]string{genie.NetworkAttachmentDefinitionAnnot: "sriov-net1"},
+			noPodResources: true,
+			expectedErr:    errors.New("device plugin resources are not available"),
+		},
+	}
+
+	for i := range tests {
+		invoker := genietest.NewInvoker()
+		_, err = attach(newConfDir(), invoker, tests[i].annot, netAttachDefs, func(gc *genie.GenieController, conf *utils.GenieConf) {
+			if !tests[i].noPodResources {
+				gc.PodResources = podresources.NewClient(server.Socket, filepath.Join(dir, "absent"))
+			}
+			conf.DefaultNetworkNamespaces = []string{"default"}
+		})
+		checkError(t, i, tests[i].expectedErr, err)
+		var deviceIDs []string
+		for _, call := range invoker.Calls(genietest.CommandAdd) {
+			id, _ := call.RuntimeConf.CapabilityArgs[utils.CapabilityDeviceID].(string)
+			deviceIDs = append(deviceIDs, id)
+		}
+		if fmt.Sprint(deviceIDs) != fmt.Sprint(tests[i].expectedDeviceIDs) {
+			t.Errorf("Test %d: expected device ids: %v; got: %v", i, tests[i].expectedDeviceIDs, deviceIDs)
+		}
+	}
+}
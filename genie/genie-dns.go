@@ -0,0 +1,107 @@
+package genie
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/containernetworking/cni/pkg/types"
+	"strings"
+)
+
+const (
+	// DNSPolicyUnion uses the union of the dns configurations of all the
+	// attachments, without duplicates and in the order of attachments
+	DNSPolicyUnion = "union"
+	// DNSPolicyPrimary uses the dns configuration of the primary attachment
+	// only, the one owning the default route of the pod, or else the first one
+	DNSPolicyPrimary = "primary"
+	// DNSPolicyAttachment uses the dns configuration of the attachment
+	// specified by dns_attachment in genie configuration
+	DNSPolicyAttachment = "attachment"
+)
+
+// dnsEntry is the dns configuration returned by a single attachment
+type dnsEntry struct {
+	name      string
+	ifName    string
+	isDefault bool
+	dns       types.DNS
+}
+
+func validateDNSPolicy(conf *utils.GenieConf) error {
+	switch conf.DNSPolicy {
+	case "", DNSPolicyUnion, DNSPolicyPrimary:
+	case DNSPolicyAttachment:
+		if strings.TrimSpace(conf.DNSAttachment) == "" {
+			return fmt.Errorf("dns_attachment must be specified for dns policy %q", DNSPolicyAttachment)
+		}
+	default:
+		return fmt.Errorf("Invalid dns policy %q", conf.DNSPolicy)
+	}
+	return nil
+}
+
+// mergeDNS merges the dns configurations of the attachments as per the dns
+// policy. The entries are expected in the order of attachments.
+func (gc *GenieController) mergeDNS(entries []dnsEntry) types.DNS {
+	if len(entries) == 0 {
+		return types.DNS{}
+	}
+
+	switch gc.DNSPolicy {
+	case DNSPolicyPrimary:
+		return primaryDNS(entries)
+	case DNSPolicyAttachment:
+		name := strings.TrimSpace(gc.DNSAttachment)
+		ifName := ""
+		if i := strings.Index(name, utils.IfNameDelimiter); i >= 0 {
+			ifName = strings.TrimSpace(name[i+1:])
+			name = strings.TrimSpace(name[:i])
+		}
+		for _, entry := range entries {
+			if entry.name == name && (ifName == "" || entry.ifName == ifName) {
+				return entry.dns
+			}
+		}
+		gc.logger().Warningf("DNS attachment %s not present for pod, using dns of primary attachment", gc.DNSAttachment)
+		return primaryDNS(entries)
+	}
+
+	return unionDNS(entries)
+}
+
+// primaryDNS returns the dns configuration of the attachment owning the
+// default route, or of the first attachment if none does
+func primaryDNS(entries []dnsEntry) types.DNS {
+	for _, entry := range entries {
+		if entry.isDefault {
+			return entry.dns
+		}
+	}
+	return entries[0].dns
+}
+
+func unionDNS(entries []dnsEntry) types.DNS {
+	var dns types.DNS
+	seen := make(map[string]bool)
+	appendUnique := func(list []string, kind string, values []string) []string {
+		for _, value := range values {
+			if key := kind + "/" + value; !seen[key] {
+				seen[key] = true
+				list = append(list, value)
+			}
+		}
+		return list
+	}
+
+	for _, entry := range entries {
+		dns.Nameservers = appendUnique(dns.Nameservers, "nameserver", entry.dns.Nameservers)
+		dns.Search = appendUnique(dns.Search, "search", entry.dns.Search)
+		dns.Options = appendUnique(dns.Options, "option", entry.dns.Options)
+		// resolv.conf can have a single domain, that of the first attachment specifying it wins
+		if dns.Domain == "" {
+			dns.Domain = entry.dns.Domain
+		}
+	}
+
+	return dns
+}
@@ -0,0 +1,67 @@
+package genie
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/client"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
+	"github.com/containernetworking/cni/pkg/types"
+	"k8s.io/api/core/v1"
+	"strings"
+)
+
+// Reasons of the events recorded on pods for their attachments
+const (
+	EventNetworkAttached = "NetworkAttached"
+	EventAttachFailed    = "AttachFailed"
+)
+
+// recordAttached records on the pod that one of its networks was attached,
+// with the interface and the addresses of the attachment
+func (gc *GenieController) recordAttached(cniArgs *utils.CNIArgs, pluginInfo *utils.PluginInfo, result types.Result) {
+	var ips []string
+	if res, err := types100.NewResultFromResult(result); err == nil {
+		for _, ip := range res.IPs {
+			ips = append(ips, ip.Address.String())
+		}
+	}
+	message := fmt.Sprintf("Attached network %s on interface %s", pluginInfo.PluginName, pluginInfo.IfName)
+	if len(ips) > 0 {
+		message += fmt.Sprintf(" with IPs %s", strings.Join(ips, ", "))
+	}
+	gc.recordPodEvent(cniArgs, v1.EventTypeNormal, EventNetworkAttached, message)
+}
+
+// recordAttachFailed records on the pod that one of its networks failed to
+// attach, with the error of the delegate
+func (gc *GenieController) recordAttachFailed(cniArgs *utils.CNIArgs, pluginInfo *utils.PluginInfo, err error) {
+	message := fmt.Sprintf("Failed to attach network %s on interface %s: %v", pluginInfo.PluginName, pluginInfo.IfName, err)
+	if pluginInfo.Optional {
+		message = fmt.Sprintf("Failed to attach optional network %s on interface %s, continuing without it: %v", pluginInfo.PluginName, pluginInfo.IfName, err)
+	}
+	gc.recordPodEvent(cniArgs, v1.EventTypeWarning, EventAttachFailed, message)
+}
+
+// recordPodEvent records an event on the pod of the invocation. Events are
+// not recorded without a pod, eg: in standalone mode, and failing to record
+// them does not fail the invocation.
+func (gc *GenieController) recordPodEvent(cniArgs *utils.CNIArgs, eventType, reason, message string) {
+	if gc.Kc == nil || gc.Standalone || gc.DryRun {
+		return
+	}
+	k8sArgs, err := loadArgs(cniArgs)
+	if err != nil || k8sArgs.K8S_POD_NAME == "" || k8sArgs.K8S_POD_NAMESPACE == "" {
+		return
+	}
+	pod := client.PodRef{
+		Namespace: string(k8sArgs.K8S_POD_NAMESPACE),
+		Name:      string(k8sArgs.K8S_POD_NAME),
+		UID:       string(k8sArgs.K8S_POD_UID),
+	}
+	err = gc.Kc.RecordPodEvent(pod, eventType, reason, message)
+	if err == client.ErrEventThrottled {
+		gc.logger().Debugf("Not recording event %s on pod %s/%s: %v", reason, pod.Namespace, pod.Name, err)
+	} else if err != nil {
+		gc.logger().Warningf("Error recording event %s on pod %s/%s: %v", reason, pod.Namespace, pod.Name, err)
+	}
+}
@@ -0,0 +1,272 @@
+package genie
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"github.com/containernetworking/cni/pkg/types"
+	"io"
+	"io/ioutil"
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	// ExplainCommand is the command line subcommand resolving the networks of a pod
+	ExplainCommand = "explain"
+	explainUsage   = `Usage: genie explain [flags] (--manifest <pod manifest> | <namespace>/<name>)
+
+Resolves the networks of a pod as CNI Genie would on ADD, and prints the
+attachments in the order they would be added. No delegate is invoked, no
+configuration file is placed and the pod is not updated.
+
+Flags:
+`
+)
+
+// Explain implements the explain subcommand. It resolves the networks of the
+// pod given either as a manifest or as namespace/name, and writes the
+// resolved attachments to out.
+func Explain(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet(ExplainCommand, flag.ContinueOnError)
+	flags.SetOutput(out)
+	confDir := flags.String("conf-dir", DefaultNetDir, "Directory of the cni configuration files of the node")
+	genieConfFile := flags.String("genie-conf", "", "Genie configuration file, "+GenieConfFile+" in conf dir by default")
+	kubeconfig := flags.String("kubeconfig", "", "Kubeconfig file, overrides the one in genie configuration")
+	manifest := flags.String("manifest", "", "Pod manifest, in yaml or json, to resolve the networks for")
+	logLevel := flags.String("log-level", "warning", "Level of the logs written to stderr")
+	flags.Usage = func() {
+		fmt.Fprint(out, explainUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if (*manifest == "") == (flags.NArg() != 1) {
+		flags.Usage()
+		return fmt.Errorf("Either a pod manifest or the namespace/name of a pod is required")
+	}
+
+	if err := logging.Configure(*logLevel, "", logging.FormatText); err != nil {
+		return err
+	}
+
+	if *genieConfFile == "" {
+		*genieConfFile = filepath.Join(*confDir, GenieConfFile)
+	}
+	confData, err := ioutil.ReadFile(*genieConfFile)
+	if err != nil {
+		return fmt.Errorf("Error reading genie configuration: %v", err)
+	}
+	conf, err := ParseCNIConf(confData)
+	if err != nil {
+		return err
+	}
+	if *kubeconfig != "" {
+		conf.Kubernetes.Kubeconfig = *kubeconfig
+	}
+
+	// A pod given as a manifest needs the api server only for the network
+	// objects it selects, explaining it works off cluster otherwise
+	var pod *v1.Pod
+	var gc *GenieController
+	if *manifest != "" {
+		if pod, err = readPodManifest(*manifest); err != nil {
+			return err
+		}
+	}
+	if pod != nil && !selectsNetworkObjects(pod.Annotations, conf) {
+		gc = NewGenieControllerWithClient(conf, nil)
+	} else if gc, err = NewGenieController(conf); err != nil {
+		return err
+	}
+	gc.Cfg.NetDir = *confDir
+	gc.DryRun = true
+
+	if pod == nil {
+		if pod, err = getPodByKey(gc, flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	return gc.explainPod(pod, conf, out)
+}
+
+// readPodManifest reads a pod from a yaml or json manifest file
+func readPodManifest(file string) (*v1.Pod, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening pod manifest: %v", err)
+	}
+	defer f.Close()
+
+	pod := &v1.Pod{}
+	if err = yaml.NewYAMLOrJSONDecoder(f, 4096).Decode(pod); err != nil {
+		return nil, fmt.Errorf("Error decoding pod manifest %s: %v", file, err)
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = "default"
+	}
+	return pod, nil
+}
+
+// getPodByKey gets a pod given as namespace/name from the api server
+func getPodByKey(gc *GenieController, key string) (*v1.Pod, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("Invalid pod %q, expected namespace/name", key)
+	}
+	if gc.Kc == nil {
+		return nil, fmt.Errorf("Pod %s can not be looked up in standalone mode, pass its manifest instead", key)
+	}
+	pod, err := gc.Kc.GetPod(context.TODO(), parts[1], parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("Error getting pod %s: %v", key, err)
+	}
+	return pod, nil
+}
+
+// selectsNetworkObjects tells whether resolving the networks of a pod with
+// the given annotations looks up network attachment definitions or logical
+// networks in the api server
+func selectsNetworkObjects(annot map[string]string, conf *utils.GenieConf) bool {
+	if usesNetAttachDefs(annot) {
+		return true
+	}
+	if cni, ok := annot["cni"]; ok && strings.TrimSpace(cni) == "" && strings.TrimSpace(annot["networks"]) != "" {
+		return true
+	}
+	for _, ref := range append([]string{conf.ClusterNetwork}, conf.DefaultNetworks...) {
+		if isNetAttachDefReference(strings.TrimSpace(ref)) {
+			return true
+		}
+	}
+	return false
+}
+
+// explainPod resolves the networks of the pod and writes the attachments as a table
+func (gc *GenieController) explainPod(pod *v1.Pod, conf *utils.GenieConf, out io.Writer) error {
+	k8sArgs := &utils.K8sArgs{
+		K8S_POD_NAME:      types.UnmarshallableString(pod.Name),
+		K8S_POD_NAMESPACE: types.UnmarshallableString(pod.Namespace),
+	}
+	annot := pod.Annotations
+	if annot == nil {
+		annot = map[string]string{}
+	}
+
+	pluginInfoList, err := gc.ResolvePodNetworks(annot, k8sArgs, conf)
+	if err != nil {
+		return fmt.Errorf("Error resolving networks of pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+
+	fmt.Fprintf(out, "Pod:        %s/%s\n", pod.Namespace, pod.Name)
+	fmt.Fprintf(out, "Selection:  %s\n", selectionSource(annot, conf))
+	fmt.Fprintf(out, "Conf dir:   %s\n\n", gc.Cfg.NetDir)
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "IFNAME\tNETWORK\tPLUGINS\tCONFIG\tSUBNET\tOPTIONAL ARGS\tRUNTIME CONFIG\tFLAGS")
+	for _, pluginInfo := range pluginInfoList {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			pluginInfo.IfName,
+			pluginInfo.PluginName,
+			orNone(pluginTypes(pluginInfo)),
+			orNone(pluginInfo.ConfSource),
+			orNone(pluginInfo.Subnet),
+			orNone(joinSorted(pluginInfo.OptionalArgs)),
+			orNone(capabilityNames(pluginInfo)),
+			orNone(attachmentFlags(pluginInfo)))
+	}
+	return w.Flush()
+}
+
+// selectionSource describes the annotation from which the networks are
+// selected, in the same order of precedence as getPluginInfoList
+func selectionSource(annot map[string]string, conf *utils.GenieConf) string {
+	if _, ok := annot[NetworkAttachmentDefinitionAnnot]; ok {
+		return NetworkAttachmentDefinitionAnnot + " annotation"
+	}
+	if usesNetAttachDefs(annot) {
+		return DefaultNetworkAnnot + " annotation"
+	}
+	cni, ok := annot["cni"]
+	switch {
+	case !ok && conf.DefaultPlugin != "":
+		return "default plugins of genie configuration"
+	case !ok && conf.ClusterNetwork != "":
+		return "cluster network of genie configuration (" + conf.ClusterNetwork + ")"
+	case !ok:
+		return "cluster default network"
+	case strings.TrimSpace(cni) != "":
+		return "cni annotation"
+	case strings.TrimSpace(annot["networks"]) != "":
+		return "networks annotation"
+	}
+	return "cAdvisor (not written to the pod in dry run)"
+}
+
+func pluginTypes(pluginInfo *utils.PluginInfo) string {
+	if pluginInfo.Config == nil {
+		return ""
+	}
+	names := make([]string, 0, len(pluginInfo.Config.Plugins))
+	for _, plugin := range pluginInfo.Config.Plugins {
+		names = append(names, plugin.Network.Type)
+	}
+	return strings.Join(names, ",")
+}
+
+// capabilityNames lists the runtime config which would be passed to the delegate
+func capabilityNames(pluginInfo *utils.PluginInfo) string {
+	supported := supportedCapabilityArgs(pluginInfo.Config, pluginInfo.CapabilityArgs)
+	names := make([]string, 0, len(supported))
+	for name := range supported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func attachmentFlags(pluginInfo *utils.PluginInfo) string {
+	var flags []string
+	if pluginInfo.Optional {
+		flags = append(flags, "optional")
+	}
+	if pluginInfo.DefaultRoute {
+		route := "default-route"
+		for _, gw := range pluginInfo.GatewayRequest {
+			route += "@" + gw.String()
+		}
+		flags = append(flags, route)
+	}
+	// The device itself is known only once the kubelet has allocated it
+	if pluginInfo.ResourceName != "" {
+		flags = append(flags, "device@"+pluginInfo.ResourceName)
+	}
+	return strings.Join(flags, ",")
+}
+
+func joinSorted(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package genie
+
+import (
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+)
+
+// SetupLogging configures logging as per genie configuration. Every log line
+// of the invocation carries the container id and the pod namespace and name.
+func SetupLogging(conf *utils.GenieConf, cniArgs *utils.CNIArgs) {
+	if err := logging.Configure(conf.LogLevel, conf.LogFile, conf.LogFormat); err != nil {
+		logging.Errorf("Error configuring logging, continuing with the defaults: %v", err)
+	}
+	if cniArgs != nil {
+		SetLogFields(cniArgs)
+	}
+}
+
+// SetLogFields sets the container id and the pod namespace and name of the
+// invocation to be carried by every log line of the process
+func SetLogFields(cniArgs *utils.CNIArgs) {
+	logging.SetFields(LogFields(cniArgs))
+}
+
+// LogFields returns the container id and the pod namespace and name of the
+// invocation, for the log lines of a controller serving one of several
+// concurrent invocations, eg: in genie daemon
+func LogFields(cniArgs *utils.CNIArgs) logging.Fields {
+	fields := logging.Fields{}
+	if cniArgs.ContainerID != "" {
+		fields["containerID"] = cniArgs.ContainerID
+	}
+	if k8sArgs, err := loadArgs(cniArgs); err == nil {
+		if k8sArgs.K8S_POD_NAMESPACE != "" {
+			fields["podNamespace"] = string(k8sArgs.K8S_POD_NAMESPACE)
+		}
+		if k8sArgs.K8S_POD_NAME != "" {
+			fields["podName"] = string(k8sArgs.K8S_POD_NAME)
+		}
+	}
+	return fields
+}
+
+// logger returns the logger of the invocation served by the controller
+func (gc *GenieController) logger() *logging.Entry {
+	if gc.Log != nil {
+		return gc.Log
+	}
+	return logging.WithFields(nil)
+}
+
+// attachmentLog returns the logger for the operations on a single attachment
+func (gc *GenieController) attachmentLog(pluginInfo *utils.PluginInfo) *logging.Entry {
+	return gc.logger().WithFields(logging.Fields{
+		"plugin": pluginInfo.PluginName,
+		"ifName": pluginInfo.IfName,
+	})
+}
@@ -0,0 +1,65 @@
+package genie
+
+import (
+	"github.com/cni-genie/CNI-Genie/utils"
+)
+
+const (
+	// MesosResultVersion is the version of the result understood by the cni
+	// isolator of Mesos
+	MesosResultVersion = "0.2.0"
+)
+
+// isMesos reports whether genie is invoked by the cni isolator of Mesos,
+// which passes the network info of the container in the netconf args
+func isMesos(conf *utils.GenieConf) bool {
+	return conf.Args != nil && conf.Args.Mesos != nil
+}
+
+// ResultVersion returns the cni version in which the result is to be printed
+func ResultVersion(conf *utils.GenieConf) string {
+	if isMesos(conf) {
+		return MesosResultVersion
+	}
+	return conf.CNIVersion
+}
+
+// netConfLabels picks the selection annotations from the labels passed in the
+// netconf args, as per the cni conventions and by Mesos. Labels of the Mesos
+// network info take precedence.
+func netConfLabels(conf *utils.GenieConf) map[string]string {
+	annot := make(map[string]string)
+	if conf.Args == nil {
+		return annot
+	}
+	var labels []utils.Label
+	if conf.Args.Cni != nil {
+		labels = append(labels, conf.Args.Cni.Labels...)
+	}
+	if conf.Args.Mesos != nil {
+		labels = append(labels, conf.Args.Mesos.NetworkInfo.Labels.Labels...)
+	}
+	for _, label := range labels {
+		if isSelectionAnnotation(label.Key) {
+			annot[label.Key] = label.Value
+		}
+	}
+	return annot
+}
+
+// setRuntimeIfName names the first attachment as requested by Mesos, unless it
+// requests a name itself. Mesos names the interface of every network it joins
+// the container to, and expects the interface with that name to be set up.
+func setRuntimeIfName(pluginInfoList []*utils.PluginInfo, cniArgs *utils.CNIArgs, conf *utils.GenieConf) {
+	if !isMesos(conf) || cniArgs.IfName == "" || len(pluginInfoList) == 0 {
+		return
+	}
+	for _, pluginInfo := range pluginInfoList {
+		if pluginInfo.IfName == cniArgs.IfName {
+			return
+		}
+	}
+	if pluginInfoList[0].IfName == "" {
+		pluginInfoList[0].IfName = cniArgs.IfName
+	}
+}
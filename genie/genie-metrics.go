@@ -0,0 +1,37 @@
+package genie
+
+import (
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"github.com/cni-genie/CNI-Genie/utils/metrics"
+	"time"
+)
+
+const (
+	// VerbAdd, VerbDel and VerbCheck are the verb labels of the delegate metrics
+	VerbAdd   = "ADD"
+	VerbDel   = "DEL"
+	VerbCheck = "CHECK"
+)
+
+// observeDelegate records the latency and the outcome of a delegate invocation.
+// The plugin label is the type of the first plugin in the delegate configuration,
+// while the network label is the name the attachment was requested with.
+func observeDelegate(pluginInfo *utils.PluginInfo, verb string, start time.Time, err error) {
+	plugin := pluginInfo.PluginName
+	if pluginInfo.Config != nil && len(pluginInfo.Config.Plugins) > 0 {
+		plugin = pluginInfo.Config.Plugins[0].Network.Type
+	}
+	metrics.ObserveDelegate(plugin, pluginInfo.PluginName, verb, err, time.Since(start))
+}
+
+// FlushMetrics adds the metrics recorded during this invocation to the metrics
+// file, if one is configured. Failure in doing so does not fail the invocation.
+func FlushMetrics(conf *utils.GenieConf) {
+	if conf.MetricsFile == "" {
+		return
+	}
+	if err := metrics.Flush(conf.MetricsFile); err != nil {
+		logging.Warningf("Error writing metrics: %v", err)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/cni-genie/CNI-Genie/networkcrd"
 	"github.com/cni-genie/CNI-Genie/utils"
 	"github.com/containernetworking/cni/libcni"
-	"os"
 	"strings"
 )
 
@@ -13,29 +12,37 @@ const (
 	GenieConfFile = "00-genie.conf"
 )
 
-func (gc *GenieController) parseNetAttachDefAnnot(annot string, k8sArgs *utils.K8sArgs) ([]*utils.PluginInfo, error) {
+func (gc *GenieController) parseNetAttachDefAnnot(podAnnot map[string]string, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) ([]*utils.PluginInfo, error) {
 	var pluginInfoList []*utils.PluginInfo
-	config, err := gc.getClusterNetwork(gc.Cfg.NetDir)
+	defaultNetwork, err := gc.getPodDefaultNetwork(podAnnot, k8sArgs, conf)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie found default network for cluster: %s\n", config.Plugins[0].Network.Type)
-	pluginInfoList = append(pluginInfoList, &utils.PluginInfo{PluginName: config.Plugins[0].Network.Type, Config: config, IfName: DefaultIfNamePrefix + "0"})
+	gc.logger().Infof("Found default network for pod: %s", defaultNetwork.PluginName)
+	pluginInfoList = append(pluginInfoList, defaultNetwork)
 
-	networks, err := networkcrd.GetNetworkInfo(annot, string(k8sArgs.K8S_POD_NAMESPACE))
-	if err != nil {
-		return nil, fmt.Errorf("Error parsing network selection annotation: %v", err)
+	var networks []networkcrd.NetworkSelectionElement
+	if annot := podAnnot[NetworkAttachmentDefinitionAnnot]; strings.TrimSpace(annot) != "" {
+		networks, err = networkcrd.GetNetworkInfo(annot, string(k8sArgs.K8S_POD_NAMESPACE))
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing network selection annotation: %v", err)
+		}
+		if conf.NamespaceIsolation {
+			if err = networkcrd.CheckNamespaceIsolation(networks, string(k8sArgs.K8S_POD_NAMESPACE), conf.GlobalNamespaces); err != nil {
+				return nil, err
+			}
+		}
 	}
-	fmt.Fprintf(os.Stderr, "CNI Genie network elements from network selection annotation: %+v\n", networks)
+	gc.logger().Debugf("Network elements from network selection annotation: %+v", networks)
 
 	for _, netElem := range networks {
-		network, err := networkcrd.GetNetworkCRDObject(gc.Kc, netElem.Name, netElem.Namespace)
+		network, err := gc.getNetworkObject(netElem.Name, netElem.Namespace)
 		if err != nil {
 			return nil, fmt.Errorf("Error getting network crd object: %v", err)
 		}
 
 		pluginInfo := utils.PluginInfo{}
-		pluginInfo.Config, err = gc.getNetworkConfig(network)
+		pluginInfo.Config, pluginInfo.ConfSource, err = gc.getNetworkConfig(network)
 		if err != nil {
 			return nil, err
 		}
@@ -49,54 +56,66 @@ func (gc *GenieController) parseNetAttachDefAnnot(annot string, k8sArgs *utils.K
 		if netElem.Mac != "" {
 			pluginInfo.OptionalArgs["mac"] = netElem.Mac
 		}
+		pluginInfo.CapabilityArgs = getCapabilityArgs(&netElem)
 		pluginInfo.PluginName = network.Name
+		pluginInfo.ResourceName = resourceName(network)
 		pluginInfo.IfName = netElem.Interface
+		pluginInfo.Optional = netElem.Optional
+		if len(netElem.GatewayRequest) > 0 {
+			pluginInfo.DefaultRoute = true
+			pluginInfo.GatewayRequest = netElem.GatewayRequest
+		}
 		pluginInfoList = append(pluginInfoList, &pluginInfo)
 	}
 
-	return pluginInfoList, nil
+	return gc.appendDefaultNetworks(pluginInfoList, conf)
 }
 
-func (gc *GenieController) getNetworkConfig(network *networkcrd.NetworkAttachmentDefinition) (*libcni.NetworkConfigList, error) {
+// getNetworkConfig gets the delegate configuration of a network attachment
+// definition. It also returns where the configuration comes from.
+func (gc *GenieController) getNetworkConfig(network *networkcrd.NetworkAttachmentDefinition) (*libcni.NetworkConfigList, string, error) {
 	var config *libcni.NetworkConfigList
+	var source string
 	var err error
 	emptySpec := networkcrd.NetworkAttachmentDefinitionSpec{}
 	if network.Spec == emptySpec || network.Spec.Config == "" {
-		config, err = networkcrd.GetConfigFromFile(network, gc.Cfg.NetDir)
+		config, err = networkcrd.GetConfigFromFile(network, gc.Cfg)
+		source = fmt.Sprintf("configuration named %s in %s", network.Name, gc.Cfg.NetDir)
 		if err != nil {
-			return nil, fmt.Errorf("Error extracting plugin configuration from configuration file for net-attach-def object (%s:%s): %v", network.Namespace, network.Name, err)
+			return nil, "", fmt.Errorf("Error extracting plugin configuration from configuration file for net-attach-def object (%s:%s): %v", network.Namespace, network.Name, err)
 		}
 	} else {
 		config, err = networkcrd.GetConfigFromSpec(network, gc.Cfg.CNI)
+		source = fmt.Sprintf("spec of net-attach-def %s/%s", network.Namespace, network.Name)
 		if err != nil {
-			return nil, fmt.Errorf("Error extracting plugin configuration from object spec for net-attach-def object (%s:%s): %v", network.Namespace, network.Name, err)
+			return nil, "", fmt.Errorf("Error extracting plugin configuration from object spec for net-attach-def object (%s:%s): %v", network.Namespace, network.Name, err)
 		}
 	}
 
-	return config, nil
+	return config, source, nil
 }
 
-// getClusterNetwork gets the cluster wide default network based on the configuration
-// files present in cni directory. The first (in lexical order) valid config file
-// (excluding 00-genie.conf) will be treated as the configuration for cluster wide
-// default network. It will be assumed that the corresponding plugin executable is
-// is present in the plugin directory and the corresponding plugin service is running
-// (if required) in the cluster. If no conf/conflist file (other than 00-genie.conf)
-// is present in the directory, then network attachment will fail assuming non-readiness
-// of node.
-func (gc *GenieController) getClusterNetwork(cniDir string) (*libcni.NetworkConfigList, error) {
-	if len(gc.Cfg.Files) <= 1 {
-		return nil, fmt.Errorf("No cni plugin has been installed on node.")
+// getCapabilityArgs collects the runtime config requested in a network selection element
+func getCapabilityArgs(netElem *networkcrd.NetworkSelectionElement) map[string]interface{} {
+	capabilityArgs := make(map[string]interface{})
+	if len(netElem.IPs) > 0 {
+		capabilityArgs[utils.CapabilityIPs] = netElem.IPs
 	}
-	for _, file := range gc.Cfg.Files {
-		if strings.Contains(file, GenieConfFile) {
-			continue
-		}
-		config, err := gc.Cfg.ParseCNIConfFromFile(file)
-		if err != nil {
-			continue
-		}
-		return config, nil
+	if netElem.Mac != "" {
+		capabilityArgs[utils.CapabilityMac] = netElem.Mac
+	}
+	if len(netElem.PortMappings) > 0 {
+		capabilityArgs[utils.CapabilityPortMappings] = netElem.PortMappings
+	}
+	if netElem.BandwidthRequest != nil {
+		capabilityArgs[utils.CapabilityBandwidth] = netElem.BandwidthRequest
 	}
-	return nil, fmt.Errorf("Unable to select default cluster network. No valid configuration file present in cni directory.")
+	if netElem.InfinibandGUIDRequest != "" {
+		capabilityArgs[utils.CapabilityInfinibandGUID] = netElem.InfinibandGUIDRequest
+	}
+	if netElem.DeviceID != "" {
+		capabilityArgs[utils.CapabilityDeviceID] = netElem.DeviceID
+	}
+
+	return capabilityArgs
 }
@@ -2,15 +2,19 @@ package genie
 
 import (
 	"encoding/json"
-	"fmt"
 	"github.com/cni-genie/CNI-Genie/networkcrd"
 	"github.com/cni-genie/CNI-Genie/utils"
-	"github.com/containernetworking/cni/pkg/types/current"
-	"os"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
 	"strconv"
 )
 
-func setGenieStatus(result current.Result, name, ifName string, currStatus interface{}) interface{} {
+func setGenieStatus(result types100.Result, name, ifName string, isDefault bool, err error, currStatus interface{}) interface{} {
+	// Multi ip preferences have no provision for recording failures
+	if err != nil {
+		return currStatus
+	}
+
 	multiIPPreferences := &utils.MultiIPPreferences{}
 	var ok bool
 	if currStatus == nil {
@@ -19,14 +23,14 @@ func setGenieStatus(result current.Result, name, ifName string, currStatus inter
 	} else {
 		multiIPPreferences, ok = currStatus.(*utils.MultiIPPreferences)
 		if !ok {
-			fmt.Fprintf(os.Stderr, "CNI Genie unable to assert multiIPPreferences\n")
+			logging.Warningf("Unable to assert multiIPPreferences")
 			return nil
 		}
 		multiIPPreferences.MultiEntry = multiIPPreferences.MultiEntry + 1
 	}
 
 	if len(result.IPs) == 0 {
-		fmt.Fprintf(os.Stderr, "CNI Genie no ip in result\n")
+		logging.Warningf("No ip in result")
 		return nil
 	}
 	multiIPPreferences.Ips["ip"+strconv.Itoa(int(multiIPPreferences.MultiEntry))] = utils.IPAddressPreferences{
@@ -36,18 +40,16 @@ func setGenieStatus(result current.Result, name, ifName string, currStatus inter
 	return interface{}(multiIPPreferences)
 }
 
-func setNetAttachStatus(result current.Result, name, ifName string, currStatus interface{}) interface{} {
+func setNetAttachStatus(result types100.Result, name, ifName string, isDefault bool, err error, currStatus interface{}) interface{} {
 	netAttachStatus := &[]networkcrd.NetworkStatus{}
-	status := networkcrd.NetworkStatus{}
+	status := networkcrd.NetworkStatus{Default: isDefault}
 	var ok bool
 	if currStatus != nil {
 		netAttachStatus, ok = currStatus.(*[]networkcrd.NetworkStatus)
 		if !ok {
-			fmt.Fprintf(os.Stderr, "CNI Genie unable to assert network attachment status\n")
+			logging.Warningf("Unable to assert network attachment status")
 			return nil
 		}
-	} else {
-		status.Default = true
 	}
 
 	for _, intf := range result.Interfaces {
@@ -57,9 +59,7 @@ func setNetAttachStatus(result current.Result, name, ifName string, currStatus i
 	}
 
 	for _, ip := range result.IPs {
-		if ip.Version == "4" && ip.Address.IP.To4() != nil {
-			status.IPs = append(status.IPs, ip.Address.IP.String())
-		} else if ip.Version == "6" && ip.Address.IP.To16() != nil {
+		if ip.Address.IP.To16() != nil {
 			status.IPs = append(status.IPs, ip.Address.IP.String())
 		}
 	}
@@ -67,6 +67,10 @@ func setNetAttachStatus(result current.Result, name, ifName string, currStatus i
 	status.Name = name
 	status.Interface = ifName
 	status.DNS = result.DNS
+	if err != nil {
+		status.Default = false
+		status.Error = err.Error()
+	}
 
 	*netAttachStatus = append(*netAttachStatus, status)
 
@@ -79,15 +83,15 @@ func getStatusBytes(status interface{}) []byte {
 	if nwStatus, ok := status.(*utils.MultiIPPreferences); ok {
 		bytes, err = json.Marshal(*nwStatus)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "CNI Genie error while marshalling status: %v\n", err)
+			logging.Warningf("Error while marshalling status: %v", err)
 		}
 	} else if nwStatus, ok := status.(*[]networkcrd.NetworkStatus); ok {
 		bytes, err = json.MarshalIndent(nwStatus, "", " ")
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "CNI Genie error while marshalling network attachment status: %v\n", err)
+			logging.Warningf("Error while marshalling network attachment status: %v", err)
 		}
 	} else {
-		fmt.Fprintf(os.Stderr, "CNI Genie unable to extract status information\n")
+		logging.Warningf("Unable to extract status information")
 	}
 
 	return bytes
@@ -0,0 +1,169 @@
+package genie
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/networkcrd"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strings"
+)
+
+const (
+	// PodAnnotationsCapability is the runtime capability through which cri
+	// runtimes, eg: containerd, pass the annotations of the pod
+	PodAnnotationsCapability = "io.kubernetes.cri.pod-annotations"
+)
+
+// SelectionAnnotations lists the annotations through which networks are selected
+// for a pod. Only these are honored from container labels in standalone mode.
+var SelectionAnnotations = []string{
+	"cni",
+	"networks",
+	NetworkAttachmentDefinitionAnnot,
+	DefaultNetworkAnnot,
+	DefaultRouteAnnot,
+	MultiIPPreferencesAnnotation,
+}
+
+// SelectionFile holds the network selections of containers on a node, used in
+// standalone mode. Each selection is a set of annotations, as they would be
+// given on a pod. Selections of a pod override the default selection, and the
+// selections of a container override both.
+type SelectionFile struct {
+	// Default selection applying to all the containers
+	Default map[string]string `json:"default,omitempty"`
+	// Selections keyed by pod namespace/name, as passed in CNI_ARGS
+	Pods map[string]map[string]string `json:"pods,omitempty"`
+	// Selections keyed by container id
+	Containers map[string]map[string]string `json:"containers,omitempty"`
+}
+
+// getPodAnnotations gets the annotations selecting the networks for the pod,
+// from the pod object or, in standalone mode, from the local sources
+func (gc *GenieController) getPodAnnotations(cniArgs *utils.CNIArgs, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) (map[string]string, error) {
+	if gc.Standalone {
+		return gc.getStandaloneAnnotations(cniArgs, k8sArgs, conf)
+	}
+	return gc.getPodAnnotationsForCNI(k8sArgs)
+}
+
+// getStandaloneAnnotations merges the network selections from the selection
+// file, the container labels passed in CNI_ARGS, the labels in netconf args and
+// the pod annotations passed by the runtime as capability args, each
+// overriding the previous ones.
+func (gc *GenieController) getStandaloneAnnotations(cniArgs *utils.CNIArgs, k8sArgs *utils.K8sArgs, conf *utils.GenieConf) (map[string]string, error) {
+	annot := make(map[string]string)
+
+	if gc.SelectionFile != "" {
+		selections, err := loadSelectionFile(gc.SelectionFile)
+		if err != nil {
+			return nil, err
+		}
+		mergeAnnotations(annot, selections.Default)
+		if k8sArgs.K8S_POD_NAMESPACE != "" && k8sArgs.K8S_POD_NAME != "" {
+			mergeAnnotations(annot, selections.Pods[string(k8sArgs.K8S_POD_NAMESPACE)+"/"+string(k8sArgs.K8S_POD_NAME)])
+		}
+		mergeAnnotations(annot, selections.Containers[cniArgs.ContainerID])
+	}
+
+	mergeAnnotations(annot, labelsFromArgs(cniArgs.Args))
+	mergeAnnotations(annot, netConfLabels(conf))
+
+	podAnnot, err := runtimePodAnnotations(conf)
+	if err != nil {
+		return nil, err
+	}
+	mergeAnnotations(annot, podAnnot)
+
+	gc.logger().Debugf("Standalone network selection: %v", annot)
+	return annot, nil
+}
+
+func loadSelectionFile(file string) (*SelectionFile, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading selection file %s: %v", file, err)
+	}
+	selections := &SelectionFile{}
+	if err = json.Unmarshal(data, selections); err != nil {
+		return nil, fmt.Errorf("Error parsing selection file %s: %v", file, err)
+	}
+	return selections, nil
+}
+
+// labelsFromArgs picks the selection annotations from the container labels
+// passed as CNI_ARGS key value pairs
+func labelsFromArgs(args string) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(args, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 && isSelectionAnnotation(kv[0]) {
+			labels[kv[0]] = kv[1]
+		}
+	}
+	return labels
+}
+
+// stripSelectionLabels removes the selection annotations passed as container
+// labels from CNI_ARGS, so that they are not rejected as unknown args
+func stripSelectionLabels(args string) string {
+	var kept []string
+	for _, pair := range strings.Split(args, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) == 2 && isSelectionAnnotation(kv[0]) {
+			continue
+		}
+		kept = append(kept, pair)
+	}
+	return strings.Join(kept, ";")
+}
+
+func isSelectionAnnotation(name string) bool {
+	for _, annot := range SelectionAnnotations {
+		if name == annot {
+			return true
+		}
+	}
+	return false
+}
+
+// runtimePodAnnotations gets the pod annotations passed by the runtime
+func runtimePodAnnotations(conf *utils.GenieConf) (map[string]string, error) {
+	arg, ok := conf.RuntimeConfig[PodAnnotationsCapability]
+	if !ok {
+		return nil, nil
+	}
+	values, ok := arg.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Invalid runtime config %s: %v", PodAnnotationsCapability, arg)
+	}
+	annot := make(map[string]string, len(values))
+	for k, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("Invalid value of pod annotation %s in runtime config: %v", k, v)
+		}
+		annot[k] = s
+	}
+	return annot, nil
+}
+
+func mergeAnnotations(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// getNetworkObject gets the network attachment definition object for a network.
+// In standalone mode there is no api server, and the configuration with the
+// name of the network is looked up in net dir instead.
+func (gc *GenieController) getNetworkObject(name, namespace string) (*networkcrd.NetworkAttachmentDefinition, error) {
+	if gc.Standalone {
+		return &networkcrd.NetworkAttachmentDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		}, nil
+	}
+	return networkcrd.GetNetworkCRDObject(gc.Kc, name, namespace)
+}
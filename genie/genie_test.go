@@ -0,0 +1,93 @@
+package genie_test
+
+import (
+	"fmt"
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/genie/genietest"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"strings"
+	"testing"
+)
+
+// The tests of this package attach a pod, default/testpod, through a
+// controller built on the fakes of genietest
+
+var genieConf = &utils.GenieConf{
+	NetConf: types.NetConf{
+		Name: "k8s-pod-network",
+		Type: "genie",
+	},
+}
+
+func newPod(annot map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "testpod",
+			Namespace:   "default",
+			Annotations: annot,
+		},
+	}
+}
+
+func newNetAttachDef(name, namespace, config string) *netattachv1.NetworkAttachmentDefinition {
+	return &netattachv1.NetworkAttachmentDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec:       netattachv1.NetworkAttachmentDefinitionSpec{Config: config},
+	}
+}
+
+// newConfDir has the networks weave, bridge and macvlan-net
+func newConfDir() *genietest.ConfDir {
+	dir := genietest.NewConfDir()
+	dir.AddNetwork("weave", "weave")
+	dir.AddNetwork("bridge", "bridge")
+	dir.AddNetwork("macvlan-net", "macvlan")
+	return dir
+}
+
+func cniArgs() *utils.CNIArgs {
+	return genie.PopulateCNIArgs(&skel.CmdArgs{
+		ContainerID: "container1",
+		Netns:       "/var/run/netns/container1",
+		Args:        "K8S_POD_NAME=testpod;K8S_POD_NAMESPACE=default",
+	})
+}
+
+// attach adds the networks of the pod with the given annotations, served
+// along with objs, through a controller on top of dir and invoker. setup, if
+// set, adjusts the controller and the copy of the genie configuration used.
+func attach(dir *genietest.ConfDir, invoker *genietest.Invoker, annot map[string]string, objs []runtime.Object, setup func(*genie.GenieController, *utils.GenieConf)) (*genie.GenieController, error) {
+	gc := genietest.NewController(dir, invoker, append([]runtime.Object{newPod(annot)}, objs...)...)
+	conf := *genieConf
+	if setup != nil {
+		setup(gc, &conf)
+	}
+	_, err := gc.AddPodNetwork(cniArgs(), &conf)
+	return gc, err
+}
+
+// checkError checks that the error contains the expected one
+func checkError(t *testing.T, test int, expected, err error) {
+	if (expected == nil) != (err == nil) || (err != nil && !strings.Contains(err.Error(), expected.Error())) {
+		t.Errorf("Test %d: expected error: %v; got error: %v", test, expected, err)
+	}
+}
+
+// checkAdded checks the networks added, in order, and their interfaces, if
+// any are expected
+func checkAdded(t *testing.T, test int, invoker *genietest.Invoker, expected, expectedIfNames []string) {
+	if fmt.Sprint(invoker.Networks(genietest.CommandAdd)) != fmt.Sprint(expected) {
+		t.Errorf("Test %d: expected added networks: %v; got: %v", test, expected, invoker.Networks(genietest.CommandAdd))
+	}
+	for n, add := range invoker.Calls(genietest.CommandAdd) {
+		if n < len(expectedIfNames) && add.RuntimeConf.IfName != expectedIfNames[n] {
+			t.Errorf("Test %d: expected interface %s for %s; got: %s", test, expectedIfNames[n], add.Network, add.RuntimeConf.IfName)
+		}
+	}
+}
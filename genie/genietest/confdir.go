@@ -0,0 +1,187 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genietest
+
+import (
+	"fmt"
+	it "github.com/cni-genie/CNI-Genie/interfaces"
+	"github.com/containernetworking/cni/libcni"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConfDir is an in-memory cni conf dir and bin dir. It implements it.RW and
+// it.CNI, so that genie finds in it the configuration files and the plugin
+// binaries instead of on disk. Files created by genie, eg: default
+// configuration files, are kept in it as well.
+type ConfDir struct {
+	Dir    string
+	BinDir string
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewConfDir creates an empty conf dir at the default paths of genie
+func NewConfDir() *ConfDir {
+	return &ConfDir{
+		Dir:    "/etc/cni/net.d",
+		BinDir: "/opt/cni/bin",
+		files:  map[string][]byte{},
+	}
+}
+
+// AddFile adds a file with the given name and content to the conf dir
+func (d *ConfDir) AddFile(name string, data []byte) {
+	d.put(filepath.Join(d.Dir, name), data)
+}
+
+// AddNetwork adds a configuration file for a network with a single plugin
+// of type pluginType, along with the binary of the plugin
+func (d *ConfDir) AddNetwork(name, pluginType string) {
+	d.AddFile("10-"+name+".conf", []byte(fmt.Sprintf(`{"cniVersion": "0.3.1", "name": %q, "type": %q}`, name, pluginType)))
+	d.AddBinary(pluginType)
+}
+
+// AddBinary adds a plugin binary to the bin dir
+func (d *ConfDir) AddBinary(name string) {
+	d.put(filepath.Join(d.BinDir, name), nil)
+}
+
+// File returns the content of the file with the given name in the conf dir
+func (d *ConfDir) File(name string) ([]byte, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.files[filepath.Join(d.Dir, name)]
+	return data, ok
+}
+
+// Config returns the cni configuration of genie backed by the conf dir
+func (d *ConfDir) Config() *it.CNIConfig {
+	return &it.CNIConfig{
+		RW:     d,
+		CNI:    d,
+		NetDir: d.Dir,
+		BinDir: d.BinDir,
+	}
+}
+
+func (d *ConfDir) put(path string, data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.files == nil {
+		d.files = map[string][]byte{}
+	}
+	d.files[filepath.Clean(path)] = data
+}
+
+func (d *ConfDir) ReadFile(file string) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data, ok := d.files[filepath.Clean(file)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: file, Err: os.ErrNotExist}
+	}
+	return data, nil
+}
+
+func (d *ConfDir) ReadDir(dir string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	for _, path := range d.list(dir) {
+		d.mu.Lock()
+		infos = append(infos, fileInfo{name: filepath.Base(path), size: int64(len(d.files[path]))})
+		d.mu.Unlock()
+	}
+	return infos, nil
+}
+
+func (d *ConfDir) CreateFile(filePath string, bytes []byte, perm os.FileMode) error {
+	d.put(filePath, bytes)
+	return nil
+}
+
+// list returns the sorted paths of the files in dir
+func (d *ConfDir) list(dir string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dir = filepath.Clean(dir)
+	var paths []string
+	for path := range d.files {
+		if filepath.Dir(path) == dir {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (d *ConfDir) ConfListFromFile(file string) (*libcni.NetworkConfigList, error) {
+	data, err := d.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", file, err)
+	}
+	return libcni.ConfListFromBytes(data)
+}
+
+func (d *ConfDir) ConfListFromBytes(bytes []byte) (*libcni.NetworkConfigList, error) {
+	return libcni.ConfListFromBytes(bytes)
+}
+
+func (d *ConfDir) ConfFromFile(file string) (*libcni.NetworkConfig, error) {
+	data, err := d.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", file, err)
+	}
+	return libcni.ConfFromBytes(data)
+}
+
+func (d *ConfDir) ConfFromBytes(bytes []byte) (*libcni.NetworkConfig, error) {
+	return libcni.ConfFromBytes(bytes)
+}
+
+func (d *ConfDir) ConfListFromConf(conf *libcni.NetworkConfig) (*libcni.NetworkConfigList, error) {
+	return libcni.ConfListFromConf(conf)
+}
+
+func (d *ConfDir) ConfListFromConfBytes(confBytes []byte) (*libcni.NetworkConfigList, error) {
+	return (&it.Cni{}).ConfListFromConfBytes(confBytes)
+}
+
+func (d *ConfDir) ConfFiles(dir string, ext []string) ([]string, error) {
+	var files []string
+	for _, path := range d.list(dir) {
+		for _, e := range ext {
+			if filepath.Ext(path) == e {
+				files = append(files, path)
+				break
+			}
+		}
+	}
+	return files, nil
+}
+
+// fileInfo is the os.FileInfo of a file of ConfDir
+type fileInfo struct {
+	name string
+	size int64
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() os.FileMode  { return 0644 }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,57 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package genietest provides fakes to unit test the attachment logic of genie,
+or extensions of it, without a cluster or a node: an Invoker recording every
+invocation of the delegates and failing on demand, an in-memory ConfDir, and
+NewController building a GenieController on top of them and of client-go fake
+clientsets.
+*/
+package genietest
+
+import (
+	"github.com/cni-genie/CNI-Genie/client"
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
+	netattachfake "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/client/clientset/versioned/fake"
+	"github.com/cni-genie/CNI-Genie/genie"
+	it "github.com/cni-genie/CNI-Genie/interfaces"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// NewController creates a controller invoking the delegates through invoker
+// and finding the configuration files and plugin binaries in dir. objs are
+// served by fake clientsets; network attachment definitions among them are
+// served by the one of network attachment definitions, and the rest by the
+// kubernetes one. Default routes are recorded by an it.FakeRoutes and no
+// attachment records are kept, unless StateDir is set on the controller.
+func NewController(dir *ConfDir, invoker *Invoker, objs ...runtime.Object) *genie.GenieController {
+	// The tracker of the fake clientset would guess the resource of net-attach-defs
+	// without hyphens, so they are created through the typed client instead
+	netClient := netattachfake.NewSimpleClientset()
+	var kubeObjs []runtime.Object
+	for _, o := range objs {
+		if nad, ok := o.(*netattachv1.NetworkAttachmentDefinition); ok {
+			netClient.K8sCniCncfIoV1().NetworkAttachmentDefinitions(nad.Namespace).Create(nad)
+		} else {
+			kubeObjs = append(kubeObjs, o)
+		}
+	}
+
+	return &genie.GenieController{
+		Invoke: invoker,
+		Cfg:    dir.Config(),
+		Kc:     &client.KubeClient{Interface: fake.NewSimpleClientset(kubeObjs...), NetClient: netClient},
+		Routes: &it.FakeRoutes{},
+	}
+}
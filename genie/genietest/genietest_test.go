@@ -0,0 +1,131 @@
+package genietest
+
+import (
+	"errors"
+	"fmt"
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
+	"github.com/cni-genie/CNI-Genie/genie"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/cni/pkg/types"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"strings"
+	"testing"
+)
+
+var genieConf = &utils.GenieConf{
+	NetConf: types.NetConf{
+		Name: "k8s-pod-network",
+		Type: "genie",
+	},
+}
+
+func newPod(annot map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "testpod",
+			Namespace:   "default",
+			Annotations: annot,
+		},
+	}
+}
+
+func newConfDir() *ConfDir {
+	dir := NewConfDir()
+	dir.AddNetwork("weave", "weave")
+	dir.AddNetwork("bridge", "bridge")
+	dir.AddNetwork("macvlan-net", "macvlan")
+	return dir
+}
+
+func cniArgs() *utils.CNIArgs {
+	return genie.PopulateCNIArgs(&skel.CmdArgs{
+		ContainerID: "container1",
+		Netns:       "/var/run/netns/container1",
+		Args:        "K8S_POD_NAME=testpod;K8S_POD_NAMESPACE=default",
+	})
+}
+
+func TestAddDeletePodNetwork(t *testing.T) {
+	tests := []struct {
+		annot           map[string]string
+		netAttachDefs   []runtime.Object
+		failures        []Failure
+		expectedErr     error
+		expectedAdded   []string
+		expectedIfNames []string
+		expectedDeleted []string
+	}{
+		{
+			annot:           map[string]string{"cni": "weave, bridge"},
+			expectedAdded:   []string{"weave", "bridge"},
+			expectedIfNames: []string{"eth0", "eth1"},
+			expectedDeleted: []string{"bridge", "weave"},
+		},
+		{
+			annot:           map[string]string{"cni": "weave, bridge?"},
+			failures:        []Failure{{Command: CommandAdd, Network: "bridge", Times: 1, Err: errors.New("no free ip")}},
+			expectedAdded:   []string{"weave", "bridge"},
+			expectedIfNames: []string{"eth0", "eth1"},
+			// The optional network is deleted as soon as it fails
+			expectedDeleted: []string{"bridge", "bridge", "weave"},
+		},
+		{
+			annot:           map[string]string{"cni": "weave, bridge"},
+			failures:        []Failure{{Command: CommandAdd, Network: "bridge", Err: errors.New("no free ip")}},
+			expectedErr:     errors.New("Error from cni: no free ip"),
+			expectedAdded:   []string{"weave", "bridge"},
+			expectedIfNames: []string{"eth0", "eth1"},
+			expectedDeleted: []string{"bridge", "weave"},
+		},
+		{
+			// The configuration of a net-attach-def without spec is taken
+			// from the conf dir
+			annot: map[string]string{genie.NetworkAttachmentDefinitionAnnot: "macvlan-net"},
+			netAttachDefs: []runtime.Object{&netattachv1.NetworkAttachmentDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: "macvlan-net", Namespace: "default"},
+			}},
+			expectedAdded:   []string{"bridge", "macvlan-net"},
+			expectedIfNames: []string{"eth0", "eth1"},
+			expectedDeleted: []string{"macvlan-net", "bridge"},
+		},
+	}
+
+	for i := range tests {
+		invoker := NewInvoker()
+		for _, f := range tests[i].failures {
+			invoker.Fail(f)
+		}
+		objs := append([]runtime.Object{newPod(tests[i].annot)}, tests[i].netAttachDefs...)
+		gc := NewController(newConfDir(), invoker, objs...)
+
+		_, err := gc.AddPodNetwork(cniArgs(), genieConf)
+		if (tests[i].expectedErr == nil) != (err == nil) || (err != nil && !strings.Contains(err.Error(), tests[i].expectedErr.Error())) {
+			t.Errorf("Test %d: expected error: %v; got error: %v", i, tests[i].expectedErr, err)
+		}
+
+		adds := invoker.Calls(CommandAdd)
+		if fmt.Sprint(invoker.Networks(CommandAdd)) != fmt.Sprint(tests[i].expectedAdded) {
+			t.Errorf("Test %d: expected added networks: %v; got: %v", i, tests[i].expectedAdded, invoker.Networks(CommandAdd))
+		}
+		for n := range adds {
+			if n < len(tests[i].expectedIfNames) && adds[n].RuntimeConf.IfName != tests[i].expectedIfNames[n] {
+				t.Errorf("Test %d: expected interface %s for %s; got: %s", i, tests[i].expectedIfNames[n], adds[n].Network, adds[n].RuntimeConf.IfName)
+			}
+			if name, err := adds[n].Arg("K8S_POD_NAME"); err != nil || name != "testpod" {
+				t.Errorf("Test %d: expected pod name in args of %s; got: %q, %v", i, adds[n].Network, name, err)
+			}
+		}
+
+		if err == nil {
+			if err = gc.DeletePodNetwork(cniArgs(), genieConf); err != nil {
+				t.Errorf("Test %d: error deleting pod network: %v", i, err)
+			}
+		}
+		if fmt.Sprint(invoker.Networks(CommandDel)) != fmt.Sprint(tests[i].expectedDeleted) {
+			t.Errorf("Test %d: expected deleted networks: %v; got: %v", i, tests[i].expectedDeleted, invoker.Networks(CommandDel))
+		}
+	}
+}
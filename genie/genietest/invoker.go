@@ -0,0 +1,319 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genietest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"net"
+	"sync"
+)
+
+const (
+	CommandAdd    = "ADD"
+	CommandDel    = "DEL"
+	CommandCheck  = "CHECK"
+	CommandGC     = "GC"
+	CommandStatus = "STATUS"
+)
+
+// Call is an invocation of a delegate recorded by Invoker
+type Call struct {
+	Command string
+	// Network is the name of the network configuration invoked
+	Network string
+	Config  *libcni.NetworkConfigList
+	// RuntimeConf is a copy of the runtime configuration passed by genie. It
+	// is nil for GC and STATUS.
+	RuntimeConf *libcni.RuntimeConf
+	// ValidAttachments are the attachments passed on GC
+	ValidAttachments []utils.GCAttachment
+	// PrevResult is the result cached for the attachment, passed on CHECK
+	// and DEL: the one returned on ADD, unless genie has replaced it
+	PrevResult types.Result
+	// Err is the error returned to genie
+	Err error
+}
+
+// Arg returns the value of the cni arg passed in the runtime conf of the call
+func (c *Call) Arg(name string) (string, error) {
+	if c.RuntimeConf == nil {
+		return "", fmt.Errorf("No runtime conf passed on %s", c.Command)
+	}
+	for _, arg := range c.RuntimeConf.Args {
+		if arg[0] == name {
+			return arg[1], nil
+		}
+	}
+	return "", fmt.Errorf("Arg %s not passed to network %s", name, c.Network)
+}
+
+// Failure scripts an error to be returned by Invoker
+type Failure struct {
+	// Command is the command failing. Empty matches any command.
+	Command string
+	// Network is the name of the network configuration or the type of its
+	// first plugin failing. Empty matches any network.
+	Network string
+	// Times is the number of invocations failing, after which the failure is
+	// dropped. Zero fails all the invocations.
+	Times int
+	Err   error
+}
+
+func (f *Failure) matches(command string, config *libcni.NetworkConfigList) bool {
+	if f.Command != "" && f.Command != command {
+		return false
+	}
+	return f.Network == "" || f.Network == config.Name ||
+		(len(config.Plugins) > 0 && f.Network == config.Plugins[0].Network.Type)
+}
+
+// Invoker is a fake it.InvokeExec recording every invocation of the
+// delegates. On ADD, it returns the result set for the network in Results,
+// or else a result with one address from a subnet of 10.0.0.0/8 assigned to
+// the network on its first ADD. Like libcni, it caches the result of ADD and
+// passes it on CHECK and DEL of the attachment.
+type Invoker struct {
+	// Results are the results returned on ADD, keyed by the name of the
+	// network configuration or the type of its first plugin
+	Results map[string]types.Result
+
+	mu       sync.Mutex
+	calls    []Call
+	failures []*Failure
+	subnets  map[string]*addrs
+	// cached are the results cached for the attachments, keyed by network
+	// name and interface name
+	cached map[string][]byte
+}
+
+type addrs struct {
+	subnet byte
+	next   byte
+}
+
+// NewInvoker creates an invoker with no results or failures set
+func NewInvoker() *Invoker {
+	return &Invoker{Results: map[string]types.Result{}}
+}
+
+// Fail scripts a failure. Failures are matched in the order they are added.
+func (i *Invoker) Fail(f Failure) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.failures = append(i.failures, &f)
+}
+
+// Calls returns the invocations of the command, or all the invocations if
+// command is empty, in the order of invocation
+func (i *Invoker) Calls(command string) []Call {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var calls []Call
+	for _, c := range i.calls {
+		if command == "" || c.Command == command {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Networks returns the names of the networks on which the command was
+// invoked, in the order of invocation
+func (i *Invoker) Networks(command string) []string {
+	var networks []string
+	for _, c := range i.Calls(command) {
+		networks = append(networks, c.Network)
+	}
+	return networks
+}
+
+// Reset drops the recorded invocations and the scripted failures. Results,
+// the subnets assigned to the networks and the cached results are kept.
+func (i *Invoker) Reset() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.calls = nil
+	i.failures = nil
+}
+
+func (i *Invoker) InvokeExecAdd(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) (types.Result, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	err := i.record(CommandAdd, config, rtConf, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, ok := i.Results[config.Name]
+	if !ok && len(config.Plugins) > 0 {
+		res, ok = i.Results[config.Plugins[0].Network.Type]
+	}
+	if !ok {
+		res = i.buildResult(config, rtConf)
+	}
+	if err = i.setCachedResult(config, rtConf, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (i *Invoker) InvokeExecDel(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	err := i.record(CommandDel, config, rtConf, nil)
+	delete(i.cached, cacheKey(config, rtConf))
+	return err
+}
+
+func (i *Invoker) InvokeExecCheck(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.record(CommandCheck, config, rtConf, nil)
+}
+
+func (i *Invoker) InvokeExecGC(config *libcni.NetworkConfigList, validAttachments []utils.GCAttachment) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.record(CommandGC, config, nil, validAttachments)
+}
+
+func (i *Invoker) InvokeExecStatus(config *libcni.NetworkConfigList) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.record(CommandStatus, config, nil, nil)
+}
+
+func (i *Invoker) SetCachedResult(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf, result types.Result) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.setCachedResult(config, rtConf, result)
+}
+
+// setCachedResult caches the result serialized, as libcni does, so that later
+// changes to it are not seen on CHECK and DEL. It must be called with the lock
+// held.
+func (i *Invoker) setCachedResult(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf, result types.Result) error {
+	res, err := result.GetAsVersion(config.CNIVersion)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	if i.cached == nil {
+		i.cached = map[string][]byte{}
+	}
+	i.cached[cacheKey(config, rtConf)] = data
+	return nil
+}
+
+// cachedResult must be called with the lock held
+func (i *Invoker) cachedResult(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) types.Result {
+	data, ok := i.cached[cacheKey(config, rtConf)]
+	if !ok {
+		return nil
+	}
+	res, err := types100.ParseResult(config.CNIVersion, data)
+	if err != nil {
+		return nil
+	}
+	return res
+}
+
+func cacheKey(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) string {
+	return config.Name + "/" + rtConf.IfName
+}
+
+// record records the invocation and returns the scripted failure for it, if
+// any. It must be called with the lock held.
+func (i *Invoker) record(command string, config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf, validAttachments []utils.GCAttachment) error {
+	var err error
+	for n, f := range i.failures {
+		if !f.matches(command, config) {
+			continue
+		}
+		err = f.Err
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				i.failures = append(i.failures[:n], i.failures[n+1:]...)
+			}
+		}
+		break
+	}
+
+	var prevResult types.Result
+	if command == CommandCheck || command == CommandDel {
+		prevResult = i.cachedResult(config, rtConf)
+	}
+	i.calls = append(i.calls, Call{
+		Command:          command,
+		Network:          config.Name,
+		Config:           config,
+		RuntimeConf:      copyRuntimeConf(rtConf),
+		ValidAttachments: append([]utils.GCAttachment(nil), validAttachments...),
+		PrevResult:       prevResult,
+		Err:              err,
+	})
+	return err
+}
+
+// buildResult builds the default result of ADD. It must be called with the
+// lock held.
+func (i *Invoker) buildResult(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) types.Result {
+	if i.subnets == nil {
+		i.subnets = map[string]*addrs{}
+	}
+	a, ok := i.subnets[config.Name]
+	if !ok {
+		a = &addrs{subnet: byte(len(i.subnets) + 1), next: 2}
+		i.subnets[config.Name] = a
+	}
+	ip := net.IPv4(10, a.subnet, 0, a.next)
+	a.next++
+
+	return &current.Result{
+		CNIVersion: current.ImplementedSpecVersion,
+		Interfaces: []*current.Interface{{Name: rtConf.IfName, Sandbox: rtConf.NetNS}},
+		IPs: []*current.IPConfig{{
+			Version:   "4",
+			Interface: current.Int(0),
+			Address:   net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)},
+			Gateway:   net.IPv4(10, a.subnet, 0, 1),
+		}},
+	}
+}
+
+// copyRuntimeConf copies the runtime conf, so that the recorded one is not
+// changed by genie afterwards
+func copyRuntimeConf(rtConf *libcni.RuntimeConf) *libcni.RuntimeConf {
+	if rtConf == nil {
+		return nil
+	}
+	c := *rtConf
+	c.Args = append([][2]string(nil), rtConf.Args...)
+	if rtConf.CapabilityArgs != nil {
+		c.CapabilityArgs = make(map[string]interface{}, len(rtConf.CapabilityArgs))
+		for k, v := range rtConf.CapabilityArgs {
+			c.CapabilityArgs[k] = v
+		}
+	}
+	return &c
+}
@@ -0,0 +1,123 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package genietest
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+	"k8s.io/kubelet/pkg/apis/podresources/v1alpha1"
+	"net"
+	"path/filepath"
+	"sync"
+)
+
+// PodResourcesServer is a fake kubelet PodResources API served on a unix
+// socket, to be queried through podresources.Client
+type PodResourcesServer struct {
+	Socket string
+	// V1alpha1Only makes the server answer only the v1alpha1 API, like older
+	// kubelets
+	V1alpha1Only bool
+
+	mu     sync.Mutex
+	pods   []*podresourcesapi.PodResources
+	server *grpc.Server
+}
+
+// NewPodResourcesServer starts serving the API on kubelet.sock in dir
+func NewPodResourcesServer(dir string) (*PodResourcesServer, error) {
+	socket := filepath.Join(dir, "kubelet.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	s := &PodResourcesServer{Socket: socket, server: grpc.NewServer()}
+	podresourcesapi.RegisterPodResourcesListerServer(s.server, &podResourcesV1{PodResourcesServer: s})
+	v1alpha1.RegisterPodResourcesListerServer(s.server, &podResourcesV1alpha1{s})
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// AddDevices allocates devices of the resource to a container of a pod
+func (s *PodResourcesServer) AddDevices(namespace, pod, container, resourceName string, ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var p *podresourcesapi.PodResources
+	for _, existing := range s.pods {
+		if existing.Namespace == namespace && existing.Name == pod {
+			p = existing
+		}
+	}
+	if p == nil {
+		p = &podresourcesapi.PodResources{Namespace: namespace, Name: pod}
+		s.pods = append(s.pods, p)
+	}
+	var c *podresourcesapi.ContainerResources
+	for _, existing := range p.Containers {
+		if existing.Name == container {
+			c = existing
+		}
+	}
+	if c == nil {
+		c = &podresourcesapi.ContainerResources{Name: container}
+		p.Containers = append(p.Containers, c)
+	}
+	c.Devices = append(c.Devices, &podresourcesapi.ContainerDevices{ResourceName: resourceName, DeviceIds: ids})
+}
+
+// Close stops serving the API
+func (s *PodResourcesServer) Close() error {
+	s.server.Stop()
+	return nil
+}
+
+// podResourcesV1 serves the v1 API, unless the server is v1alpha1 only
+type podResourcesV1 struct {
+	*PodResourcesServer
+	podresourcesapi.UnimplementedPodResourcesListerServer
+}
+
+func (s *podResourcesV1) List(ctx context.Context, req *podresourcesapi.ListPodResourcesRequest) (*podresourcesapi.ListPodResourcesResponse, error) {
+	if s.V1alpha1Only {
+		return nil, status.Errorf(codes.Unimplemented, "unknown service v1.PodResourcesLister")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &podresourcesapi.ListPodResourcesResponse{PodResources: s.pods}, nil
+}
+
+// podResourcesV1alpha1 serves the v1alpha1 API
+type podResourcesV1alpha1 struct {
+	*PodResourcesServer
+}
+
+func (s *podResourcesV1alpha1) List(ctx context.Context, req *v1alpha1.ListPodResourcesRequest) (*v1alpha1.ListPodResourcesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	resp := &v1alpha1.ListPodResourcesResponse{}
+	for _, p := range s.pods {
+		pod := &v1alpha1.PodResources{Name: p.Name, Namespace: p.Namespace}
+		for _, c := range p.Containers {
+			container := &v1alpha1.ContainerResources{Name: c.Name}
+			for _, d := range c.Devices {
+				container.Devices = append(container.Devices, &v1alpha1.ContainerDevices{ResourceName: d.ResourceName, DeviceIds: d.DeviceIds})
+			}
+			pod.Containers = append(pod.Containers, container)
+		}
+		resp.PodResources = append(resp.PodResources, pod)
+	}
+	return resp, nil
+}
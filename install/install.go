@@ -0,0 +1,365 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package install implements genie-install, which installs genie on a node from
+the genie DaemonSet. It renders the genie configuration for the default
+network of the cluster found in the cni conf dir of the node, writes the
+kubeconfig used by the plugin with the service account token of the DaemonSet,
+and keeps both up to date as the conf dir changes and the token is rotated.
+*/
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"github.com/containernetworking/cni/libcni"
+	"gopkg.in/fsnotify/fsnotify.v1"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultConfName is the name of genie configuration file, sorted before
+	// the ones of the delegates so that it is picked by the runtime
+	DefaultConfName = "00-genie.conf"
+	// DefaultKubeconfigName is the name of the kubeconfig of the plugin
+	DefaultKubeconfigName = "genie-kubeconfig"
+	// DefaultTokenFile is the token of the service account, projected and
+	// rotated by kubelet
+	DefaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	// DefaultCAFile is the ca certificate of the api server
+	DefaultCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+
+	// KubeconfigPermission restricts the kubeconfig, which holds the token,
+	// to root
+	KubeconfigPermission os.FileMode = 0600
+	// ConfPermission is the permission of genie configuration file
+	ConfPermission os.FileMode = 0644
+)
+
+// defaultConf is the genie configuration rendered when no template is given
+const defaultConf = `{
+    "name": "k8s-pod-network",
+    "type": "genie",
+    "log_level": "info"
+}`
+
+// Options are the options of the installation
+type Options struct {
+	// HostNetDir is the cni conf dir of the node, as mounted in the installer
+	HostNetDir string
+	// NetDir is the cni conf dir as seen on the node. Paths written into genie
+	// configuration are relative to it.
+	NetDir         string
+	ConfName       string
+	KubeconfigName string
+	// Template is the genie configuration to render. The placeholders of the
+	// configurations written for launch.sh, eg: __KUBERNETES_NODE_NAME__, are
+	// substituted. A default configuration is used if it is empty.
+	Template []byte
+	// TokenFile and CAFile are the credentials written into the kubeconfig
+	TokenFile string
+	CAFile    string
+	// ServiceHost and ServicePort are the address of the api server
+	ServiceHost string
+	ServicePort string
+	// RomanaServiceHost and RomanaServicePort are the address of the romana
+	// root service, substituted for __ROMANA_SERVICE_HOST__ and
+	// __ROMANA_SERVICE_PORT__
+	RomanaServiceHost string
+	RomanaServicePort string
+	NodeName          string
+	// Binary is the genie plugin copied into HostBinDir, the cni bin dir of
+	// the node as mounted in the installer. It is not copied if either is empty.
+	Binary     string
+	HostBinDir string
+}
+
+// Installer installs genie on the node as per its options
+type Installer struct {
+	Options
+}
+
+// NewInstaller creates an installer, with the defaults set for the options
+// which are empty
+func NewInstaller(opts Options) *Installer {
+	if opts.NetDir == "" {
+		opts.NetDir = "/etc/cni/net.d"
+	}
+	if opts.HostNetDir == "" {
+		opts.HostNetDir = opts.NetDir
+	}
+	if opts.ConfName == "" {
+		opts.ConfName = DefaultConfName
+	}
+	if opts.KubeconfigName == "" {
+		opts.KubeconfigName = DefaultKubeconfigName
+	}
+	if opts.TokenFile == "" {
+		opts.TokenFile = DefaultTokenFile
+	}
+	if opts.CAFile == "" {
+		opts.CAFile = DefaultCAFile
+	}
+	return &Installer{Options: opts}
+}
+
+// InstallBinary copies the genie plugin into the cni bin dir of the node
+func (in *Installer) InstallBinary() error {
+	if in.Binary == "" || in.HostBinDir == "" {
+		return nil
+	}
+	src, err := os.Open(in.Binary)
+	if err != nil {
+		return fmt.Errorf("Error opening genie binary: %v", err)
+	}
+	defer src.Close()
+
+	dst := filepath.Join(in.HostBinDir, filepath.Base(in.Binary))
+	tmp, err := ioutil.TempFile(in.HostBinDir, "."+filepath.Base(in.Binary))
+	if err != nil {
+		return fmt.Errorf("Error creating genie binary in %s: %v", in.HostBinDir, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0755)
+	}
+	if err == nil {
+		// Renaming replaces the binary without disturbing running invocations
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		return fmt.Errorf("Error installing genie binary to %s: %v", dst, err)
+	}
+	logging.Infof("Installed genie binary to %s", dst)
+	return nil
+}
+
+// Sync writes the kubeconfig and genie configuration as per the current
+// credentials and the current default network. Files are replaced
+// atomically and only when their content changes. Genie configuration is
+// removed while there is no default network, so that the node is not ready
+// until there is a network to delegate to.
+func (in *Installer) Sync() error {
+	kubeconfig, err := in.renderKubeconfig()
+	if err != nil {
+		return err
+	}
+	changed, err := writeFileAtomic(filepath.Join(in.HostNetDir, in.KubeconfigName), kubeconfig, KubeconfigPermission)
+	if err != nil {
+		return fmt.Errorf("Error writing kubeconfig: %v", err)
+	}
+	if changed {
+		logging.Infof("Wrote kubeconfig %s", filepath.Join(in.HostNetDir, in.KubeconfigName))
+	}
+
+	confFile := filepath.Join(in.HostNetDir, in.ConfName)
+	delegate, file, err := DefaultNetwork(in.HostNetDir, in.ConfName)
+	if err != nil {
+		return err
+	}
+	if delegate == nil {
+		if err = os.Remove(confFile); err == nil {
+			logging.Warningf("Removed %s as there is no default network in %s", confFile, in.HostNetDir)
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("Error removing genie configuration: %v", err)
+		}
+		return nil
+	}
+
+	conf, err := in.renderConf(delegate)
+	if err != nil {
+		return err
+	}
+	changed, err = writeFileAtomic(confFile, conf, ConfPermission)
+	if err != nil {
+		return fmt.Errorf("Error writing genie configuration: %v", err)
+	}
+	if changed {
+		logging.Infof("Wrote genie configuration %s for default network %s (%s): %s", confFile, delegate.Name, file, logging.Redact(string(conf)))
+	}
+	return nil
+}
+
+// Run syncs the installation, then keeps syncing it on the changes in the
+// conf dir and of the credentials, and every resync period, until stopCh is
+// closed
+func (in *Installer) Run(resync time.Duration, stopCh <-chan struct{}) error {
+	if err := in.Sync(); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Error creating watcher: %v", err)
+	}
+	defer watcher.Close()
+	// Projected volumes are updated by swapping a symlink in the directory,
+	// so the directories of the credentials are watched instead of the files
+	dirs := map[string]bool{in.HostNetDir: true, filepath.Dir(in.TokenFile): true, filepath.Dir(in.CAFile): true}
+	for dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			return fmt.Errorf("Error watching %s: %v", dir, err)
+		}
+	}
+
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return nil
+		case event := <-watcher.Events:
+			logging.Debugf("Syncing on %s", event)
+		case err = <-watcher.Errors:
+			logging.Warningf("Error watching for changes: %v", err)
+			continue
+		case <-ticker.C:
+		}
+		if err = in.Sync(); err != nil {
+			logging.Errorf("Error syncing genie installation: %v", err)
+		}
+	}
+}
+
+// DefaultNetwork returns the configuration of the default network of the
+// cluster, ie: the first valid configuration in dir in the order the runtime
+// picks them, leaving out genie configuration. nil is returned if there is
+// none.
+func DefaultNetwork(dir, confName string) (*libcni.NetworkConfigList, string, error) {
+	files, err := libcni.ConfFiles(dir, []string{".conf", ".conflist", ".json"})
+	if err != nil {
+		return nil, "", fmt.Errorf("Error listing configuration files in %s: %v", dir, err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if filepath.Base(file) == confName {
+			continue
+		}
+		var confList *libcni.NetworkConfigList
+		if strings.HasSuffix(file, ".conflist") {
+			confList, err = libcni.ConfListFromFile(file)
+		} else {
+			var conf *libcni.NetworkConfig
+			conf, err = libcni.ConfFromFile(file)
+			if err == nil {
+				confList, err = libcni.ConfListFromConf(conf)
+			}
+		}
+		if err != nil {
+			logging.Warningf("Skipping configuration file %s: %v", file, err)
+			continue
+		}
+		if len(confList.Plugins) == 0 || confList.Plugins[0].Network.Type == "genie" {
+			continue
+		}
+		return confList, file, nil
+	}
+	return nil, "", nil
+}
+
+// renderConf renders genie configuration delegating to the default network
+func (in *Installer) renderConf(delegate *libcni.NetworkConfigList) ([]byte, error) {
+	template := string(in.Template)
+	if strings.TrimSpace(template) == "" {
+		template = defaultConf
+	}
+	// The token is written into the kubeconfig, where it is kept up to date
+	template = strings.NewReplacer(
+		"__KUBERNETES_NODE_NAME__", in.NodeName,
+		"__KUBERNETES_SERVICE_HOST__", in.ServiceHost,
+		"__KUBERNETES_SERVICE_PORT__", in.ServicePort,
+		"__ROMANA_SERVICE_HOST__", in.RomanaServiceHost,
+		"__ROMANA_SERVICE_PORT__", in.RomanaServicePort,
+		"__SERVICEACCOUNT_TOKEN__", "",
+	).Replace(template)
+
+	conf := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(template), &conf); err != nil {
+		return nil, fmt.Errorf("Error parsing genie configuration template: %v", err)
+	}
+	if _, ok := conf["cniVersion"]; !ok && delegate.CNIVersion != "" {
+		conf["cniVersion"] = delegate.CNIVersion
+	}
+	// The runtime passes the runtime config only for the capabilities declared
+	// in genie configuration
+	if _, ok := conf["capabilities"]; !ok {
+		capabilities := map[string]bool{}
+		for _, plugin := range delegate.Plugins {
+			for capability, enabled := range plugin.Network.Capabilities {
+				if enabled {
+					capabilities[capability] = true
+				}
+			}
+		}
+		if len(capabilities) > 0 {
+			conf["capabilities"] = capabilities
+		}
+	}
+	kubernetes, _ := conf["kubernetes"].(map[string]interface{})
+	if kubernetes == nil {
+		kubernetes = map[string]interface{}{}
+		conf["kubernetes"] = kubernetes
+	}
+	kubernetes["kubeconfig"] = filepath.Join(in.NetDir, in.KubeconfigName)
+
+	out, err := json.MarshalIndent(conf, "", "    ")
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling genie configuration: %v", err)
+	}
+	return out, nil
+}
+
+// server returns the address of the api server
+func (in *Installer) server() string {
+	return "https://" + net.JoinHostPort(in.ServiceHost, in.ServicePort)
+}
+
+// writeFileAtomic replaces the file with data through a rename, so that
+// readers never see a partially written file. Nothing is written if the file
+// already has data. The temporary file is hidden and does not have the
+// extension of a configuration file, so it is never picked by the runtime.
+func writeFileAtomic(file string, data []byte, perm os.FileMode) (bool, error) {
+	if old, err := ioutil.ReadFile(file); err == nil && bytes.Equal(old, data) {
+		if info, err := os.Stat(file); err == nil && info.Mode().Perm() == perm {
+			return false, nil
+		}
+	}
+
+	tmp := filepath.Join(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return false, err
+	}
+	// The permission of an existing temporary file is not changed by WriteFile
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
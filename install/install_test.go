@@ -0,0 +1,179 @@
+package install
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newInstaller(t *testing.T, template string) (*Installer, func()) {
+	dir, err := ioutil.TempDir("", "genie-install")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	netDir := filepath.Join(dir, "net.d")
+	secrets := filepath.Join(dir, "secrets")
+	for _, d := range []string{netDir, secrets} {
+		if err = os.Mkdir(d, 0755); err != nil {
+			t.Fatalf("Error creating %s: %v", d, err)
+		}
+	}
+	writeFile(t, filepath.Join(secrets, "token"), "token1\n")
+	writeFile(t, filepath.Join(secrets, "ca.crt"), "ca")
+
+	in := NewInstaller(Options{
+		HostNetDir:  netDir,
+		Template:    []byte(template),
+		TokenFile:   filepath.Join(secrets, "token"),
+		CAFile:      filepath.Join(secrets, "ca.crt"),
+		ServiceHost: "10.96.0.1",
+		ServicePort: "443",
+		NodeName:    "node1",
+	})
+	return in, func() { os.RemoveAll(dir) }
+}
+
+func writeFile(t *testing.T, file, data string) {
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatalf("Error writing %s: %v", file, err)
+	}
+}
+
+func readConf(t *testing.T, in *Installer) map[string]interface{} {
+	data, err := ioutil.ReadFile(filepath.Join(in.HostNetDir, in.ConfName))
+	if err != nil {
+		t.Fatalf("Error reading genie configuration: %v", err)
+	}
+	conf := map[string]interface{}{}
+	if err = json.Unmarshal(data, &conf); err != nil {
+		t.Fatalf("Error parsing genie configuration %s: %v", data, err)
+	}
+	return conf
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		template             string
+		delegates            map[string]string
+		expectedConf         bool
+		expectedVersion      string
+		expectedCapabilities bool
+		expectedHostname     string
+		expectedRomanaRoot   string
+	}{
+		{
+			template:     "",
+			delegates:    nil,
+			expectedConf: false,
+		},
+		{
+			template: "",
+			delegates: map[string]string{
+				"10-flannel.conflist": `{"cniVersion": "0.3.1", "name": "cbr0", "plugins": [{"type": "flannel"}, {"type": "portmap", "capabilities": {"portMappings": true}}]}`,
+				"20-bridge.conf":      `{"cniVersion": "0.4.0", "name": "mybridgenet", "type": "bridge"}`,
+			},
+			expectedConf:         true,
+			expectedVersion:      "0.3.1",
+			expectedCapabilities: true,
+		},
+		{
+			template: `{"name": "k8s-pod-network", "type": "genie", "cniVersion": "0.2.0", "hostname": "__KUBERNETES_NODE_NAME__",
+				"kubernetes": {"k8s_api_root": "https://__KUBERNETES_SERVICE_HOST__:__KUBERNETES_SERVICE_PORT__"}}`,
+			delegates: map[string]string{
+				"05-broken.conf":    `{"name": "broken"`,
+				"20-bridge.conf":    `{"cniVersion": "0.4.0", "name": "mybridgenet", "type": "bridge"}`,
+				"00-old-genie.conf": `{"cniVersion": "0.3.0", "name": "k8s-pod-network", "type": "genie"}`,
+			},
+			expectedConf:     true,
+			expectedVersion:  "0.2.0",
+			expectedHostname: "node1",
+		},
+	}
+
+	for i := range tests {
+		in, cleanup := newInstaller(t, tests[i].template)
+		defer cleanup()
+		in.RomanaServiceHost, in.RomanaServicePort = "10.96.0.99", "9600"
+		for name, data := range tests[i].delegates {
+			writeFile(t, filepath.Join(in.HostNetDir, name), data)
+		}
+
+		if err := in.Sync(); err != nil {
+			t.Fatalf("Test %d: error syncing: %v", i, err)
+		}
+
+		kubeconfig, err := clientcmd.LoadFromFile(filepath.Join(in.HostNetDir, in.KubeconfigName))
+		if err != nil {
+			t.Fatalf("Test %d: error loading kubeconfig: %v", i, err)
+		}
+		if kubeconfig.AuthInfos[kubeconfigUser].Token != "token1" || kubeconfig.Clusters[kubeconfigCluster].Server != "https://10.96.0.1:443" {
+			t.Errorf("Test %d: unexpected kubeconfig: %+v, %+v", i, kubeconfig.AuthInfos[kubeconfigUser], kubeconfig.Clusters[kubeconfigCluster])
+		}
+
+		_, err = os.Stat(filepath.Join(in.HostNetDir, in.ConfName))
+		if tests[i].expectedConf != (err == nil) {
+			t.Fatalf("Test %d: expected genie configuration: %v; stat error: %v", i, tests[i].expectedConf, err)
+		}
+		if !tests[i].expectedConf {
+			continue
+		}
+		conf := readConf(t, in)
+		if conf["cniVersion"] != tests[i].expectedVersion {
+			t.Errorf("Test %d: expected cni version %s; got: %v", i, tests[i].expectedVersion, conf["cniVersion"])
+		}
+		if _, ok := conf["capabilities"]; ok != tests[i].expectedCapabilities {
+			t.Errorf("Test %d: expected capabilities: %v; got: %v", i, tests[i].expectedCapabilities, conf["capabilities"])
+		}
+		if tests[i].expectedHostname != "" && conf["hostname"] != tests[i].expectedHostname {
+			t.Errorf("Test %d: expected hostname %s; got: %v", i, tests[i].expectedHostname, conf["hostname"])
+		}
+		if tests[i].expectedRomanaRoot != "" && conf["romana_root"] != tests[i].expectedRomanaRoot {
+			t.Errorf("Test %d: expected romana root %s; got: %v", i, tests[i].expectedRomanaRoot, conf["romana_root"])
+		}
+		if kubernetes, _ := conf["kubernetes"].(map[string]interface{}); kubernetes["kubeconfig"] != "/etc/cni/net.d/genie-kubeconfig" {
+			t.Errorf("Test %d: expected kubeconfig of the node in genie configuration; got: %v", i, conf["kubernetes"])
+		}
+	}
+}
+
+func TestSyncChanges(t *testing.T) {
+	in, cleanup := newInstaller(t, "")
+	defer cleanup()
+	writeFile(t, filepath.Join(in.HostNetDir, "10-bridge.conf"), `{"cniVersion": "0.3.1", "name": "mybridgenet", "type": "bridge"}`)
+	if err := in.Sync(); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+
+	// The token is rotated and the default network is upgraded
+	writeFile(t, in.TokenFile, "token2")
+	writeFile(t, filepath.Join(in.HostNetDir, "10-bridge.conf"), `{"cniVersion": "0.4.0", "name": "mybridgenet", "type": "bridge"}`)
+	if err := in.Sync(); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	kubeconfig, err := clientcmd.LoadFromFile(filepath.Join(in.HostNetDir, in.KubeconfigName))
+	if err != nil || kubeconfig.AuthInfos[kubeconfigUser].Token != "token2" {
+		t.Errorf("Expected rotated token in kubeconfig; got: %v, %v", kubeconfig, err)
+	}
+	if info, err := os.Stat(filepath.Join(in.HostNetDir, in.KubeconfigName)); err != nil || info.Mode().Perm() != KubeconfigPermission {
+		t.Errorf("Expected kubeconfig with permission %v; got: %v, %v", KubeconfigPermission, info, err)
+	}
+	if conf := readConf(t, in); conf["cniVersion"] != "0.4.0" {
+		t.Errorf("Expected cni version of the upgraded default network; got: %v", conf["cniVersion"])
+	}
+
+	// The default network is removed
+	os.Remove(filepath.Join(in.HostNetDir, "10-bridge.conf"))
+	if err := in.Sync(); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(in.HostNetDir, in.ConfName)); !os.IsNotExist(err) {
+		t.Errorf("Expected genie configuration to be removed; stat error: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(in.HostNetDir, ".*"))
+	if len(files) != 0 {
+		t.Errorf("Expected no temporary files left; got: %v", files)
+	}
+}
@@ -0,0 +1,74 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package install
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+)
+
+const (
+	kubeconfigCluster = "local"
+	kubeconfigUser    = "genie"
+	kubeconfigContext = "genie-context"
+)
+
+// renderKubeconfig renders the kubeconfig of the plugin. The token and the
+// ca certificate are embedded, as the files of the installer are not
+// reachable from the node; the kubeconfig is rewritten when they change.
+func (in *Installer) renderKubeconfig() ([]byte, error) {
+	if in.ServiceHost == "" || in.ServicePort == "" {
+		return nil, fmt.Errorf("Error rendering kubeconfig: address of the api server is not set")
+	}
+	token, err := ioutil.ReadFile(in.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading service account token: %v", err)
+	}
+	ca, err := ioutil.ReadFile(in.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading ca certificate: %v", err)
+	}
+
+	// The kubeconfig is written in json, which is valid yaml as well
+	config := clientcmdv1.Config{
+		Kind:       "Config",
+		APIVersion: "v1",
+		Clusters: []clientcmdv1.NamedCluster{{
+			Name: kubeconfigCluster,
+			Cluster: clientcmdv1.Cluster{
+				Server:                   in.server(),
+				CertificateAuthorityData: ca,
+			},
+		}},
+		AuthInfos: []clientcmdv1.NamedAuthInfo{{
+			Name:     kubeconfigUser,
+			AuthInfo: clientcmdv1.AuthInfo{Token: string(bytes.TrimSpace(token))},
+		}},
+		Contexts: []clientcmdv1.NamedContext{{
+			Name: kubeconfigContext,
+			Context: clientcmdv1.Context{
+				Cluster:  kubeconfigCluster,
+				AuthInfo: kubeconfigUser,
+			},
+		}},
+		CurrentContext: kubeconfigContext,
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Error rendering kubeconfig: %v", err)
+	}
+	return out, nil
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/containernetworking/cni/libcni"
 	"k8s.io/apimachinery/pkg/util/json"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -54,8 +55,46 @@ func (c *CNIConfig) GetCNIConfList(file string) (*libcni.NetworkConfigList, erro
 	return c.ParseCNIConfFromFile(file)
 }
 
+// LoadNetConfList loads the configuration of the network with the given name
+// from net dir in the same way as libcni.LoadConfList, but through CNI so
+// that the files can be faked
 func (c *CNIConfig) LoadNetConfList(name string) (*libcni.NetworkConfigList, error) {
-	return libcni.LoadConfList(c.NetDir, name)
+	files, err := c.ConfFiles(c.NetDir, []string{".conflist"})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		confList, err := c.ConfListFromFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if confList.Name == name {
+			return confList, nil
+		}
+	}
+
+	confFiles, err := c.ConfFiles(c.NetDir, []string{".conf", ".json"})
+	if err != nil {
+		return nil, err
+	}
+	if len(confFiles) == 0 {
+		if len(files) != 0 {
+			return nil, libcni.NotFoundError{Dir: c.NetDir, Name: name}
+		}
+		return nil, libcni.NoConfigsFoundError{Dir: c.NetDir}
+	}
+	sort.Strings(confFiles)
+	for _, file := range confFiles {
+		conf, err := c.ConfFromFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if conf.Network.Name == name {
+			return c.ConfListFromConf(conf)
+		}
+	}
+	return nil, libcni.NotFoundError{Dir: c.NetDir, Name: name}
 }
 
 func (c *CNIConfig) LoadConfFiles() error {
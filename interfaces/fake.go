@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
 	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/cni/pkg/types/current"
@@ -12,23 +13,51 @@ import (
 	"strings"
 )
 
+// FakeIo and FakeCni serve the configuration files held in Config, keyed by
+// the plugin name in the file name. The fakes of a controller share the same
+// Config, which NewFakeConfig fills with the default configurations.
 type FakeIo struct {
-	Files []string
+	Files  []string
+	Config map[string]string
 }
 
 type FakeInvoke struct {
 	Result types.Result
 	Error  error
+	// AddError holds the errors to be returned on ADD, keyed by plugin type
+	AddError map[string]error
+	// Deleted records the plugin types on which DEL was invoked, in order
+	Deleted []string
+	// Checked records the plugin types on which CHECK was invoked, in order
+	Checked []string
+	// Collected records the valid attachments GC was invoked with, keyed by
+	// network name
+	Collected map[string][]utils.GCAttachment
+	// Cached records the results cached in place of the ones of ADD, keyed by
+	// interface name
+	Cached map[string]types.Result
+}
+
+// FakeRoutes records the route operations instead of doing them
+type FakeRoutes struct {
+	// Deleted records the interfaces through which default routes were deleted
+	Deleted []string
+	// Added records the gateways of the default routes added, keyed by interface
+	Added map[string][]net.IP
+	Error error
 }
 
 type FakeCni struct {
 	InstalledPlugins []string
 	Files            []string
-	config           map[string]string
+	Config           map[string]string
 }
 
-var config map[string]string = map[string]string{
-	"weave": `{
+// NewFakeConfig returns the configurations of weave, flannel, bridge and
+// macvlan, to be shared by the fakes of a controller
+func NewFakeConfig() map[string]string {
+	return map[string]string{
+		"weave": `{
     "cniVersion": "0.3.0",
     "name": "weave",
     "plugins": [
@@ -38,27 +67,28 @@ var config map[string]string = map[string]string{
         }
     ]
 }`,
-	"flannel": `{
+		"flannel": `{
   "name": "cbr0",
   "type": "flannel",
   "delegate": {
     "isDefaultGateway": true
   }
 }`,
-	"bridge": `{
+		"bridge": `{
   "name": "mybridgenet",
   "type": "bridge",
   "ipam": {
     "type": "host-local"
   }
 }`,
-	"macvlan": `{
+		"macvlan": `{
   "name": "macvlannet",
   "type": "macvlan",
   "ipam": {
     "type": "host-local"
   }
 }`,
+	}
 }
 
 var ip map[string]net.IPNet
@@ -67,7 +97,7 @@ func (fi *FakeIo) ReadFile(file string) ([]byte, error) {
 	if file == "" {
 		return nil, errors.New("Invalid file path")
 	}
-	if c, ok := config[parseFileName(file)]; ok {
+	if c, ok := fi.Config[parseFileName(file)]; ok {
 		return []byte(c), nil
 	} else {
 		return nil, errors.New("File not present")
@@ -85,7 +115,10 @@ func (fi *FakeIo) CreateFile(filePath string, bytes []byte, perm os.FileMode) er
 	if filePath == "" {
 		return errors.New("Invalid file path")
 	}
-	config[parseFileName(filePath)] = string(bytes)
+	if fi.Config == nil {
+		fi.Config = make(map[string]string)
+	}
+	fi.Config[parseFileName(filePath)] = string(bytes)
 	fi.Files = append(fi.Files, filePath)
 	return nil
 }
@@ -93,7 +126,7 @@ func (fi *FakeIo) CreateFile(filePath string, bytes []byte, perm os.FileMode) er
 func SetIp(cni []string) {
 	ip = map[string]net.IPNet{}
 	var cnt uint8 = 1
-	for p := range config {
+	for p := range NewFakeConfig() {
 		switch p {
 		case "flannel":
 			ip[p] = net.IPNet{IP: net.IPv4(byte(10), byte(244), byte(0), byte(1))}
@@ -132,20 +165,60 @@ func buildResult(cni string) types.Result {
 }
 
 func (i *FakeInvoke) InvokeExecAdd(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) (types.Result, error) {
+	if err := i.AddError[config.Plugins[0].Network.Type]; err != nil {
+		return nil, err
+	}
 	return buildResult(config.Plugins[0].Network.Type), nil
 }
 
 func (i *FakeInvoke) InvokeExecDel(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) error {
+	i.Deleted = append(i.Deleted, config.Plugins[0].Network.Type)
+	return i.Error
+}
+
+func (i *FakeInvoke) InvokeExecCheck(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) error {
+	// Configurations of the tests may have no plugins
+	if len(config.Plugins) > 0 {
+		i.Checked = append(i.Checked, config.Plugins[0].Network.Type)
+	}
+	return i.Error
+}
+
+func (i *FakeInvoke) InvokeExecGC(config *libcni.NetworkConfigList, validAttachments []utils.GCAttachment) error {
+	if i.Collected == nil {
+		i.Collected = make(map[string][]utils.GCAttachment)
+	}
+	i.Collected[config.Name] = validAttachments
+	return i.Error
+}
+
+func (i *FakeInvoke) InvokeExecStatus(config *libcni.NetworkConfigList) error {
 	return i.Error
 }
 
-var fakeConfig *CNIConfig = &CNIConfig{
-	CNI: &FakeCni{},
-	RW:  &FakeIo{},
+func (i *FakeInvoke) SetCachedResult(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf, result types.Result) error {
+	if i.Cached == nil {
+		i.Cached = make(map[string]types.Result)
+	}
+	i.Cached[rtConf.IfName] = result
+	return nil
+}
+
+func (r *FakeRoutes) DelDefaultRoutes(netns, ifName string) error {
+	r.Deleted = append(r.Deleted, ifName)
+	return r.Error
+}
+
+func (r *FakeRoutes) AddDefaultRoute(netns, ifName string, gw net.IP) error {
+	if r.Added == nil {
+		r.Added = make(map[string][]net.IP)
+	}
+	r.Added[ifName] = append(r.Added[ifName], gw)
+	return r.Error
 }
 
 func (c *FakeCni) ConfListFromFile(file string) (*libcni.NetworkConfigList, error) {
-	return fakeConfig.ParseCNIConfFromBytes([]byte(config[parseFileName(file)]))
+	return (&CNIConfig{CNI: c}).ParseCNIConfFromBytes([]byte(c.Config[parseFileName(file)]))
 }
 
 func (c *FakeCni) ConfListFromBytes(bytes []byte) (*libcni.NetworkConfigList, error) {
@@ -153,7 +226,7 @@ func (c *FakeCni) ConfListFromBytes(bytes []byte) (*libcni.NetworkConfigList, er
 }
 
 func (c *FakeCni) ConfFromFile(file string) (*libcni.NetworkConfig, error) {
-	return c.ConfFromBytes([]byte(config[parseFileName(file)]))
+	return c.ConfFromBytes([]byte(c.Config[parseFileName(file)]))
 }
 
 func (c *FakeCni) ConfFromBytes(bytes []byte) (*libcni.NetworkConfig, error) {
@@ -170,8 +243,11 @@ func (c *FakeCni) ConfListFromConfBytes(confBytes []byte) (*libcni.NetworkConfig
 
 func (c *FakeCni) ConfFiles(dir string, ext []string) ([]string, error) {
 	var extn string
-	files := make([]string, 0, len(config))
-	for plugin, conf := range config {
+	if c.Config == nil {
+		c.Config = make(map[string]string)
+	}
+	files := make([]string, 0, len(c.Config))
+	for plugin, conf := range c.Config {
 		obj := map[string]interface{}{}
 		_ = json.Unmarshal([]byte(conf), &obj)
 		if _, ok := obj["plugins"]; ok {
@@ -181,12 +257,11 @@ func (c *FakeCni) ConfFiles(dir string, ext []string) ([]string, error) {
 		}
 		files = append(files, DefaultNetDir+"10-"+plugin+extn)
 	}
-	c.config = config
 	defaultConf := `{"name": "%s", "type": "%s"}`
 	for _, plg := range c.InstalledPlugins {
-		if c.config[plg] == "" {
-			c.config[plg] = fmt.Sprintf(defaultConf, plg, plg)
-			fmt.Println("c.config[plg]:", c.config[plg])
+		if c.Config[plg] == "" {
+			c.Config[plg] = fmt.Sprintf(defaultConf, plg, plg)
+			fmt.Println("c.Config[plg]:", c.Config[plg])
 			files = append(files, DefaultNetDir+"10-"+plg+".conf")
 		}
 	}
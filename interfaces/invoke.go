@@ -2,6 +2,8 @@ package interfaces
 
 import (
 	"context"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
 	"github.com/containernetworking/cni/libcni"
 	"github.com/containernetworking/cni/pkg/types"
 )
@@ -9,6 +11,10 @@ import (
 type InvokeExec interface {
 	InvokeExecAdd(list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) (types.Result, error)
 	InvokeExecDel(list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error
+	InvokeExecCheck(list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error
+	InvokeExecGC(list *libcni.NetworkConfigList, validAttachments []utils.GCAttachment) error
+	InvokeExecStatus(list *libcni.NetworkConfigList) error
+	SetCachedResult(list *libcni.NetworkConfigList, rt *libcni.RuntimeConf, result types.Result) error
 }
 
 type Invoke struct {
@@ -16,13 +22,39 @@ type Invoke struct {
 }
 
 func (i *Invoke) InvokeExecAdd(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) (types.Result, error) {
-	cniConfig := libcni.CNIConfig{Path: i.Path}
+	if isVersion1(config.CNIVersion) {
+		return i.addNetworkList(context.TODO(), config, rtConf)
+	}
+	cniConfig := libcni.NewCNIConfig(i.Path, i.exec())
 	ctx := context.TODO()
 	return cniConfig.AddNetworkList(ctx, config, rtConf)
 }
 
 func (i *Invoke) InvokeExecDel(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) error {
-	cniConfig := libcni.CNIConfig{Path: i.Path}
+	if isVersion1(config.CNIVersion) {
+		return i.delNetworkList(context.TODO(), config, rtConf)
+	}
+	cniConfig := libcni.NewCNIConfig(i.Path, i.exec())
 	ctx := context.TODO()
 	return cniConfig.DelNetworkList(ctx, config, rtConf)
 }
+
+// SetCachedResult replaces the result cached on ADD, which CHECK and DEL pass
+// to the delegates as prevResult, with the result genie has fixed up
+func (i *Invoke) SetCachedResult(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf, result types.Result) error {
+	res, err := result.GetAsVersion(config.CNIVersion)
+	if err != nil {
+		return fmt.Errorf("Error converting result to version %s: %v", config.CNIVersion, err)
+	}
+	// libcni caches the results of the older versions at the same location
+	return setCachedResult(res, config.Name, rtConf)
+}
+
+func (i *Invoke) InvokeExecCheck(config *libcni.NetworkConfigList, rtConf *libcni.RuntimeConf) error {
+	if isVersion1(config.CNIVersion) {
+		return i.checkNetworkList(context.TODO(), config, rtConf)
+	}
+	cniConfig := libcni.NewCNIConfig(i.Path, i.exec())
+	ctx := context.TODO()
+	return cniConfig.CheckNetworkList(ctx, config, rtConf)
+}
@@ -0,0 +1,97 @@
+package interfaces
+
+import (
+	"encoding/json"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
+	"github.com/containernetworking/cni/libcni"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakePlugin reports a default route on ADD and saves its input on CHECK
+const fakePlugin = `#!/bin/sh
+in=$(cat)
+case "$CNI_COMMAND" in
+ADD)
+	v=$(echo "$in" | sed -n 's/.*"cniVersion": *"\([^"]*\)".*/\1/p')
+	echo '{"cniVersion": "'$v'", "ips": [{"version": "4", "address": "10.1.0.2/24"}], "routes": [{"dst": "0.0.0.0/0", "gw": "10.1.0.1"}, {"dst": "10.96.0.0/12"}]}'
+	;;
+CHECK)
+	echo "$in" > "$(dirname "$0")/check-$CNI_IFNAME"
+	;;
+esac
+`
+
+func TestSetCachedResult(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-invoke")
+	if err != nil {
+		t.Fatalf("Error creating temp directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "fake"), []byte(fakePlugin), 0755); err != nil {
+		t.Fatalf("Error writing plugin: %v", err)
+	}
+
+	// Versions below 1.0.0 are cached by libcni, the others by genie
+	for _, ver := range []string{"0.4.0", "1.0.0"} {
+		list, err := libcni.ConfListFromBytes([]byte(`{"cniVersion": "` + ver + `", "name": "testnet", "plugins": [{"type": "fake"}]}`))
+		if err != nil {
+			t.Fatalf("Error parsing config of version %s: %v", ver, err)
+		}
+		rt := &libcni.RuntimeConf{
+			ContainerID: "container1",
+			NetNS:       "/var/run/netns/container1",
+			IfName:      "eth0",
+			CacheDir:    filepath.Join(dir, "cache"),
+		}
+		i := &Invoke{Path: []string{dir}}
+
+		result, err := i.InvokeExecAdd(list, rt)
+		if err != nil {
+			t.Errorf("Version %s: error adding network: %v", ver, err)
+			continue
+		}
+		res, err := types100.NewResultFromResult(result)
+		if err != nil {
+			t.Errorf("Version %s: error converting result: %v", ver, err)
+			continue
+		}
+		res.Routes = res.Routes[1:]
+		if err = i.SetCachedResult(list, rt, res); err != nil {
+			t.Errorf("Version %s: error caching result: %v", ver, err)
+			continue
+		}
+
+		if err = i.InvokeExecCheck(list, rt); err != nil {
+			t.Errorf("Version %s: error checking network: %v", ver, err)
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, "check-eth0"))
+		if err != nil {
+			t.Errorf("Version %s: error reading input of CHECK: %v", ver, err)
+			continue
+		}
+		var conf struct {
+			PrevResult json.RawMessage `json:"prevResult"`
+		}
+		if err = json.Unmarshal(data, &conf); err != nil {
+			t.Errorf("Version %s: error parsing input of CHECK: %v", ver, err)
+			continue
+		}
+		prevResult, err := types100.ParseResult(ver, conf.PrevResult)
+		if err != nil {
+			t.Errorf("Version %s: error parsing prevResult %s: %v", ver, conf.PrevResult, err)
+			continue
+		}
+		prev, err := types100.NewResultFromResult(prevResult)
+		if err != nil {
+			t.Errorf("Version %s: error converting prevResult: %v", ver, err)
+			continue
+		}
+		if len(prev.Routes) != 1 || prev.Routes[0].Dst.String() != "10.96.0.0/12" || len(prev.IPs) != 1 {
+			t.Errorf("Version %s: expected the cached result as prevResult on CHECK; got: %v", ver, prev)
+		}
+	}
+}
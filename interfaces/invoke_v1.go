@@ -0,0 +1,268 @@
+package interfaces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/types100"
+	"github.com/containernetworking/cni/libcni"
+	"github.com/containernetworking/cni/pkg/invoke"
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/version"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// GCMinCNIVersion specifies the minimum cni version supporting GC and STATUS commands
+	GCMinCNIVersion = "1.1.0"
+	// ValidAttachmentsKey is the key under which the valid attachments are passed to plugins on GC
+	ValidAttachmentsKey = "cni.dev/valid-attachments"
+)
+
+// The cni library in use understands result versions up to 0.4.0 only, hence
+// plugin lists of version 1.x are executed by Genie itself
+
+func isVersion1(cniVersion string) bool {
+	major, _, _, err := version.ParseVersion(cniVersion)
+	return err == nil && major >= 1
+}
+
+func supportsGC(cniVersion string) bool {
+	gtet, err := version.GreaterThanOrEqualTo(cniVersion, GCMinCNIVersion)
+	return err == nil && gtet
+}
+
+func (i *Invoke) exec() invoke.Exec {
+	return &delegateExec{&invoke.DefaultExec{
+		RawExec:       &invoke.RawExec{Stderr: os.Stderr},
+		PluginDecoder: version.PluginDecoder{},
+	}}
+}
+
+// delegateExec runs the delegates with the cni variables of their own
+// invocation. The environment of a delegate lists its variables first and
+// then the environment of genie, which has the variables of the invocation
+// of genie itself, eg: CNI_IFNAME. Only the first value of every variable is
+// kept, so that the process environment needn't be changed for every
+// delegate, as it is shared by the concurrent invocations of genie daemon.
+type delegateExec struct {
+	*invoke.DefaultExec
+}
+
+func (e *delegateExec) ExecPlugin(ctx context.Context, pluginPath string, stdinData []byte, environ []string) ([]byte, error) {
+	return e.DefaultExec.ExecPlugin(ctx, pluginPath, stdinData, firstEnvValues(environ))
+}
+
+func firstEnvValues(environ []string) []string {
+	seen := make(map[string]bool, len(environ))
+	env := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		key := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			key = kv[:i]
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		env = append(env, kv)
+	}
+	return env
+}
+
+// execPlugin invokes a single plugin of the list with the given command and
+// returns the raw output of the plugin
+func (i *Invoke) execPlugin(ctx context.Context, command string, list *libcni.NetworkConfigList, net *libcni.NetworkConfig, inject map[string]interface{}, rt *libcni.RuntimeConf) ([]byte, error) {
+	exec := i.exec()
+	pluginPath, err := exec.FindInPath(net.Network.Type, i.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{
+		"name":       list.Name,
+		"cniVersion": list.CNIVersion,
+	}
+	for k, v := range inject {
+		values[k] = v
+	}
+
+	// Pass only those capability args which are supported by the plugin
+	rc := make(map[string]interface{})
+	for capability, supported := range net.Network.Capabilities {
+		if data, ok := rt.CapabilityArgs[capability]; supported && ok {
+			rc[capability] = data
+		}
+	}
+	if len(rc) > 0 {
+		values["runtimeConfig"] = rc
+	}
+
+	conf, err := libcni.InjectConf(net, values)
+	if err != nil {
+		return nil, err
+	}
+
+	args := &invoke.Args{
+		Command:     command,
+		ContainerID: rt.ContainerID,
+		NetNS:       rt.NetNS,
+		PluginArgs:  rt.Args,
+		IfName:      rt.IfName,
+		Path:        strings.Join(i.Path, string(os.PathListSeparator)),
+	}
+
+	return exec.ExecPlugin(ctx, pluginPath, conf.Bytes, args.AsEnv())
+}
+
+func (i *Invoke) addNetworkList(ctx context.Context, list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) (types.Result, error) {
+	var result types.Result
+	for _, net := range list.Plugins {
+		inject := map[string]interface{}{}
+		if result != nil {
+			inject["prevResult"] = result
+		}
+		out, err := i.execPlugin(ctx, "ADD", list, net, inject, rt)
+		if err != nil {
+			return nil, err
+		}
+		result, err = types100.ParseResult(list.CNIVersion, out)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing result of plugin %s: %v", net.Network.Type, err)
+		}
+	}
+
+	if err := setCachedResult(result, list.Name, rt); err != nil {
+		return nil, fmt.Errorf("failed to set network %q cached result: %v", list.Name, err)
+	}
+
+	return result, nil
+}
+
+func (i *Invoke) checkNetworkList(ctx context.Context, list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error {
+	if list.DisableCheck {
+		return nil
+	}
+
+	cachedResult, err := getCachedResult(list.Name, rt)
+	if err != nil {
+		return fmt.Errorf("failed to get network %q cached result: %v", list.Name, err)
+	}
+	// Unlike the vendored libcni, which runs CHECK with a nil prevResult, a
+	// missing cached result fails CHECK: the delegates would otherwise have
+	// nothing to check the attachment against
+	if cachedResult == nil {
+		return fmt.Errorf("failed to get network %q cached result: no cached result", list.Name)
+	}
+
+	for _, net := range list.Plugins {
+		if _, err := i.execPlugin(ctx, "CHECK", list, net, prevResult(cachedResult), rt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *Invoke) delNetworkList(ctx context.Context, list *libcni.NetworkConfigList, rt *libcni.RuntimeConf) error {
+	cachedResult, err := getCachedResult(list.Name, rt)
+	if err != nil {
+		return fmt.Errorf("failed to get network %q cached result: %v", list.Name, err)
+	}
+
+	for idx := len(list.Plugins) - 1; idx >= 0; idx-- {
+		if _, err := i.execPlugin(ctx, "DEL", list, list.Plugins[idx], prevResult(cachedResult), rt); err != nil {
+			return err
+		}
+	}
+	_ = os.Remove(resultCacheFilePath(list.Name, rt))
+
+	return nil
+}
+
+// InvokeExecGC asks every plugin of the list to clean up the resources not
+// belonging to any of the valid attachments. Only plugin lists of version
+// 1.1.0 onwards support GC.
+func (i *Invoke) InvokeExecGC(list *libcni.NetworkConfigList, validAttachments []utils.GCAttachment) error {
+	if !supportsGC(list.CNIVersion) {
+		return nil
+	}
+	if validAttachments == nil {
+		validAttachments = []utils.GCAttachment{}
+	}
+
+	inject := map[string]interface{}{ValidAttachmentsKey: validAttachments}
+	var errs []string
+	for _, net := range list.Plugins {
+		if _, err := i.execPlugin(context.TODO(), "GC", list, net, inject, &libcni.RuntimeConf{}); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", net.Network.Type, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("GC failed for network %s: %s", list.Name, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// InvokeExecStatus checks the readiness of every plugin of the list. Plugins
+// of versions older than 1.1.0 are considered ready if their binary exists.
+func (i *Invoke) InvokeExecStatus(list *libcni.NetworkConfigList) error {
+	for _, net := range list.Plugins {
+		if !supportsGC(list.CNIVersion) {
+			if _, err := i.exec().FindInPath(net.Network.Type, i.Path); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := i.execPlugin(context.TODO(), "STATUS", list, net, nil, &libcni.RuntimeConf{}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func prevResult(result []byte) map[string]interface{} {
+	if result == nil {
+		return nil
+	}
+	return map[string]interface{}{"prevResult": json.RawMessage(result)}
+}
+
+// The cached results are kept at the same location as that of the cni library
+func resultCacheFilePath(netName string, rt *libcni.RuntimeConf) string {
+	cacheDir := rt.CacheDir
+	if cacheDir == "" {
+		cacheDir = libcni.CacheDir
+	}
+	return filepath.Join(cacheDir, "results", fmt.Sprintf("%s-%s-%s", netName, rt.ContainerID, rt.IfName))
+}
+
+func setCachedResult(result types.Result, netName string, rt *libcni.RuntimeConf) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	fname := resultCacheFilePath(netName, rt)
+	if err := os.MkdirAll(filepath.Dir(fname), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, data, 0600)
+}
+
+func getCachedResult(netName string, rt *libcni.RuntimeConf) ([]byte, error) {
+	data, err := ioutil.ReadFile(resultCacheFilePath(netName, rt))
+	if err != nil {
+		// The cached result may not exist on-disk
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import "net"
+
+// Routes abstracts the operations done by genie on the routes inside the
+// network namespace of a container
+type Routes interface {
+	// DelDefaultRoutes deletes all the default routes through the given interface
+	DelDefaultRoutes(netns, ifName string) error
+	// AddDefaultRoute adds, or replaces an existing, default route via the given
+	// gateway through the given interface
+	AddDefaultRoute(netns, ifName string, gw net.IP) error
+}
+
+// NetlinkRoutes manages the routes inside a network namespace using netlink
+type NetlinkRoutes struct{}
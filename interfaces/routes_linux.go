@@ -0,0 +1,202 @@
+// +build linux
+
+package interfaces
+
+import (
+	"encoding/binary"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// DelDefaultRoutes deletes the default routes of the main table through the
+// given interface, for both the address families
+func (_ *NetlinkRoutes) DelDefaultRoutes(netns, ifName string) error {
+	return withNetNS(netns, func() error {
+		link, err := net.InterfaceByName(ifName)
+		if err != nil {
+			return fmt.Errorf("Error getting interface %s: %v", ifName, err)
+		}
+
+		rib, err := syscall.NetlinkRIB(unix.RTM_GETROUTE, unix.AF_UNSPEC)
+		if err != nil {
+			return fmt.Errorf("Error listing routes: %v", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(rib)
+		if err != nil {
+			return fmt.Errorf("Error parsing routes: %v", err)
+		}
+
+		for i := range msgs {
+			if msgs[i].Header.Type != unix.RTM_NEWROUTE || len(msgs[i].Data) < unix.SizeofRtMsg {
+				continue
+			}
+			rtmsg := msgs[i].Data[:unix.SizeofRtMsg]
+			// Only the default routes (zero destination length) of the main table
+			if rtmsg[1] != 0 || rtmsg[4] != unix.RT_TABLE_MAIN {
+				continue
+			}
+			attrs, err := syscall.ParseNetlinkRouteAttr(&msgs[i])
+			if err != nil {
+				return fmt.Errorf("Error parsing route attributes: %v", err)
+			}
+
+			// The delete request carries only the attributes identifying the route
+			req := append([]byte{}, rtmsg...)
+			oif := -1
+			for _, attr := range attrs {
+				switch attr.Attr.Type {
+				case unix.RTA_OIF:
+					oif = int(nativeEndian.Uint32(attr.Value))
+					req = appendRtAttr(req, attr.Attr.Type, attr.Value)
+				case unix.RTA_GATEWAY, unix.RTA_PRIORITY, unix.RTA_TABLE:
+					req = appendRtAttr(req, attr.Attr.Type, attr.Value)
+				}
+			}
+			if oif != link.Index {
+				continue
+			}
+			if err = netlinkRequest(unix.RTM_DELROUTE, 0, req); err != nil {
+				return fmt.Errorf("Error deleting default route through %s: %v", ifName, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// AddDefaultRoute adds a default route in the main table via the given gateway
+func (_ *NetlinkRoutes) AddDefaultRoute(netns, ifName string, gw net.IP) error {
+	return withNetNS(netns, func() error {
+		link, err := net.InterfaceByName(ifName)
+		if err != nil {
+			return fmt.Errorf("Error getting interface %s: %v", ifName, err)
+		}
+
+		family, gwBytes := unix.AF_INET6, gw.To16()
+		if gw4 := gw.To4(); gw4 != nil {
+			family, gwBytes = unix.AF_INET, gw4
+		}
+		// struct rtmsg: family, dst_len, src_len, tos, table, protocol, scope, type, flags
+		req := make([]byte, unix.SizeofRtMsg)
+		req[0] = byte(family)
+		req[4] = unix.RT_TABLE_MAIN
+		req[5] = unix.RTPROT_BOOT
+		req[6] = unix.RT_SCOPE_UNIVERSE
+		req[7] = unix.RTN_UNICAST
+		req = appendRtAttr(req, unix.RTA_GATEWAY, gwBytes)
+		oif := make([]byte, 4)
+		nativeEndian.PutUint32(oif, uint32(link.Index))
+		req = appendRtAttr(req, unix.RTA_OIF, oif)
+
+		if err = netlinkRequest(unix.RTM_NEWROUTE, unix.NLM_F_CREATE|unix.NLM_F_REPLACE, req); err != nil {
+			return fmt.Errorf("Error adding default route via %s through %s: %v", gw, ifName, err)
+		}
+		return nil
+	})
+}
+
+func appendRtAttr(b []byte, attrType uint16, value []byte) []byte {
+	l := unix.SizeofRtAttr + len(value)
+	attr := make([]byte, (l+unix.NLMSG_ALIGNTO-1) & ^(unix.NLMSG_ALIGNTO-1))
+	nativeEndian.PutUint16(attr[0:2], uint16(l))
+	nativeEndian.PutUint16(attr[2:4], attrType)
+	copy(attr[unix.SizeofRtAttr:], value)
+	return append(b, attr...)
+}
+
+// netlinkRequest sends a single route netlink request and waits for its acknowledgement
+func netlinkRequest(msgType, flags uint16, data []byte) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	sa := &unix.SockaddrNetlink{Family: unix.AF_NETLINK}
+	if err = unix.Bind(fd, sa); err != nil {
+		return err
+	}
+
+	msg := make([]byte, unix.NLMSG_HDRLEN+len(data))
+	nativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+	nativeEndian.PutUint16(msg[4:6], msgType)
+	nativeEndian.PutUint16(msg[6:8], flags|unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	nativeEndian.PutUint32(msg[8:12], 1)
+	copy(msg[unix.NLMSG_HDRLEN:], data)
+	if err = unix.Sendto(fd, msg, 0, sa); err != nil {
+		return err
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if m.Header.Type == unix.NLMSG_ERROR {
+				if errno := int32(nativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return syscall.Errno(-errno)
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// withNetNS runs fn inside the given network namespace. It is run on a
+// dedicated OS thread, which is discarded if it could not be switched back
+// to the original namespace.
+func withNetNS(netns string, fn func() error) error {
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		orig, err := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net", os.Getpid(), unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errCh <- fmt.Errorf("Error opening current network namespace: %v", err)
+			return
+		}
+		defer orig.Close()
+
+		target, err := os.Open(netns)
+		if err != nil {
+			runtime.UnlockOSThread()
+			errCh <- fmt.Errorf("Error opening network namespace %s: %v", netns, err)
+			return
+		}
+		defer target.Close()
+
+		if err = unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			errCh <- fmt.Errorf("Error switching to network namespace %s: %v", netns, err)
+			return
+		}
+
+		fnErr := fn()
+		if err = unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err == nil {
+			runtime.UnlockOSThread()
+		}
+		errCh <- fnErr
+	}()
+
+	return <-errCh
+}
@@ -0,0 +1,153 @@
+// +build linux
+
+package interfaces
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestNetNS creates a network namespace with the interfaces eth0 and net1,
+// each having an IPv4 and an IPv6 default route. The one through net1 has the
+// higher metric. Creating network namespaces needs root and iproute2.
+func newTestNetNS(t *testing.T) string {
+	if os.Geteuid() != 0 {
+		t.Skip("Network namespaces can be created only by root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("ip command not found")
+	}
+	name := fmt.Sprintf("genie-test-%d", os.Getpid())
+	if out, err := exec.Command("ip", "netns", "add", name).CombinedOutput(); err != nil {
+		t.Skipf("Error creating network namespace: %v: %s", err, out)
+	}
+
+	for n, ifName := range []string{"eth0", "net1"} {
+		peer := fmt.Sprintf("peer%d", n)
+		metric := fmt.Sprint(n * 100)
+		for _, args := range [][]string{
+			{"link", "add", ifName, "type", "veth", "peer", "name", peer},
+			{"link", "set", ifName, "up"},
+			{"link", "set", peer, "up"},
+			{"addr", "add", fmt.Sprintf("10.%d.0.2/24", n+1), "dev", ifName},
+			{"addr", "add", fmt.Sprintf("fd00:%d::2/64", n+1), "dev", ifName, "nodad"},
+			{"route", "add", "default", "via", fmt.Sprintf("10.%d.0.1", n+1), "dev", ifName, "metric", metric},
+			{"-6", "route", "add", "default", "via", fmt.Sprintf("fd00:%d::1", n+1), "dev", ifName, "metric", metric},
+		} {
+			ipNetNS(t, name, args...)
+		}
+	}
+
+	return name
+}
+
+func ipNetNS(t *testing.T, name string, args ...string) string {
+	out, err := exec.Command("ip", append([]string{"-n", name}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Error running ip %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+// defaultRoutes lists the default routes of the family, as gateway@interface
+func defaultRoutes(t *testing.T, name, family string) []string {
+	var routes []string
+	for _, line := range strings.Split(ipNetNS(t, name, family, "route", "show", "default"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[1] != "via" || fields[3] != "dev" {
+			continue
+		}
+		routes = append(routes, fields[2]+"@"+fields[4])
+	}
+	sort.Strings(routes)
+	return routes
+}
+
+func TestNetlinkRoutes(t *testing.T) {
+	name := newTestNetNS(t)
+	defer exec.Command("ip", "netns", "del", name).Run()
+	netns := "/var/run/netns/" + name
+	routes := &NetlinkRoutes{}
+
+	tests := []struct {
+		op              func() error
+		expectedErr     bool
+		expectedRoutes  []string
+		expectedRoutes6 []string
+	}{
+		{
+			op:              func() error { return routes.DelDefaultRoutes(netns, "eth0") },
+			expectedRoutes:  []string{"10.2.0.1@net1"},
+			expectedRoutes6: []string{"fd00:2::1@net1"},
+		},
+		{
+			// Nothing left to delete
+			op:              func() error { return routes.DelDefaultRoutes(netns, "eth0") },
+			expectedRoutes:  []string{"10.2.0.1@net1"},
+			expectedRoutes6: []string{"fd00:2::1@net1"},
+		},
+		{
+			op:              func() error { return routes.AddDefaultRoute(netns, "eth0", net.ParseIP("10.1.0.254")) },
+			expectedRoutes:  []string{"10.1.0.254@eth0", "10.2.0.1@net1"},
+			expectedRoutes6: []string{"fd00:2::1@net1"},
+		},
+		{
+			// The default route of the same metric is replaced
+			op:              func() error { return routes.AddDefaultRoute(netns, "eth0", net.ParseIP("10.1.0.1")) },
+			expectedRoutes:  []string{"10.1.0.1@eth0", "10.2.0.1@net1"},
+			expectedRoutes6: []string{"fd00:2::1@net1"},
+		},
+		{
+			op:              func() error { return routes.AddDefaultRoute(netns, "eth0", net.ParseIP("fd00:1::1")) },
+			expectedRoutes:  []string{"10.1.0.1@eth0", "10.2.0.1@net1"},
+			expectedRoutes6: []string{"fd00:1::1@eth0", "fd00:2::1@net1"},
+		},
+		{
+			op:              func() error { return routes.DelDefaultRoutes(netns, "net1") },
+			expectedRoutes:  []string{"10.1.0.1@eth0"},
+			expectedRoutes6: []string{"fd00:1::1@eth0"},
+		},
+		{
+			op:              func() error { return routes.AddDefaultRoute(netns, "eth2", net.ParseIP("10.1.0.1")) },
+			expectedErr:     true,
+			expectedRoutes:  []string{"10.1.0.1@eth0"},
+			expectedRoutes6: []string{"fd00:1::1@eth0"},
+		},
+		{
+			// The gateway is not reachable through the interface
+			op:              func() error { return routes.AddDefaultRoute(netns, "eth0", net.ParseIP("10.3.0.1")) },
+			expectedErr:     true,
+			expectedRoutes:  []string{"10.1.0.1@eth0"},
+			expectedRoutes6: []string{"fd00:1::1@eth0"},
+		},
+		{
+			op:              func() error { return routes.DelDefaultRoutes(netns+"-missing", "eth0") },
+			expectedErr:     true,
+			expectedRoutes:  []string{"10.1.0.1@eth0"},
+			expectedRoutes6: []string{"fd00:1::1@eth0"},
+		},
+	}
+
+	for i := range tests {
+		err := tests[i].op()
+		if tests[i].expectedErr != (err != nil) {
+			t.Errorf("Test %d: expected error: %v; got error: %v", i, tests[i].expectedErr, err)
+		}
+		if got := defaultRoutes(t, name, "-4"); fmt.Sprint(got) != fmt.Sprint(tests[i].expectedRoutes) {
+			t.Errorf("Test %d: expected IPv4 default routes: %v; got: %v", i, tests[i].expectedRoutes, got)
+		}
+		if got := defaultRoutes(t, name, "-6"); fmt.Sprint(got) != fmt.Sprint(tests[i].expectedRoutes6) {
+			t.Errorf("Test %d: expected IPv6 default routes: %v; got: %v", i, tests[i].expectedRoutes6, got)
+		}
+	}
+
+	// The namespace of the test itself is not switched
+	if _, err := net.InterfaceByName("peer0"); err == nil {
+		t.Errorf("Expected interfaces of the test namespace only in it")
+	}
+}
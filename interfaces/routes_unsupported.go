@@ -0,0 +1,16 @@
+// +build !linux
+
+package interfaces
+
+import (
+	"fmt"
+	"net"
+)
+
+func (_ *NetlinkRoutes) DelDefaultRoutes(netns, ifName string) error {
+	return fmt.Errorf("Route management is not supported on this platform")
+}
+
+func (_ *NetlinkRoutes) AddDefaultRoute(netns, ifName string, gw net.IP) error {
+	return fmt.Errorf("Route management is not supported on this platform")
+}
@@ -1,14 +1,15 @@
 package networkcrd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	client "github.com/cni-genie/CNI-Genie/client"
 	it "github.com/cni-genie/CNI-Genie/interfaces"
 	"github.com/cni-genie/CNI-Genie/utils"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
 	"github.com/containernetworking/cni/libcni"
 	"net"
-	"os"
 	"regexp"
 	"strings"
 )
@@ -50,10 +51,32 @@ func validateFields(network NetworkSelectionElement) error {
 		}
 	}
 
+	for _, portMap := range network.PortMappings {
+		if portMap == nil || portMap.ContainerPort <= 0 || portMap.HostPort <= 0 {
+			return fmt.Errorf("Invalid port mapping %+v", portMap)
+		}
+	}
+
+	if bw := network.BandwidthRequest; bw != nil {
+		if bw.IngressRate < 0 || bw.IngressBurst < 0 || bw.EgressRate < 0 || bw.EgressBurst < 0 {
+			return fmt.Errorf("Invalid bandwidth request %+v", *bw)
+		}
+	}
+
+	if network.InfinibandGUIDRequest != "" {
+		if guid, err := net.ParseMAC(network.InfinibandGUIDRequest); err != nil || len(guid) != 8 {
+			return fmt.Errorf("Invalid infiniband guid %s", network.InfinibandGUIDRequest)
+		}
+	}
+
 	return nil
 }
 
 func parseNetworkInfoFromAnnot(network *NetworkSelectionElement, annot string) {
+	if strings.HasSuffix(annot, utils.OptionalNetworkSuffix) {
+		network.Optional = true
+		annot = strings.TrimSpace(strings.TrimSuffix(annot, utils.OptionalNetworkSuffix))
+	}
 	ns := strings.Index(annot, "/")
 	if ns >= 0 {
 		network.Namespace = annot[:ns]
@@ -102,6 +125,32 @@ func GetNetworkInfo(annotation, podNs string) ([]NetworkSelectionElement, error)
 	return networks, nil
 }
 
+// CheckNamespaceIsolation checks that the networks selected by a pod of
+// namespace podNs are in its own namespace or in one of the global namespaces
+func CheckNamespaceIsolation(networks []NetworkSelectionElement, podNs string, globalNamespaces []string) error {
+	for _, network := range networks {
+		if !NamespaceAllowed(network.Namespace, podNs, globalNamespaces) {
+			return fmt.Errorf("Network attachment definition %s/%s is not allowed for pods of namespace %s: namespace isolation allows only the namespace of the pod and the global namespaces %v",
+				network.Namespace, network.Name, podNs, globalNamespaces)
+		}
+	}
+	return nil
+}
+
+// NamespaceAllowed tells whether a pod of namespace podNs may refer to a
+// network attachment definition of namespace with namespace isolation
+func NamespaceAllowed(namespace, podNs string, globalNamespaces []string) bool {
+	if namespace == podNs {
+		return true
+	}
+	for _, global := range globalNamespaces {
+		if namespace == global {
+			return true
+		}
+	}
+	return false
+}
+
 type optionalParameters struct {
 	Cni struct {
 		Ips []string `json:"ips,omitempty"`
@@ -109,8 +158,8 @@ type optionalParameters struct {
 	} `json:"cni"`
 }
 
-func GetConfigFromFile(networkCrd *NetworkAttachmentDefinition, cniDir string) (*libcni.NetworkConfigList, error) {
-	return libcni.LoadConfList(cniDir, networkCrd.Name)
+func GetConfigFromFile(networkCrd *NetworkAttachmentDefinition, cfg *it.CNIConfig) (*libcni.NetworkConfigList, error) {
+	return cfg.LoadNetConfList(networkCrd.Name)
 }
 
 func GetConfigFromSpec(networkCrd *NetworkAttachmentDefinition, cni it.CNI) (*libcni.NetworkConfigList, error) {
@@ -145,17 +194,64 @@ func GetConfigFromSpec(networkCrd *NetworkAttachmentDefinition, cni it.CNI) (*li
 	return netConfigList, nil
 }
 
-func GetNetworkCRDObject(kubeClient *client.KubeClient, name, namespace string) (*NetworkAttachmentDefinition, error) {
-	path := fmt.Sprintf("/apis/k8s.cni.cncf.io/v1/namespaces/%s/network-attachment-definitions/%s", namespace, name)
-	fmt.Fprintf(os.Stderr, "CNI Genie network attachment definition object (%s:%s) path: %s\n", namespace, name, path)
-	obj, err := kubeClient.GetRaw(path)
+// knownCapabilities are the capabilities whose runtime config is passed to
+// the delegates, either by genie or by the runtime through genie
+var knownCapabilities = map[string]bool{
+	utils.CapabilityIPs:            true,
+	utils.CapabilityMac:            true,
+	utils.CapabilityPortMappings:   true,
+	utils.CapabilityBandwidth:      true,
+	utils.CapabilityInfinibandGUID: true,
+	utils.CapabilityDeviceID:       true,
+	"ipRanges":                     true,
+	"dns":                          true,
+	"aliases":                      true,
+	"cgroupPath":                   true,
+}
+
+// ValidateNetworkAttachmentDefinition validates the plugin configuration in
+// the spec of a network attachment definition object, the way genie parses it
+// on the nodes. An object without configuration is valid, its configuration
+// being taken from the conf dir of the nodes.
+func ValidateNetworkAttachmentDefinition(networkCrd *NetworkAttachmentDefinition, cni it.CNI) error {
+	if strings.TrimSpace(networkCrd.Spec.Config) == "" {
+		return nil
+	}
+	config := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(networkCrd.Spec.Config), &config); err != nil {
+		return fmt.Errorf("Error parsing plugin configuration data: %v", err)
+	}
+	if name, ok := config["name"]; ok {
+		if s, _ := name.(string); strings.TrimSpace(s) != "" && s != networkCrd.Name {
+			return fmt.Errorf("Name %q of plugin configuration does not match the name of network attachment definition %s", s, networkCrd.Name)
+		}
+	}
+
+	netConfigList, err := GetConfigFromSpec(networkCrd, cni)
 	if err != nil {
-		return nil, fmt.Errorf("Error performing GET request: %v", err)
+		return err
 	}
-	networkcrd := &NetworkAttachmentDefinition{}
-	err = json.Unmarshal(obj, networkcrd)
+	if len(netConfigList.Plugins) == 0 {
+		return fmt.Errorf("Plugin configuration list has no plugins")
+	}
+	for i, plugin := range netConfigList.Plugins {
+		if plugin.Network.Type == "" {
+			return fmt.Errorf("Plugin %d of plugin configuration has no type", i)
+		}
+		for capability := range plugin.Network.Capabilities {
+			if !knownCapabilities[capability] {
+				return fmt.Errorf("Unknown capability %q in configuration of plugin %s", capability, plugin.Network.Type)
+			}
+		}
+	}
+	return nil
+}
+
+func GetNetworkCRDObject(kubeClient *client.KubeClient, name, namespace string) (*NetworkAttachmentDefinition, error) {
+	logging.Debugf("Getting network attachment definition object (%s:%s)", namespace, name)
+	networkcrd, err := kubeClient.GetNetworkAttachmentDefinition(context.TODO(), name, namespace)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Error getting network attachment definition: %v", err)
 	}
 
 	return networkcrd, nil
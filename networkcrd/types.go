@@ -1,34 +1,22 @@
 package networkcrd
 
 import (
+	netattachv1 "github.com/cni-genie/CNI-Genie/controllers/netattachdef-pkg/apis/k8s.cni.cncf.io/v1"
 	"github.com/containernetworking/cni/pkg/types"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
 )
 
-// NetworkAttachmentDefinition describes the network attachment objects
-type NetworkAttachmentDefinition struct {
-	metav1.TypeMeta `json:",inline"`
-	// Standard object metadata
-	metav1.ObjectMeta `json:"metadata,omitempty"`
+// ResourceNameAnnot specifies the device plugin resource (eg: intel.com/sriov)
+// of the network of a network attachment definition object. A device of the
+// resource allocated to the pod is passed to the delegate as deviceID.
+const ResourceNameAnnot = "k8s.v1.cni.cncf.io/resourceName"
 
-	// Spec specifies an option to choose how the configuration for the
-	// CNI plugin, specified by this NetworkAttachmentDefinition object,
-	// is to be fetched, i.e. whether from the value defined for the sub-field
-	// or to be loaded from the corresponding configuration file present
-	// in the network directory, in case the sub-field or this field itself
-	// is empty.
-	// +optional
-	Spec NetworkAttachmentDefinitionSpec `json:"spec"`
-}
+// NetworkAttachmentDefinition describes the network attachment objects. It is
+// the type served by the typed clientset of k8s.cni.cncf.io/v1.
+type NetworkAttachmentDefinition = netattachv1.NetworkAttachmentDefinition
 
 // NetworkAttachmentDefinitionSpec describes the configuration of network object
-type NetworkAttachmentDefinitionSpec struct {
-	// Config specifies the configuration in conf or conflist
-	// format for the CNI plugin, which will be invoked by this
-	// NetworkAttachmentDefinition object
-	// +optional
-	Config string `json:"config,omitempty"`
-}
+type NetworkAttachmentDefinitionSpec = netattachv1.NetworkAttachmentDefinitionSpec
 
 // NetworkSelectionElement describes the properties of a single object specified
 // in network attachment selection annotation
@@ -51,6 +39,46 @@ type NetworkSelectionElement struct {
 	// to the container by this network attachment
 	// +optional
 	Interface string `json:"interface,omitempty"`
+	// PortMappings specifies the port mappings requested for this network attachment
+	// +optional
+	PortMappings []*PortMapEntry `json:"portMappings,omitempty"`
+	// BandwidthRequest specifies the bandwidth limits requested for this network attachment
+	// +optional
+	BandwidthRequest *BandwidthEntry `json:"bandwidth,omitempty"`
+	// InfinibandGUIDRequest specifies the infiniband GUID requested for this network attachment
+	// +optional
+	InfinibandGUIDRequest string `json:"infiniband-guid,omitempty"`
+	// DeviceID specifies the device (eg: pci address of a VF) to be used for this network attachment
+	// +optional
+	DeviceID string `json:"deviceID,omitempty"`
+	// GatewayRequest specifies the gateways to be used for the default route of the pod.
+	// Specifying it makes this network attachment own the default route, and default
+	// routes of all other network attachments are removed
+	// +optional
+	GatewayRequest []net.IP `json:"default-route,omitempty"`
+	// Optional specifies that the pod does not depend on this network attachment.
+	// Failure in attaching it is recorded in the network status annotation
+	// instead of failing the pod
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// PortMapEntry describes a port mapping, as passed to plugins supporting the
+// "portMappings" capability
+type PortMapEntry struct {
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol,omitempty"`
+	HostIP        string `json:"hostIP,omitempty"`
+}
+
+// BandwidthEntry describes the bandwidth limits, as passed to plugins supporting
+// the "bandwidth" capability
+type BandwidthEntry struct {
+	IngressRate  int `json:"ingressRate"`
+	IngressBurst int `json:"ingressBurst"`
+	EgressRate   int `json:"egressRate"`
+	EgressBurst  int `json:"egressBurst"`
 }
 
 // NetworkStatus describes the status to be updated in pod
@@ -61,4 +89,5 @@ type NetworkStatus struct {
 	Mac       string    `json:"mac,omitempty"`
 	Default   bool      `json:"default,omitempty"`
 	DNS       types.DNS `json:"dns,omitempty"`
+	Error     string    `json:"error,omitempty"`
 }
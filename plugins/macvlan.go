@@ -15,7 +15,7 @@ package plugins
 
 import (
 	"fmt"
-	"os"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
 	"os/exec"
 	"strings"
 )
@@ -36,7 +36,7 @@ func GetMacvlanConfig() interface{} {
 	if nic, err := GetDefaultNic(); err == nil && nic != "" {
 		master = nic
 	} else {
-		fmt.Fprintf(os.Stderr, "CNI Genie Could not get default nic for the host; [error: %v]. Using %s as master instead.", err, DefaultMasterForMacvlan)
+		logging.Warningf("Could not get default nic for the host; [error: %v]. Using %s as master instead.", err, DefaultMasterForMacvlan)
 	}
 
 	macvlanObj := struct {
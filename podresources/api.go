@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podresources
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+	"k8s.io/kubelet/pkg/apis/podresources/v1alpha1"
+	"net"
+	"time"
+)
+
+// List lists the resources allocated to the pods of the node, falling back
+// to the v1alpha1 API if the kubelet does not serve v1
+func (c *Client) List() (*podresourcesapi.ListPodResourcesResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to PodResources API at %s: %v", c.Socket, err)
+	}
+	defer conn.Close()
+
+	resp, err := podresourcesapi.NewPodResourcesListerClient(conn).List(ctx, &podresourcesapi.ListPodResourcesRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return listV1alpha1(ctx, conn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error listing pod resources: %v", err)
+	}
+	return resp, nil
+}
+
+// dial connects to the unix socket of the PodResources API, failing without
+// waiting for the timeout if the socket is absent
+func (c *Client) dial(ctx context.Context) (*grpc.ClientConn, error) {
+	return grpc.DialContext(ctx, c.Socket, grpc.WithInsecure(), grpc.WithBlock(), grpc.FailOnNonTempDialError(true),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}))
+}
+
+// listV1alpha1 lists the pod resources through the v1alpha1 API, served by
+// older kubelets, and converts them to v1
+func listV1alpha1(ctx context.Context, conn *grpc.ClientConn) (*podresourcesapi.ListPodResourcesResponse, error) {
+	alphaResp, err := v1alpha1.NewPodResourcesListerClient(conn).List(ctx, &v1alpha1.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing pod resources: %v", err)
+	}
+	resp := &podresourcesapi.ListPodResourcesResponse{}
+	for _, alphaPod := range alphaResp.PodResources {
+		pod := &podresourcesapi.PodResources{Name: alphaPod.Name, Namespace: alphaPod.Namespace}
+		for _, alphaContainer := range alphaPod.Containers {
+			container := &podresourcesapi.ContainerResources{Name: alphaContainer.Name}
+			for _, alphaDevices := range alphaContainer.Devices {
+				container.Devices = append(container.Devices, &podresourcesapi.ContainerDevices{
+					ResourceName: alphaDevices.ResourceName,
+					DeviceIds:    alphaDevices.DeviceIds,
+				})
+			}
+			pod.Containers = append(pod.Containers, container)
+		}
+		resp.PodResources = append(resp.PodResources, pod)
+	}
+	return resp, nil
+}
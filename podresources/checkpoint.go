@@ -0,0 +1,90 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podresources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+)
+
+// checkpoint is the part of the kubelet device manager checkpoint describing
+// the devices allocated to the containers
+type checkpoint struct {
+	Data struct {
+		PodDeviceEntries []podDevicesEntry
+	}
+}
+
+type podDevicesEntry struct {
+	PodUID        string
+	ContainerName string
+	ResourceName  string
+	// DeviceIDs is a list of device ids, or a map of the device ids by numa
+	// node with kubelets aware of the topology
+	DeviceIDs json.RawMessage
+}
+
+// checkpointDeviceIDs gets the devices of the pod from the checkpoint file
+func (c *Client) checkpointDeviceIDs(pod Pod, resourceName string) ([]string, error) {
+	if pod.UID == "" {
+		return nil, fmt.Errorf("Uid of the pod is required to look up the checkpoint file")
+	}
+	data, err := ioutil.ReadFile(c.CheckpointFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading checkpoint file: %v", err)
+	}
+	cp := checkpoint{}
+	if err = json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("Error parsing checkpoint file %s: %v", c.CheckpointFile, err)
+	}
+
+	var ids []string
+	for _, entry := range cp.Data.PodDeviceEntries {
+		if entry.PodUID != pod.UID || entry.ResourceName != resourceName {
+			continue
+		}
+		entryIDs, err := parseDeviceIDs(entry.DeviceIDs)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing devices of %s in checkpoint file %s: %v", resourceName, c.CheckpointFile, err)
+		}
+		ids = appendUnique(ids, entryIDs...)
+	}
+	return ids, nil
+}
+
+func parseDeviceIDs(raw json.RawMessage) ([]string, error) {
+	var ids []string
+	if err := json.Unmarshal(raw, &ids); err == nil {
+		return ids, nil
+	}
+	byNode := map[string][]string{}
+	if err := json.Unmarshal(raw, &byNode); err != nil {
+		return nil, err
+	}
+	var nodes []string
+	for node := range byNode {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		ni, _ := strconv.Atoi(nodes[i])
+		nj, _ := strconv.Atoi(nodes[j])
+		return ni < nj
+	})
+	for _, node := range nodes {
+		ids = append(ids, byNode[node]...)
+	}
+	return ids, nil
+}
@@ -0,0 +1,119 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package podresources finds the devices allocated to the containers of a pod
+// by the device plugins, eg: the VFs of an SR-IOV network, as reported by the
+// kubelet through its PodResources API or, with kubelets not serving it,
+// through the checkpoint file of its device manager.
+package podresources
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils/logging"
+	"time"
+)
+
+const (
+	// DefaultSocket is the unix socket of the kubelet PodResources API
+	DefaultSocket = "/var/lib/kubelet/pod-resources/kubelet.sock"
+	// DefaultCheckpointFile is the checkpoint file of the kubelet device manager
+	DefaultCheckpointFile = "/var/lib/kubelet/device-plugins/kubelet_internal_checkpoint"
+	// DefaultTimeout is the timeout of a PodResources API request
+	DefaultTimeout = 10 * time.Second
+)
+
+// Pod identifies the pod whose devices are looked up. The PodResources API
+// identifies pods by namespace and name, the checkpoint file by uid.
+type Pod struct {
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// Lister lists the devices of a resource allocated to a pod
+type Lister interface {
+	// DeviceIDs returns the ids of the devices of the resource allocated to
+	// the containers of the pod, in the order of the containers
+	DeviceIDs(pod Pod, resourceName string) ([]string, error)
+}
+
+// Client is a Lister querying the PodResources API of the kubelet, and its
+// checkpoint file if the API cannot be reached
+type Client struct {
+	Socket         string
+	CheckpointFile string
+	Timeout        time.Duration
+}
+
+// NewClient creates a client querying the kubelet at socket, and reading the
+// checkpoint file if the API cannot be reached. The default socket and
+// checkpoint file are used if they are empty.
+func NewClient(socket, checkpointFile string) *Client {
+	if socket == "" {
+		socket = DefaultSocket
+	}
+	if checkpointFile == "" {
+		checkpointFile = DefaultCheckpointFile
+	}
+	return &Client{Socket: socket, CheckpointFile: checkpointFile, Timeout: DefaultTimeout}
+}
+
+func (c *Client) DeviceIDs(pod Pod, resourceName string) ([]string, error) {
+	ids, err := c.listDeviceIDs(pod, resourceName)
+	if err == nil {
+		return ids, nil
+	}
+	logging.Debugf("PodResources API at %s not available, falling back to checkpoint file %s: %v", c.Socket, c.CheckpointFile, err)
+	ids, cerr := c.checkpointDeviceIDs(pod, resourceName)
+	if cerr != nil {
+		return nil, fmt.Errorf("Error getting devices of %s allocated to pod %s/%s: %v; %v", resourceName, pod.Namespace, pod.Name, err, cerr)
+	}
+	return ids, nil
+}
+
+// listDeviceIDs gets the devices of the pod from the PodResources API
+func (c *Client) listDeviceIDs(pod Pod, resourceName string) ([]string, error) {
+	resp, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, p := range resp.PodResources {
+		if p.Namespace != pod.Namespace || p.Name != pod.Name {
+			continue
+		}
+		for _, container := range p.Containers {
+			for _, devices := range container.Devices {
+				if devices.ResourceName == resourceName {
+					ids = appendUnique(ids, devices.DeviceIds...)
+				}
+			}
+		}
+	}
+	return ids, nil
+}
+
+func appendUnique(ids []string, more ...string) []string {
+	for _, id := range more {
+		found := false
+		for _, existing := range ids {
+			if existing == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
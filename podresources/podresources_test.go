@@ -0,0 +1,94 @@
+package podresources_test
+
+import (
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/genie/genietest"
+	"github.com/cni-genie/CNI-Genie/podresources"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const checkpointData = `{"Data": {"PodDeviceEntries": [
+	{"PodUID": "uid1", "ContainerName": "app", "ResourceName": "intel.com/sriov", "DeviceIDs": ["0000:03:02.0"], "AllocResp": "Cg=="},
+	{"PodUID": "uid1", "ContainerName": "sidecar", "ResourceName": "intel.com/sriov", "DeviceIDs": {"1": ["0000:81:02.1"], "0": ["0000:03:02.3"]}},
+	{"PodUID": "uid2", "ContainerName": "app", "ResourceName": "intel.com/sriov", "DeviceIDs": ["0000:03:02.5"]}
+]}, "Checksum": 1}`
+
+func TestDeviceIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "podresources")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	checkpointFile := filepath.Join(dir, "kubelet_internal_checkpoint")
+	if err = ioutil.WriteFile(checkpointFile, []byte(checkpointData), 0644); err != nil {
+		t.Fatalf("Error writing checkpoint file: %v", err)
+	}
+
+	server, err := genietest.NewPodResourcesServer(dir)
+	if err != nil {
+		t.Fatalf("Error starting PodResources server: %v", err)
+	}
+	defer server.Close()
+	server.AddDevices("default", "testpod", "app", "intel.com/sriov", "0000:03:02.0", "0000:03:02.1")
+	server.AddDevices("default", "testpod", "app", "example.com/gpu", "gpu0")
+	server.AddDevices("default", "testpod", "sidecar", "intel.com/sriov", "0000:03:02.2")
+	server.AddDevices("default", "otherpod", "app", "intel.com/sriov", "0000:03:02.3")
+
+	pod := podresources.Pod{Namespace: "default", Name: "testpod", UID: "uid1"}
+	tests := []struct {
+		socket       string
+		v1alpha1Only bool
+		pod          podresources.Pod
+		resourceName string
+		expectedErr  bool
+		expectedIDs  []string
+	}{
+		{
+			socket:       server.Socket,
+			pod:          pod,
+			resourceName: "intel.com/sriov",
+			expectedIDs:  []string{"0000:03:02.0", "0000:03:02.1", "0000:03:02.2"},
+		},
+		{
+			socket:       server.Socket,
+			v1alpha1Only: true,
+			pod:          pod,
+			resourceName: "example.com/gpu",
+			expectedIDs:  []string{"gpu0"},
+		},
+		{
+			socket:       server.Socket,
+			pod:          pod,
+			resourceName: "intel.com/qat",
+			expectedIDs:  nil,
+		},
+		{
+			// The checkpoint file is read when the API cannot be reached
+			socket:       filepath.Join(dir, "absent.sock"),
+			pod:          pod,
+			resourceName: "intel.com/sriov",
+			expectedIDs:  []string{"0000:03:02.0", "0000:03:02.3", "0000:81:02.1"},
+		},
+		{
+			socket:       filepath.Join(dir, "absent.sock"),
+			pod:          podresources.Pod{Namespace: "default", Name: "testpod"},
+			resourceName: "intel.com/sriov",
+			expectedErr:  true,
+		},
+	}
+
+	for i := range tests {
+		server.V1alpha1Only = tests[i].v1alpha1Only
+		client := podresources.NewClient(tests[i].socket, checkpointFile)
+		ids, err := client.DeviceIDs(tests[i].pod, tests[i].resourceName)
+		if tests[i].expectedErr != (err != nil) {
+			t.Errorf("Test %d: expected error: %v; got error: %v", i, tests[i].expectedErr, err)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tests[i].expectedIDs) {
+			t.Errorf("Test %d: expected device ids: %v; got: %v", i, tests[i].expectedIDs, ids)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// +build linux
+
+// Package filelock serializes the processes of genie on a node, eg: the
+// invocations of the plugin, through locks on files.
+package filelock
+
+import (
+	"os"
+	"syscall"
+)
+
+// Lock takes an exclusive lock on the given file, creating it if needed, and
+// returns the function releasing it
+func Lock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
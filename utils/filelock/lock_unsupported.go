@@ -0,0 +1,11 @@
+// +build !linux
+
+package filelock
+
+import (
+	"fmt"
+)
+
+func Lock(path string) (func(), error) {
+	return nil, fmt.Errorf("File locking is not supported on this platform")
+}
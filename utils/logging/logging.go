@@ -0,0 +1,332 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logging implements the leveled logging of CNI Genie. Log lines are
+// written either as text or as JSON, and carry the fields identifying the
+// container, the pod and the attachment being worked upon.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level specifies the severity of a log line
+type Level int
+
+const (
+	ErrorLevel Level = iota
+	WarningLevel
+	InfoLevel
+	DebugLevel
+)
+
+const (
+	// FormatText writes log lines as text, with the fields as key=value pairs
+	FormatText = "text"
+	// FormatJSON writes each log line as a JSON object
+	FormatJSON = "json"
+	// LogFilePermission specifies the permission for the log file
+	LogFilePermission os.FileMode = 0600
+	// Prefix is the component name with which every text log line starts
+	Prefix = "CNI Genie"
+)
+
+var levelNames = map[Level]string{
+	ErrorLevel:   "error",
+	WarningLevel: "warning",
+	InfoLevel:    "info",
+	DebugLevel:   "debug",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel converts the log level from configuration. Info level is used
+// if the level is not specified.
+func ParseLevel(level string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "error":
+		return ErrorLevel, nil
+	case "warning", "warn":
+		return WarningLevel, nil
+	case "", "info":
+		return InfoLevel, nil
+	case "debug":
+		return DebugLevel, nil
+	}
+	return InfoLevel, fmt.Errorf("Invalid log level %q", level)
+}
+
+// Fields holds the structured context of a log line
+type Fields map[string]interface{}
+
+// Logger writes log lines at or above its level
+type Logger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	level  Level
+	json   bool
+	fields Fields
+}
+
+// Entry is a log line in the making, carrying fields in addition to those of the logger
+type Entry struct {
+	logger *Logger
+	fields Fields
+}
+
+var std = New(os.Stderr)
+
+// New creates a text logger writing to out at info level
+func New(out io.Writer) *Logger {
+	return &Logger{out: out, level: InfoLevel, fields: Fields{}}
+}
+
+// Configure sets up the standard logger as per the level, the log file and
+// the format from configuration. Logs are written to stderr if no log file
+// is specified.
+func Configure(level, file, format string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	var jsonFormat bool
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", FormatText:
+	case FormatJSON:
+		jsonFormat = true
+	default:
+		return fmt.Errorf("Invalid log format %q", format)
+	}
+
+	var out io.Writer = os.Stderr
+	if file != "" {
+		if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return fmt.Errorf("Error creating directory for log file %s: %v", file, err)
+		}
+		out, err = os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, LogFilePermission)
+		if err != nil {
+			return fmt.Errorf("Error opening log file %s: %v", file, err)
+		}
+	}
+
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	std.level = lvl
+	std.json = jsonFormat
+	std.out = out
+	return nil
+}
+
+// StandardLogger returns the logger used by the package level functions
+func StandardLogger() *Logger {
+	return std
+}
+
+// SetOutput sets the writer of the logger
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = out
+}
+
+// SetLevel sets the level of the logger
+func (l *Logger) SetLevel(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.level = level
+}
+
+// SetJSON switches the logger between JSON and text formats
+func (l *Logger) SetJSON(json bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.json = json
+}
+
+// AddFields adds fields to be carried by every log line of the logger,
+// eg: container id of the current invocation
+func (l *Logger) AddFields(fields Fields) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for k, v := range fields {
+		l.fields[k] = v
+	}
+}
+
+// SetFields replaces the fields carried by every log line of the logger
+func (l *Logger) SetFields(fields Fields) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fields = Fields{}
+	for k, v := range fields {
+		l.fields[k] = v
+	}
+}
+
+// Enabled reports whether lines of the given level are written
+func (l *Logger) Enabled(level Level) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return level <= l.level
+}
+
+// WithFields creates an entry carrying the given fields
+func (l *Logger) WithFields(fields Fields) *Entry {
+	return &Entry{logger: l, fields: fields}
+}
+
+// WithFields creates an entry carrying the given fields along with those of e
+func (e *Entry) WithFields(fields Fields) *Entry {
+	merged := make(Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{logger: e.logger, fields: merged}
+}
+
+func (e *Entry) Debugf(format string, args ...interface{}) {
+	e.logger.log(DebugLevel, e.fields, format, args...)
+}
+
+func (e *Entry) Infof(format string, args ...interface{}) {
+	e.logger.log(InfoLevel, e.fields, format, args...)
+}
+
+func (e *Entry) Warningf(format string, args ...interface{}) {
+	e.logger.log(WarningLevel, e.fields, format, args...)
+}
+
+func (e *Entry) Errorf(format string, args ...interface{}) {
+	e.logger.log(ErrorLevel, e.fields, format, args...)
+}
+
+func (l *Logger) log(level Level, fields Fields, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if level > l.level {
+		return
+	}
+
+	all := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		all[k] = v
+	}
+	for k, v := range fields {
+		all[k] = v
+	}
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	// The complete line is written at once, so that the lines of genie
+	// processes sharing the log file do not interleave
+	var line []byte
+	if l.json {
+		obj := make(map[string]interface{}, len(all)+3)
+		for k, v := range all {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			obj[k] = v
+		}
+		obj["time"] = now
+		obj["level"] = level.String()
+		obj["msg"] = msg
+		var err error
+		if line, err = json.Marshal(obj); err != nil {
+			line = []byte(fmt.Sprintf(`{"time":%q,"level":"error","msg":"Error marshalling log line: %v"}`, now, err))
+		}
+		line = append(line, '\n')
+	} else {
+		buf := &bytes.Buffer{}
+		fmt.Fprintf(buf, "%s %s %s: %s", now, level, Prefix, msg)
+		keys := make([]string, 0, len(all))
+		for k := range all {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value := fmt.Sprintf("%v", all[k])
+			if strings.ContainsAny(value, " \"=") {
+				value = fmt.Sprintf("%q", value)
+			}
+			fmt.Fprintf(buf, " %s=%s", k, value)
+		}
+		buf.WriteByte('\n')
+		line = buf.Bytes()
+	}
+
+	l.out.Write(line)
+}
+
+// SetFields replaces the fields carried by every log line of the standard logger
+func SetFields(fields Fields) {
+	std.SetFields(fields)
+}
+
+// AddFields adds fields to be carried by every log line of the standard logger
+func AddFields(fields Fields) {
+	std.AddFields(fields)
+}
+
+// Enabled reports whether lines of the given level are written by the standard logger
+func Enabled(level Level) bool {
+	return std.Enabled(level)
+}
+
+// WithFields creates an entry of the standard logger carrying the given fields
+func WithFields(fields Fields) *Entry {
+	return std.WithFields(fields)
+}
+
+func Debugf(format string, args ...interface{}) {
+	std.log(DebugLevel, nil, format, args...)
+}
+
+func Infof(format string, args ...interface{}) {
+	std.log(InfoLevel, nil, format, args...)
+}
+
+func Warningf(format string, args ...interface{}) {
+	std.log(WarningLevel, nil, format, args...)
+}
+
+func Errorf(format string, args ...interface{}) {
+	std.log(ErrorLevel, nil, format, args...)
+}
+
+// Values of the JSON keys matching this expression are considered credentials
+var sensitiveKeys = regexp.MustCompile(`(?i)("[^"]*(token|password|secret|key|cert-data|certificate-authority-data)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// Redact masks the credentials, eg: k8s_auth_token, in the given JSON
+// data, so that it can be logged
+func Redact(data string) string {
+	return sensitiveKeys.ReplaceAllString(data, `$1"--- REDACTED ---"`)
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		level    string
+		expected []string
+	}{
+		{level: "error", expected: []string{"error"}},
+		{level: "warning", expected: []string{"error", "warning"}},
+		{level: "", expected: []string{"error", "warning", "info"}},
+		{level: "debug", expected: []string{"error", "warning", "info", "debug"}},
+	}
+
+	for i := range tests {
+		buf := &bytes.Buffer{}
+		logger := New(buf)
+		level, err := ParseLevel(tests[i].level)
+		if err != nil {
+			t.Fatalf("Error parsing level %q: %v", tests[i].level, err)
+		}
+		logger.SetLevel(level)
+
+		entry := logger.WithFields(nil)
+		entry.Errorf("error")
+		entry.Warningf("warning")
+		entry.Infof("info")
+		entry.Debugf("debug")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != len(tests[i].expected) {
+			t.Errorf("Expected %d lines for level %q; got: %q", len(tests[i].expected), tests[i].level, lines)
+			continue
+		}
+		for j, line := range lines {
+			if !strings.HasSuffix(line, Prefix+": "+tests[i].expected[j]) {
+				t.Errorf("Expected line with message %q; got: %q", tests[i].expected[j], line)
+			}
+		}
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Errorf("Expected error for invalid level")
+	}
+}
+
+func TestFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := New(buf)
+	logger.AddFields(Fields{"containerID": "abc123", "podName": "testpod"})
+
+	logger.WithFields(Fields{"plugin": "weave", "ifName": "eth0"}).Infof("Network added\n")
+	if line := buf.String(); !strings.HasSuffix(line, "Network added containerID=abc123 ifName=eth0 plugin=weave podName=testpod\n") {
+		t.Errorf("Unexpected text line: %q", line)
+	}
+
+	buf.Reset()
+	logger.SetJSON(true)
+	logger.WithFields(Fields{"plugin": "weave"}).Warningf("Error: %v", "no ip")
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &obj); err != nil {
+		t.Fatalf("Error unmarshalling json line %q: %v", buf.String(), err)
+	}
+	if obj["level"] != "warning" || obj["msg"] != "Error: no ip" || obj["plugin"] != "weave" || obj["containerID"] != "abc123" {
+		t.Errorf("Unexpected json line: %v", obj)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	conf := `{"name": "k8s-pod-network", "policy": {"type": "k8s", "k8s_auth_token": "eyJhbGciOi.secret"}, "kubernetes": {"kubeconfig": "/etc/cni/net.d/genie-kubeconfig"}}`
+	redacted := Redact(conf)
+	if strings.Contains(redacted, "eyJhbGciOi") {
+		t.Errorf("Token not redacted: %s", redacted)
+	}
+	if !strings.Contains(redacted, `"k8s_auth_token": "--- REDACTED ---"`) || !strings.Contains(redacted, "/etc/cni/net.d/genie-kubeconfig") {
+		t.Errorf("Unexpected redaction: %s", redacted)
+	}
+}
@@ -0,0 +1,440 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics records the latency and the outcome of the operations done
+// by CNI Genie. As the plugin lives only for a single invocation, the metrics
+// are accumulated into a node local file in the Prometheus text format, to be
+// exported by the textfile collector of the node exporter.
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/cni-genie/CNI-Genie/utils/filelock"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DelegateOperations counts the invocations of the delegate plugins
+	DelegateOperations = "genie_delegate_operations_total"
+	// DelegateDuration observes the time taken by the delegate plugins
+	DelegateDuration = "genie_delegate_duration_seconds"
+	// KubeAPIRequests counts the requests made to the api server
+	KubeAPIRequests = "genie_kube_api_requests_total"
+	// KubeAPIDuration observes the time taken by the requests to the api server
+	KubeAPIDuration = "genie_kube_api_request_duration_seconds"
+
+	// ResultSuccess is the result label of a successful operation
+	ResultSuccess = "success"
+	// ResultError is the result label of a failed operation
+	ResultError = "error"
+
+	// FilePermission specifies the permission for the metrics file, which
+	// must be readable by the node exporter
+	FilePermission os.FileMode = 0644
+)
+
+const (
+	counterType   = "counter"
+	histogramType = "histogram"
+)
+
+// Labels are the label names and values identifying a series
+type Labels map[string]string
+
+// String renders the labels sorted by name, as in the text format
+func (l Labels) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, "%s=\"%s\"", name, escapeLabelValue(l[name]))
+	}
+	return buf.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+type family struct {
+	help    string
+	typ     string
+	buckets []float64
+}
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms.
+// Delegates setting up the interfaces may take several seconds.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+var families = map[string]*family{
+	DelegateOperations: {
+		help: "Number of delegate plugin invocations by plugin, network, verb and result.",
+		typ:  counterType,
+	},
+	DelegateDuration: {
+		help:    "Time taken by delegate plugin invocations by plugin, network, verb and result.",
+		typ:     histogramType,
+		buckets: latencyBuckets,
+	},
+	KubeAPIRequests: {
+		help: "Number of api server requests by method and result.",
+		typ:  counterType,
+	},
+	KubeAPIDuration: {
+		help:    "Time taken by api server requests by method and result.",
+		typ:     histogramType,
+		buckets: latencyBuckets,
+	},
+}
+
+// series holds the value of a counter, or the cumulative bucket counts,
+// the sum and the count of a histogram
+type series struct {
+	value   float64
+	buckets []float64
+	sum     float64
+	count   float64
+}
+
+// Registry accumulates the metrics of an invocation
+type Registry struct {
+	mu     sync.Mutex
+	series map[string]map[string]*series
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{series: make(map[string]map[string]*series)}
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the registry used by the package level functions
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+func (r *Registry) get(name string, labels string) *series {
+	f := families[name]
+	byLabels, ok := r.series[name]
+	if !ok {
+		byLabels = make(map[string]*series)
+		r.series[name] = byLabels
+	}
+	s, ok := byLabels[labels]
+	if !ok {
+		s = &series{buckets: make([]float64, len(f.buckets))}
+		byLabels[labels] = s
+	}
+	return s
+}
+
+// Inc increments the counter with the given name and labels
+func (r *Registry) Inc(name string, labels Labels) {
+	if f, ok := families[name]; !ok || f.typ != counterType {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.get(name, labels.String()).value++
+}
+
+// Observe adds an observation to the histogram with the given name and labels
+func (r *Registry) Observe(name string, labels Labels, value float64) {
+	f, ok := families[name]
+	if !ok || f.typ != histogramType {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.get(name, labels.String())
+	for i, bound := range f.buckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// merge adds the values of other registry to this one
+func (r *Registry) merge(other *Registry) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, byLabels := range other.series {
+		for labels, o := range byLabels {
+			s := r.get(name, labels)
+			s.value += o.value
+			for i := range s.buckets {
+				s.buckets[i] += o.buckets[i]
+			}
+			s.sum += o.sum
+			s.count += o.count
+		}
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.series))
+	for name := range r.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		f := families[name]
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", name, f.typ)
+
+		byLabels := r.series[name]
+		keys := make([]string, 0, len(byLabels))
+		for labels := range byLabels {
+			keys = append(keys, labels)
+		}
+		sort.Strings(keys)
+		for _, labels := range keys {
+			s := byLabels[labels]
+			if f.typ == counterType {
+				fmt.Fprintf(&buf, "%s%s %s\n", name, braced(labels), formatFloat(s.value))
+				continue
+			}
+			for i, bound := range f.buckets {
+				fmt.Fprintf(&buf, "%s_bucket%s %s\n", name, braced(withLe(labels, formatFloat(bound))), formatFloat(s.buckets[i]))
+			}
+			fmt.Fprintf(&buf, "%s_bucket%s %s\n", name, braced(withLe(labels, "+Inf")), formatFloat(s.count))
+			fmt.Fprintf(&buf, "%s_sum%s %s\n", name, braced(labels), formatFloat(s.sum))
+			fmt.Fprintf(&buf, "%s_count%s %s\n", name, braced(labels), formatFloat(s.count))
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+func braced(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+// withLe adds the bucket bound label. It is always rendered last, so that it
+// can be split off again while reading the file.
+func withLe(labels, le string) string {
+	if labels == "" {
+		return `le="` + le + `"`
+	}
+	return labels + `,le="` + le + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Read parses the metrics previously written by WriteTo. Series which are not
+// known to this version of CNI Genie are dropped.
+func Read(rd io.Reader) (*Registry, error) {
+	r := NewRegistry()
+	scanner := bufio.NewScanner(rd)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := r.parseSample(line); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Registry) parseSample(line string) error {
+	sep := strings.LastIndex(line, " ")
+	if sep < 0 {
+		return fmt.Errorf("Error parsing metrics line %q: value missing", line)
+	}
+	value, err := strconv.ParseFloat(line[sep+1:], 64)
+	if err != nil {
+		return fmt.Errorf("Error parsing metrics line %q: %v", line, err)
+	}
+	name, labels := line[:sep], ""
+	if i := strings.Index(name, "{"); i >= 0 {
+		name, labels = name[:i], strings.TrimSuffix(name[i+1:], "}")
+	}
+
+	if f, ok := families[name]; ok && f.typ == counterType {
+		r.get(name, labels).value = value
+		return nil
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		base := strings.TrimSuffix(name, suffix)
+		f, ok := families[base]
+		if base == name || !ok || f.typ != histogramType {
+			continue
+		}
+		switch suffix {
+		case "_sum":
+			r.get(base, labels).sum = value
+		case "_count":
+			r.get(base, labels).count = value
+		case "_bucket":
+			labels, le, ok := splitLe(labels)
+			if !ok {
+				return fmt.Errorf("Error parsing metrics line %q: bucket bound missing", line)
+			}
+			s := r.get(base, labels)
+			for i, bound := range f.buckets {
+				if formatFloat(bound) == le {
+					s.buckets[i] = value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// splitLe separates the bucket bound label from the rest of the labels.
+// Quotes within the label values are escaped, so the last unescaped `le="`
+// starts the bound label.
+func splitLe(labels string) (string, string, bool) {
+	i := strings.LastIndex(labels, `le="`)
+	if i < 0 || (i > 0 && labels[i-1] != ',') || !strings.HasSuffix(labels, `"`) {
+		return "", "", false
+	}
+	return strings.TrimSuffix(labels[:i], ","), labels[i+len(`le="`) : len(labels)-1], true
+}
+
+// WriteFile adds the metrics of the registry to the ones already in the file.
+// Concurrent invocations are serialized through a lock file, and the file is
+// replaced atomically so that the exporter never reads a partial file.
+func (r *Registry) WriteFile(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error creating metrics directory %s: %v", dir, err)
+	}
+
+	unlock, err := filelock.Lock(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("Error locking metrics file %s: %v", path, err)
+	}
+	defer unlock()
+
+	total := NewRegistry()
+	if f, err := os.Open(path); err == nil {
+		existing, err := Read(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("Error reading metrics file %s: %v", path, err)
+		}
+		total.merge(existing)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("Error opening metrics file %s: %v", path, err)
+	}
+	total.merge(r)
+
+	// The temporary file starts with a dot, so that it is not picked up by the
+	// textfile collector
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("Error creating temporary metrics file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := total.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Error writing metrics file: %v", err)
+	}
+	if err := tmp.Chmod(FilePermission); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Error setting permission of metrics file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("Error writing metrics file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("Error replacing metrics file %s: %v", path, err)
+	}
+	return nil
+}
+
+// result returns the result label for the outcome of an operation
+func result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
+
+// ObserveDelegate records an invocation of a delegate plugin
+func ObserveDelegate(plugin, network, verb string, err error, duration time.Duration) {
+	labels := Labels{
+		"plugin":  plugin,
+		"network": network,
+		"verb":    verb,
+		"result":  result(err),
+	}
+	defaultRegistry.Inc(DelegateOperations, labels)
+	defaultRegistry.Observe(DelegateDuration, labels, duration.Seconds())
+}
+
+// ObserveKubeAPI records a request to the api server. The result is the http
+// status code of the response, or "error" if no response was received.
+func ObserveKubeAPI(method, result string, duration time.Duration) {
+	labels := Labels{
+		"method": method,
+		"result": result,
+	}
+	defaultRegistry.Inc(KubeAPIRequests, labels)
+	defaultRegistry.Observe(KubeAPIDuration, labels, duration.Seconds())
+}
+
+// Flush adds the metrics recorded by this invocation to the metrics file and
+// resets them once written. The metrics of a failed write are kept for the
+// next flush.
+func Flush(path string) error {
+	defaultRegistry.mu.Lock()
+	recorded := &Registry{series: defaultRegistry.series}
+	defaultRegistry.series = make(map[string]map[string]*series)
+	defaultRegistry.mu.Unlock()
+
+	if err := recorded.WriteFile(path); err != nil {
+		defaultRegistry.merge(recorded)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAccumulates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-metrics")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "genie.prom")
+
+	labels := Labels{"plugin": "bridge", "network": `net"1`, "verb": "ADD", "result": ResultSuccess}
+	for i := 0; i < 2; i++ {
+		r := NewRegistry()
+		r.Inc(DelegateOperations, labels)
+		r.Observe(DelegateDuration, labels, 0.3)
+		if err := r.WriteFile(path); err != nil {
+			t.Fatalf("Error writing metrics file: %v", err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading metrics file: %v", err)
+	}
+	expected := []string{
+		"# TYPE genie_delegate_operations_total counter",
+		`genie_delegate_operations_total{network="net\"1",plugin="bridge",result="success",verb="ADD"} 2`,
+		"# TYPE genie_delegate_duration_seconds histogram",
+		`genie_delegate_duration_seconds_bucket{network="net\"1",plugin="bridge",result="success",verb="ADD",le="0.25"} 0`,
+		`genie_delegate_duration_seconds_bucket{network="net\"1",plugin="bridge",result="success",verb="ADD",le="0.5"} 2`,
+		`genie_delegate_duration_seconds_bucket{network="net\"1",plugin="bridge",result="success",verb="ADD",le="+Inf"} 2`,
+		`genie_delegate_duration_seconds_sum{network="net\"1",plugin="bridge",result="success",verb="ADD"} 0.6`,
+		`genie_delegate_duration_seconds_count{network="net\"1",plugin="bridge",result="success",verb="ADD"} 2`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Expected line %q in metrics file:\n%s", line, data)
+		}
+	}
+}
+
+func TestFlushKeepsMetricsOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genie-metrics")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "genie.prom")
+
+	// The metrics directory can not be created under a file
+	notDir := filepath.Join(dir, "file")
+	if err = ioutil.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	line := `genie_kube_api_requests_total{method="GET",result="200"} 1`
+	ObserveKubeAPI("GET", "200", 0)
+	if err = Flush(filepath.Join(notDir, "genie.prom")); err == nil {
+		t.Fatalf("Expected error flushing metrics under a file")
+	}
+
+	// The metrics are written by the next flush, and only once
+	for i := 0; i < 2; i++ {
+		if err = Flush(path); err != nil {
+			t.Fatalf("Error flushing metrics: %v", err)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Error reading metrics file: %v", err)
+		}
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("Flush %d: expected line %q in metrics file:\n%s", i, line, data)
+		}
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	r := NewRegistry()
+	r.Inc(KubeAPIRequests, Labels{"method": "GET", "result": "200"})
+	r.Inc(KubeAPIRequests, Labels{"method": "PATCH", "result": ResultError})
+	r.Observe(KubeAPIDuration, Labels{"method": "GET", "result": "200"}, 0.002)
+	r.Observe(KubeAPIDuration, Labels{"method": "GET", "result": "200"}, 120)
+
+	first := &bytes.Buffer{}
+	if _, err := r.WriteTo(first); err != nil {
+		t.Fatalf("Error writing metrics: %v", err)
+	}
+	read, err := Read(bytes.NewReader(first.Bytes()))
+	if err != nil {
+		t.Fatalf("Error reading metrics: %v", err)
+	}
+	second := &bytes.Buffer{}
+	if _, err := read.WriteTo(second); err != nil {
+		t.Fatalf("Error writing metrics: %v", err)
+	}
+	if first.String() != second.String() {
+		t.Errorf("Metrics changed after reading back; expected:\n%s\ngot:\n%s", first, second)
+	}
+
+	if _, err := Read(strings.NewReader("genie_delegate_operations_total{verb=\"ADD\"}\n")); err == nil {
+		t.Errorf("Expected error reading a line without value")
+	}
+}
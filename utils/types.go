@@ -28,6 +28,22 @@ import (
 const (
 	// Delimiter seperating plugin/network name from interfece name
 	IfNameDelimiter = "@"
+	// Suffix marking a plugin/network as optional for the pod, eg: "weave,monitoring@eth1?"
+	OptionalNetworkSuffix = "?"
+)
+
+// DefaultStateDir specifies the default directory for keeping the node local
+// state of genie, eg: the attachment records of containers
+const DefaultStateDir = "/var/lib/cni/genie"
+
+// Capabilities for which the arguments are passed to delegates through runtime config
+const (
+	CapabilityIPs            = "ips"
+	CapabilityMac            = "mac"
+	CapabilityPortMappings   = "portMappings"
+	CapabilityBandwidth      = "bandwidth"
+	CapabilityInfinibandGUID = "infinibandGUID"
+	CapabilityDeviceID       = "deviceID"
 )
 
 type ContainerInfoGenie struct {
@@ -75,6 +91,55 @@ type PolicyConfig struct {
 type KubernetesConfig struct {
 	K8sAPIRoot string `json:"k8s_api_root"`
 	Kubeconfig string `json:"kubeconfig"`
+	// Timeout of a single request to the api server, eg: "5s". By default, 5s is used
+	RequestTimeout string `json:"request_timeout"`
+	// Time within which a call to the api server, including its retries, has
+	// to complete. By default, 20s is used
+	CallTimeout string `json:"call_timeout"`
+	// Number of attempts made for a call failing with transient errors. By default, 4 attempts are made
+	MaxAttempts int `json:"max_attempts"`
+	// Delay before the first retry, doubled for every further retry. By default, 200ms is used
+	RetryBackoff string `json:"retry_backoff"`
+	// Rate of requests to the api server, by default 5 per second with bursts of 10
+	QPS   float32 `json:"qps"`
+	Burst int     `json:"burst"`
+	// Rate of the events recorded on pods, by default 1 per second with
+	// bursts of 25, across all the invocations of genie on the node. Events
+	// beyond it are dropped
+	EventQPS   float32 `json:"event_qps"`
+	EventBurst int     `json:"event_burst"`
+}
+
+// Label is a key value pair passed in the args of the network configuration
+type Label struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ConventionArgs are the args of the network configuration defined by the
+// cni conventions
+type ConventionArgs struct {
+	IPs    []string `json:"ips,omitempty"`
+	Labels []Label  `json:"labels,omitempty"`
+}
+
+// MesosNetworkInfo is the network info passed by the cni isolator of Mesos
+type MesosNetworkInfo struct {
+	Name   string `json:"name,omitempty"`
+	Labels struct {
+		Labels []Label `json:"labels,omitempty"`
+	} `json:"labels,omitempty"`
+}
+
+// MesosArgs are the args of the network configuration passed by Mesos
+type MesosArgs struct {
+	NetworkInfo MesosNetworkInfo `json:"network_info"`
+}
+
+// NetConfArgs are the args passed by the runtime in the network configuration
+type NetConfArgs struct {
+	Cni   *ConventionArgs `json:"cni,omitempty"`
+	Mesos *MesosArgs      `json:"org.apache.mesos,omitempty"`
 }
 
 // GenieConf describes cni-genie plugin configurations
@@ -83,10 +148,80 @@ type GenieConf struct {
 	Kubernetes KubernetesConfig `json:"kubernetes"`
 	Policy     PolicyConfig     `json:"policy"`
 	LogLevel   string           `json:"log_level"`
+	// File to write the logs into. By default, logs are written to stderr
+	LogFile string `json:"log_file"`
+	// Format of the logs: text (default) or json
+	LogFormat string `json:"log_format"`
 	// CNI-Genie default plugin
 	DefaultPlugin string `json:"default_plugin"`
+	// Primary network of the pods not selecting one through the cni annotation,
+	// given as the name of a network configuration in the conf dir, a
+	// configuration file (a path, or a file name in the conf dir) or a network
+	// attachment definition (namespace/name). By default, the first valid
+	// configuration file in the conf dir is used
+	ClusterNetwork string `json:"cluster_network"`
+	// Networks attached to every pod after the ones it selects, given in the
+	// same forms as the cluster network
+	DefaultNetworks []string `json:"default_networks,omitempty"`
+	// Namespaces whose pods may replace the cluster network with a network
+	// attachment definition through the cni-default-network annotation. "*"
+	// allows all the namespaces. By default, no namespace may
+	DefaultNetworkNamespaces []string `json:"default_network_namespaces,omitempty"`
+	// Namespace isolation restricts the network attachment definitions a pod
+	// refers to to the ones of its own namespace and of the global namespaces
+	NamespaceIsolation bool `json:"namespace_isolation"`
+	// Namespaces whose network attachment definitions may be referred to by the
+	// pods of any namespace with namespace isolation
+	GlobalNamespaces []string `json:"global_namespaces,omitempty"`
+	// Unix socket of the kubelet PodResources API, through which the devices
+	// allocated to pods are found. By default, /var/lib/kubelet/pod-resources/kubelet.sock is used
+	PodResourcesSocket string `json:"pod_resources_socket"`
+	// Checkpoint file of the kubelet device manager, read when the PodResources
+	// API is not served. By default, /var/lib/kubelet/device-plugins/kubelet_internal_checkpoint is used
+	DeviceCheckpointFile string `json:"device_checkpoint_file"`
 	// Address to reach at cadvisor. By default, http://127.0.0.1:4194 is used as CAdvisor address
 	CAdvisorAddr string `json:"cAdvisor_address"`
+	// Directory for keeping node local attachment records of containers and
+	// the token bucket of the events recorded on pods. By default, /var/lib/cni/genie is used
+	StateDir string `json:"state_dir"`
+	// Node local file into which the metrics of the delegate invocations and the
+	// api server requests are accumulated, in Prometheus text format. Metrics are
+	// not recorded if it is not set
+	MetricsFile string `json:"metrics_file"`
+	// Standalone mode runs genie without kubernetes. Networks are selected through
+	// the pod annotations passed by the runtime, container labels in CNI_ARGS and
+	// the selection file, and no kubernetes client is built
+	Standalone bool `json:"standalone"`
+	// Node local file holding the network selections of containers in standalone mode
+	SelectionFile string `json:"selection_file"`
+	// Directory of the delegate configuration files. By default, /etc/cni/net.d is used
+	ConfDir string `json:"conf_dir"`
+	// Directory of the delegate plugin binaries. By default, /opt/cni/bin is used
+	BinDir string `json:"bin_dir"`
+	// Args passed by the runtime in the network configuration, eg: by Mesos
+	Args *NetConfArgs `json:"args,omitempty"`
+	// Policy for merging the dns configurations of attachments: union (default),
+	// primary (the attachment owning the default route, or else the first) or
+	// attachment
+	DNSPolicy string `json:"dns_policy"`
+	// Attachment (plugin or network name, optionally followed by @interface) whose
+	// dns configuration is used with dns policy "attachment"
+	DNSAttachment string `json:"dns_attachment"`
+	// Attachments still in use, passed by the runtime on GC
+	ValidAttachments []GCAttachment `json:"cni.dev/valid-attachments,omitempty"`
+	// Unix socket of genie daemon. If set, the plugin only forwards the ADD, DEL
+	// and CHECK invocations to the daemon, which resolves and attaches the
+	// networks using its informer caches
+	DaemonSocket string `json:"daemon_socket"`
+	// Runtime config passed by the runtime for the capabilities declared in genie
+	// configuration, eg: port mappings. It is applied to the first attachment of the pod.
+	RuntimeConfig map[string]interface{} `json:"runtimeConfig,omitempty"`
+}
+
+// GCAttachment identifies an attachment reported as valid by the runtime on GC
+type GCAttachment struct {
+	ContainerID string `json:"containerID"`
+	IfName      string `json:"ifname"`
 }
 
 // K8sArgs is the valid CNI_ARGS used for Kubernetes
@@ -95,6 +230,7 @@ type K8sArgs struct {
 	IP                         net.IP
 	K8S_POD_NAME               types.UnmarshallableString
 	K8S_POD_NAMESPACE          types.UnmarshallableString
+	K8S_POD_UID                types.UnmarshallableString
 	K8S_POD_INFRA_CONTAINER_ID types.UnmarshallableString
 	K8S_ANNOT                  types.UnmarshallableString
 }
@@ -165,4 +301,22 @@ type PluginInfo struct {
 	OptionalArgs     map[string]string
 	ValidationParams interface{}
 	ValidateRes      ValidateResult
+	// Optional specifies that the pod does not depend on this attachment.
+	// Failure in attaching it is recorded instead of failing the pod.
+	Optional bool
+	// CapabilityArgs holds the runtime config requested for this attachment,
+	// keyed by capability. Each is passed only to delegates supporting it.
+	CapabilityArgs map[string]interface{}
+	// DefaultRoute specifies that this attachment owns the default route of
+	// the pod. Default routes of all the other attachments are removed.
+	DefaultRoute bool
+	// GatewayRequest specifies the gateways for the default route. If empty,
+	// the default route set up by the delegate is kept.
+	GatewayRequest []net.IP
+	// ConfSource describes where the delegate configuration comes from, eg: the
+	// configuration file or the network attachment definition
+	ConfSource string
+	// ResourceName is the device plugin resource of the network, whose devices
+	// allocated to the pod are passed to the delegate as deviceID runtime config
+	ResourceName string
 }
@@ -0,0 +1,301 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package types100 implements the result format of CNI specification
+// versions 1.0.0 and 1.1.0, along with the conversions from and to the
+// older (0.x) result versions supported by the cni library.
+package types100
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/cni/pkg/types/current"
+	"github.com/containernetworking/cni/pkg/version"
+)
+
+// ImplementedSpecVersion is the highest result version implemented by this package
+const ImplementedSpecVersion string = "1.1.0"
+
+// SupportedVersions lists the result versions handled by this package
+var SupportedVersions = []string{"1.0.0", ImplementedSpecVersion}
+
+// IsSupportedVersion reports whether the given version is a 1.x version
+func IsSupportedVersion(v string) bool {
+	for _, supported := range SupportedVersions {
+		if v == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// NewResult parses a 1.x result
+func NewResult(data []byte) (types.Result, error) {
+	result := &Result{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	if !IsSupportedVersion(result.CNIVersion) {
+		return nil, fmt.Errorf("result type supports %v but unmarshalled CNIVersion is %q", SupportedVersions, result.CNIVersion)
+	}
+	return result, nil
+}
+
+// NewResultFromResult converts a result of any supported version into a 1.x result
+func NewResultFromResult(result types.Result) (*Result, error) {
+	if r, ok := result.(*Result); ok {
+		return r, nil
+	}
+
+	// Bring the older versions up to the current (0.4.0) version first
+	curr, err := current.NewResultFromResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	newResult := &Result{
+		CNIVersion: ImplementedSpecVersion,
+		DNS:        curr.DNS,
+	}
+	for _, intf := range curr.Interfaces {
+		newResult.Interfaces = append(newResult.Interfaces, &Interface{
+			Name:    intf.Name,
+			Mac:     intf.Mac,
+			Sandbox: intf.Sandbox,
+		})
+	}
+	for _, ip := range curr.IPs {
+		newResult.IPs = append(newResult.IPs, &IPConfig{
+			Interface: ip.Interface,
+			Address:   ip.Address,
+			Gateway:   ip.Gateway,
+		})
+	}
+	for _, route := range curr.Routes {
+		newResult.Routes = append(newResult.Routes, &Route{
+			Dst: route.Dst,
+			GW:  route.GW,
+		})
+	}
+
+	return newResult, nil
+}
+
+// ParseResult parses the output of a plugin of the given version
+func ParseResult(cniVersion string, data []byte) (types.Result, error) {
+	if IsSupportedVersion(cniVersion) {
+		return NewResult(data)
+	}
+	return version.NewResult(cniVersion, data)
+}
+
+// Result is what gets returned from the plugin (via stdout) to the caller
+type Result struct {
+	CNIVersion string       `json:"cniVersion,omitempty"`
+	Interfaces []*Interface `json:"interfaces,omitempty"`
+	IPs        []*IPConfig  `json:"ips,omitempty"`
+	Routes     []*Route     `json:"routes,omitempty"`
+	DNS        types.DNS    `json:"dns,omitempty"`
+}
+
+func (r *Result) Version() string {
+	return ImplementedSpecVersion
+}
+
+func (r *Result) GetAsVersion(version string) (types.Result, error) {
+	if IsSupportedVersion(version) {
+		r.CNIVersion = version
+		return r, nil
+	}
+
+	// 1.x results can be represented in the older versions by
+	// dropping the fields those versions do not know about
+	curr := &current.Result{
+		CNIVersion: current.ImplementedSpecVersion,
+		DNS:        r.DNS,
+	}
+	for _, intf := range r.Interfaces {
+		curr.Interfaces = append(curr.Interfaces, &current.Interface{
+			Name:    intf.Name,
+			Mac:     intf.Mac,
+			Sandbox: intf.Sandbox,
+		})
+	}
+	for _, ip := range r.IPs {
+		ipVersion := "6"
+		if ip.Address.IP.To4() != nil {
+			ipVersion = "4"
+		}
+		curr.IPs = append(curr.IPs, &current.IPConfig{
+			Version:   ipVersion,
+			Interface: ip.Interface,
+			Address:   ip.Address,
+			Gateway:   ip.Gateway,
+		})
+	}
+	for _, route := range r.Routes {
+		curr.Routes = append(curr.Routes, &types.Route{
+			Dst: route.Dst,
+			GW:  route.GW,
+		})
+	}
+
+	return curr.GetAsVersion(version)
+}
+
+func (r *Result) Print() error {
+	return r.PrintTo(os.Stdout)
+}
+
+func (r *Result) PrintTo(writer io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+func (r *Result) String() string {
+	var str string
+	if len(r.Interfaces) > 0 {
+		str += fmt.Sprintf("Interfaces:%+v, ", r.Interfaces)
+	}
+	if len(r.IPs) > 0 {
+		str += fmt.Sprintf("IP:%+v, ", r.IPs)
+	}
+	if len(r.Routes) > 0 {
+		str += fmt.Sprintf("Routes:%+v, ", r.Routes)
+	}
+	return fmt.Sprintf("%sDNS:%+v", str, r.DNS)
+}
+
+// Interface contains values about the created interfaces
+type Interface struct {
+	Name       string `json:"name"`
+	Mac        string `json:"mac,omitempty"`
+	Mtu        int    `json:"mtu,omitempty"`
+	Sandbox    string `json:"sandbox,omitempty"`
+	SocketPath string `json:"socketPath,omitempty"`
+	PciID      string `json:"pciID,omitempty"`
+}
+
+func (i *Interface) String() string {
+	return fmt.Sprintf("%+v", *i)
+}
+
+// Int returns a pointer to the int value passed in.  Used to
+// set the IPConfig.Interface field.
+func Int(v int) *int {
+	return &v
+}
+
+// IPConfig contains values necessary to configure an IP address on an interface
+type IPConfig struct {
+	// Index into Result structs Interfaces list
+	Interface *int
+	Address   net.IPNet
+	Gateway   net.IP
+}
+
+func (i *IPConfig) String() string {
+	return fmt.Sprintf("%+v", *i)
+}
+
+// JSON (un)marshallable types
+type ipConfig struct {
+	Interface *int        `json:"interface,omitempty"`
+	Address   types.IPNet `json:"address"`
+	Gateway   net.IP      `json:"gateway,omitempty"`
+}
+
+func (c *IPConfig) MarshalJSON() ([]byte, error) {
+	ipc := ipConfig{
+		Interface: c.Interface,
+		Address:   types.IPNet(c.Address),
+		Gateway:   c.Gateway,
+	}
+
+	return json.Marshal(ipc)
+}
+
+func (c *IPConfig) UnmarshalJSON(data []byte) error {
+	ipc := ipConfig{}
+	if err := json.Unmarshal(data, &ipc); err != nil {
+		return err
+	}
+
+	c.Interface = ipc.Interface
+	c.Address = net.IPNet(ipc.Address)
+	c.Gateway = ipc.Gateway
+	return nil
+}
+
+// Route describes a route along with the attributes introduced in 1.1.0
+type Route struct {
+	Dst      net.IPNet
+	GW       net.IP
+	MTU      int
+	AdvMSS   int
+	Priority int
+	Table    *int
+	Scope    *int
+}
+
+func (r *Route) String() string {
+	return fmt.Sprintf("%+v", *r)
+}
+
+type route struct {
+	Dst      types.IPNet `json:"dst"`
+	GW       net.IP      `json:"gw,omitempty"`
+	MTU      int         `json:"mtu,omitempty"`
+	AdvMSS   int         `json:"advmss,omitempty"`
+	Priority int         `json:"priority,omitempty"`
+	Table    *int        `json:"table,omitempty"`
+	Scope    *int        `json:"scope,omitempty"`
+}
+
+func (r *Route) UnmarshalJSON(data []byte) error {
+	rt := route{}
+	if err := json.Unmarshal(data, &rt); err != nil {
+		return err
+	}
+
+	r.Dst = net.IPNet(rt.Dst)
+	r.GW = rt.GW
+	r.MTU = rt.MTU
+	r.AdvMSS = rt.AdvMSS
+	r.Priority = rt.Priority
+	r.Table = rt.Table
+	r.Scope = rt.Scope
+	return nil
+}
+
+func (r Route) MarshalJSON() ([]byte, error) {
+	rt := route{
+		Dst:      types.IPNet(r.Dst),
+		GW:       r.GW,
+		MTU:      r.MTU,
+		AdvMSS:   r.AdvMSS,
+		Priority: r.Priority,
+		Table:    r.Table,
+		Scope:    r.Scope,
+	}
+
+	return json.Marshal(rt)
+}